@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -42,6 +43,8 @@ type Response struct {
 }
 
 func main() {
+	ctx := context.Background()
+
 	// 从环境变量获取配置
 	azureOpenAIEndpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
 	azureOpenAIKey := os.Getenv("AZURE_OPENAI_API_KEY")
@@ -63,6 +66,12 @@ func main() {
 		log.Fatalf("初始化客户端错误: %s", err)
 	}
 
+	// 配置了内容安全服务时，在发送前检测提示攻击
+	shield := newPromptShieldFromEnv()
+	if shield != nil {
+		fmt.Println("已启用 Prompt Shields 提示攻击检测")
+	}
+
 	// 存储对话历史
 	var messages []azopenai.ChatRequestMessageClassification
 
@@ -89,6 +98,18 @@ func main() {
 			break
 		}
 
+		// 检测到攻击的输入不加入对话历史，也不发送给模型
+		if shield != nil {
+			if err := shield.check(ctx, userInput, nil); err != nil {
+				if errors.Is(err, ErrPromptAttack) {
+					fmt.Printf("已拒绝: %v\n", err)
+				} else {
+					fmt.Printf("提示攻击检测失败: %v\n", err)
+				}
+				continue
+			}
+		}
+
 		// 添加用户消息到历史
 		userMessage := azopenai.ChatRequestUserMessage{
 			Content: azopenai.NewChatRequestUserMessageContent(userInput),
@@ -100,7 +121,7 @@ func main() {
 
 		// 发出聊天完成请求
 		resp, err := client.GetChatCompletions(
-			context.TODO(),
+			ctx,
 			azopenai.ChatCompletionsOptions{
 				Messages:            messages,
 				DeploymentName:      &deploymentName,
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrPromptAttack 表示 Prompt Shields 在用户输入或文档中检测到攻击
+var ErrPromptAttack = errors.New("检测到提示攻击")
+
+// promptShield 在发送消息前调用内容安全服务的 Prompt Shields 接口
+// 与 cognitiveServicesContentSafety 中的 shieldPrompt 使用相同的接口和结果结构
+type promptShield struct {
+	endpoint   string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// newPromptShieldFromEnv 从 AZURE_CONTENT_SAFETY_ENDPOINT 和 AZURE_CONTENT_SAFETY_KEY 创建检测器，未配置时返回 nil
+func newPromptShieldFromEnv() *promptShield {
+	endpoint := os.Getenv("AZURE_CONTENT_SAFETY_ENDPOINT")
+	apiKey := os.Getenv("AZURE_CONTENT_SAFETY_KEY")
+	if endpoint == "" || apiKey == "" {
+		return nil
+	}
+	return &promptShield{
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// shieldRequest 表示 Prompt Shields 的请求，没有文档时不发送 documents 字段
+type shieldRequest struct {
+	UserPrompt string   `json:"userPrompt"`
+	Documents  []string `json:"documents,omitempty"`
+}
+
+// shieldResult 表示 Prompt Shields 的检测结果
+type shieldResult struct {
+	UserPromptAnalysis *struct {
+		AttackDetected bool `json:"attackDetected"`
+	} `json:"userPromptAnalysis"`
+	DocumentsAnalysis []struct {
+		AttackDetected bool `json:"attackDetected"`
+	} `json:"documentsAnalysis"`
+}
+
+// check 检测用户输入和附带的文档，检测到攻击时返回 ErrPromptAttack
+func (s *promptShield) check(ctx context.Context, userPrompt string, documents []string) error {
+	payload, err := json.Marshal(shieldRequest{UserPrompt: userPrompt, Documents: documents})
+	if err != nil {
+		return fmt.Errorf("序列化请求失败: %v", err)
+	}
+	apiURL := s.endpoint + "/contentsafety/text:shieldPrompt?api-version=2024-09-01"
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ocp-Apim-Subscription-Key", s.apiKey)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送HTTP请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Prompt Shields 返回错误: %s, 状态码: %d", string(body), resp.StatusCode)
+	}
+
+	var result shieldResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	if result.UserPromptAnalysis != nil && result.UserPromptAnalysis.AttackDetected {
+		return fmt.Errorf("%w: 用户输入疑似越狱攻击", ErrPromptAttack)
+	}
+	for i, doc := range result.DocumentsAnalysis {
+		if doc.AttackDetected {
+			return fmt.Errorf("%w: 第 %d 个文档疑似间接注入攻击", ErrPromptAttack, i+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package azblob
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// AuthMode 表示访问 Blob 服务的认证方式
+type AuthMode string
+
+const (
+	AuthAuto             AuthMode = ""                 // 按配置自动选择：连接字符串、SAS URL、账号密钥，都没有时使用 DefaultAzureCredential
+	AuthSharedKey        AuthMode = "sharedkey"        // 账号名称和密钥
+	AuthConnectionString AuthMode = "connectionstring" // 连接字符串
+	AuthSAS              AuthMode = "sas"              // 带 SAS 令牌的服务地址
+	AuthDefault          AuthMode = "default"          // DefaultAzureCredential：依次尝试环境变量、工作负载标识、托管标识、Azure CLI 等
+	AuthManagedIdentity  AuthMode = "managedidentity"  // 托管标识，ClientID 为空时使用系统分配的标识
+	AuthClientSecret     AuthMode = "clientsecret"     // 服务主体的客户端密码
+	AuthAzureCLI         AuthMode = "azurecli"         // az login 登录的账号
+	AuthAnonymous        AuthMode = "anonymous"        // 匿名访问公共容器
+)
+
+// Config 表示创建客户端的配置
+// 使用 Microsoft Entra 认证（default、managedidentity、clientsecret、azurecli）时，账号需要为标识分配 Storage Blob Data 角色
+type Config struct {
+	Auth             AuthMode
+	ServiceURL       string // 服务地址，为空时使用 https://<AccountName>.blob.core.windows.net/；使用 Azurite 等模拟器时设为 http://127.0.0.1:10000/<账号>/
+	AccountName      string
+	AccountKey       string
+	ConnectionString string
+	SASURL           string // 账号级 SAS 的服务地址，如 https://<账号>.blob.core.windows.net/?sv=...&sig=...
+	TenantID         string // clientsecret 使用
+	ClientID         string // clientsecret 使用；managedidentity 时为用户分配标识的客户端ID
+	ClientSecret     string
+	ClientOptions    *azblob.ClientOptions // 重试、传输等选项，可为空
+}
+
+// ConfigFromEnv 从环境变量读取配置
+//
+//	AZURE_STORAGE_AUTH               认证方式，为空时自动选择
+//	AZURE_STORAGE_SERVICE_URL        服务地址
+//	AZURE_STORAGE_ACCOUNT            账号名称
+//	AZURE_STORAGE_KEY                账号密钥
+//	AZURE_STORAGE_CONNECTION_STRING  连接字符串
+//	AZURE_STORAGE_SAS_URL            带 SAS 的服务地址
+//	AZURE_TENANT_ID / AZURE_CLIENT_ID / AZURE_CLIENT_SECRET  服务主体或托管标识
+func ConfigFromEnv() Config {
+	return Config{
+		Auth:             AuthMode(strings.ToLower(os.Getenv("AZURE_STORAGE_AUTH"))),
+		ServiceURL:       os.Getenv("AZURE_STORAGE_SERVICE_URL"),
+		AccountName:      os.Getenv("AZURE_STORAGE_ACCOUNT"),
+		AccountKey:       os.Getenv("AZURE_STORAGE_KEY"),
+		ConnectionString: os.Getenv("AZURE_STORAGE_CONNECTION_STRING"),
+		SASURL:           os.Getenv("AZURE_STORAGE_SAS_URL"),
+		TenantID:         os.Getenv("AZURE_TENANT_ID"),
+		ClientID:         os.Getenv("AZURE_CLIENT_ID"),
+		ClientSecret:     os.Getenv("AZURE_CLIENT_SECRET"),
+	}
+}
+
+// mode 返回实际使用的认证方式
+func (cfg Config) mode() AuthMode {
+	if cfg.Auth != AuthAuto {
+		return cfg.Auth
+	}
+	switch {
+	case cfg.ConnectionString != "":
+		return AuthConnectionString
+	case cfg.SASURL != "":
+		return AuthSAS
+	case cfg.AccountKey != "":
+		return AuthSharedKey
+	}
+	return AuthDefault
+}
+
+// serviceURL 返回服务地址，保证以 "/" 结尾
+func (cfg Config) serviceURL() (string, error) {
+	serviceURL := cfg.ServiceURL
+	if serviceURL == "" {
+		if cfg.AccountName == "" {
+			return "", fmt.Errorf("必须指定存储账号名称或服务地址")
+		}
+		serviceURL = fmt.Sprintf("https://%s.blob.core.windows.net/", cfg.AccountName)
+	}
+	u, err := url.Parse(serviceURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("无效的服务地址: %s", serviceURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.String(), nil
+}
+
+// NewClientFromConfig 按配置的认证方式创建客户端
+func NewClientFromConfig(cfg Config) (*Client, error) {
+	switch mode := cfg.mode(); mode {
+	case AuthSharedKey:
+		if cfg.AccountName == "" || cfg.AccountKey == "" {
+			return nil, fmt.Errorf("使用账号密钥认证时必须指定账号名称和密钥")
+		}
+		credential, err := azblob.NewSharedKeyCredential(cfg.AccountName, cfg.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("无法创建凭证: %v", err)
+		}
+		serviceURL, err := cfg.serviceURL()
+		if err != nil {
+			return nil, err
+		}
+		client, err := azblob.NewClientWithSharedKeyCredential(serviceURL, credential, cfg.ClientOptions)
+		if err != nil {
+			return nil, fmt.Errorf("无法创建客户端: %v", err)
+		}
+		return &Client{client: client, sharedKey: credential}, nil
+	case AuthConnectionString:
+		if cfg.ConnectionString == "" {
+			return nil, fmt.Errorf("未指定连接字符串")
+		}
+		client, err := azblob.NewClientFromConnectionString(cfg.ConnectionString, cfg.ClientOptions)
+		if err != nil {
+			return nil, fmt.Errorf("无法通过连接字符串创建客户端: %v", err)
+		}
+		return &Client{client: client, sharedKey: connectionStringCredential(cfg.ConnectionString)}, nil
+	case AuthSAS:
+		return newSASClient(cfg.SASURL, cfg.ClientOptions)
+	case AuthAnonymous:
+		serviceURL, err := cfg.serviceURL()
+		if err != nil {
+			return nil, err
+		}
+		client, err := azblob.NewClientWithNoCredential(serviceURL, cfg.ClientOptions)
+		if err != nil {
+			return nil, fmt.Errorf("无法创建客户端: %v", err)
+		}
+		return &Client{client: client}, nil
+	case AuthDefault, AuthManagedIdentity, AuthClientSecret, AuthAzureCLI:
+		credential, err := tokenCredential(cfg, mode)
+		if err != nil {
+			return nil, err
+		}
+		serviceURL, err := cfg.serviceURL()
+		if err != nil {
+			return nil, err
+		}
+		return NewClientWithTokenCredential(serviceURL, credential, cfg.ClientOptions)
+	default:
+		return nil, fmt.Errorf("不支持的认证方式: %s", cfg.Auth)
+	}
+}
+
+// tokenCredential 按认证方式创建 Microsoft Entra 凭证
+func tokenCredential(cfg Config, mode AuthMode) (azcore.TokenCredential, error) {
+	var credential azcore.TokenCredential
+	var err error
+	switch mode {
+	case AuthManagedIdentity:
+		opts := &azidentity.ManagedIdentityCredentialOptions{}
+		if cfg.ClientID != "" {
+			opts.ID = azidentity.ClientID(cfg.ClientID)
+		}
+		credential, err = azidentity.NewManagedIdentityCredential(opts)
+	case AuthClientSecret:
+		if cfg.TenantID == "" || cfg.ClientID == "" || cfg.ClientSecret == "" {
+			return nil, fmt.Errorf("使用客户端密码认证时必须指定租户ID、客户端ID和客户端密码")
+		}
+		credential, err = azidentity.NewClientSecretCredential(cfg.TenantID, cfg.ClientID, cfg.ClientSecret, nil)
+	case AuthAzureCLI:
+		credential, err = azidentity.NewAzureCLICredential(nil)
+	default:
+		credential, err = azidentity.NewDefaultAzureCredential(nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无法创建 %s 凭证: %v", mode, err)
+	}
+	return credential, nil
+}
+
+// NewClientWithTokenCredential 使用 Microsoft Entra 凭证创建客户端，可以传入任意 azidentity 凭证
+func NewClientWithTokenCredential(serviceURL string, credential azcore.TokenCredential, options *azblob.ClientOptions) (*Client, error) {
+	client, err := azblob.NewClient(serviceURL, credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("无法创建客户端: %v", err)
+	}
+	return &Client{client: client, token: true}, nil
+}
+
+// connectionStringCredential 从连接字符串中取出账号名称和密钥；使用 SAS 的连接字符串没有密钥，返回 nil
+func connectionStringCredential(connectionString string) *azblob.SharedKeyCredential {
+	values := map[string]string{}
+	for _, part := range strings.Split(connectionString, ";") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			values[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	if values["accountname"] == "" || values["accountkey"] == "" {
+		return nil
+	}
+	credential, err := azblob.NewSharedKeyCredential(values["accountname"], values["accountkey"])
+	if err != nil {
+		return nil
+	}
+	return credential
+}
+
+// NewClientFromConnectionString 使用连接字符串创建客户端
+func NewClientFromConnectionString(connectionString string) (*Client, error) {
+	return NewClientFromConfig(Config{Auth: AuthConnectionString, ConnectionString: connectionString})
+}
+
+// NewClientWithSASURL 使用带账号级 SAS 的服务地址创建客户端
+func NewClientWithSASURL(sasURL string) (*Client, error) {
+	return NewClientFromConfig(Config{Auth: AuthSAS, SASURL: sasURL})
+}
+
+// newSASClient 创建使用 SAS 认证的客户端；SAS 必须是账号级的，地址中不能包含容器
+func newSASClient(sasURL string, options *azblob.ClientOptions) (*Client, error) {
+	u, err := url.Parse(sasURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("无效的 SAS 地址")
+	}
+	if u.Query().Get("sig") == "" {
+		return nil, fmt.Errorf("SAS 地址中缺少签名（sig）")
+	}
+	client, err := azblob.NewClientWithNoCredential(sasURL, options)
+	if err != nil {
+		return nil, fmt.Errorf("无法创建客户端: %v", err)
+	}
+	return &Client{client: client}, nil
+}
@@ -0,0 +1,81 @@
+package azblob
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConfigMode(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want AuthMode
+	}{
+		{Config{ConnectionString: "x", AccountKey: "k"}, AuthConnectionString},
+		{Config{SASURL: "https://a.blob.core.windows.net/?sig=x", AccountKey: "k"}, AuthSAS},
+		{Config{AccountName: "a", AccountKey: "k"}, AuthSharedKey},
+		{Config{AccountName: "a"}, AuthDefault},
+		{Config{Auth: AuthAzureCLI, AccountKey: "k"}, AuthAzureCLI},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.mode(); got != tt.want {
+			t.Errorf("%+v 的认证方式 = %s, 期望 %s", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestConfigServiceURL(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		want    string
+		wantErr bool
+	}{
+		{Config{AccountName: "mystorage"}, "https://mystorage.blob.core.windows.net/", false},
+		{Config{AccountName: "mystorage", ServiceURL: "http://127.0.0.1:10000/devstoreaccount1"}, "http://127.0.0.1:10000/devstoreaccount1/", false},
+		{Config{}, "", true},
+		{Config{ServiceURL: "not a url"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := tt.cfg.serviceURL()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("serviceURL(%+v) = %q, %v", tt.cfg, got, err)
+		}
+	}
+}
+
+func TestNewClientFromConfigErrors(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"缺少密钥":    {Auth: AuthSharedKey, AccountName: "a"},
+		"缺少连接字符串": {Auth: AuthConnectionString},
+		"SAS缺少签名": {SASURL: "https://a.blob.core.windows.net/?sv=2023-11-03"},
+		"缺少服务主体":  {Auth: AuthClientSecret, AccountName: "a", TenantID: "t"},
+		"未知认证方式":  {Auth: "password"},
+		"缺少账号":    {Auth: AuthAnonymous},
+	} {
+		if _, err := NewClientFromConfig(cfg); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	srv := newFakeBlobServer()
+	defer srv.Close()
+	srv.containers["public"] = map[string]*fakeBlob{}
+
+	for name, cfg := range map[string]Config{
+		"账号密钥": {AccountName: testAccountName, AccountKey: testAccountKey, ServiceURL: srv.serviceURL()},
+		"连接字符串": {ConnectionString: "DefaultEndpointsProtocol=http;AccountName=" + testAccountName +
+			";AccountKey=" + testAccountKey + ";BlobEndpoint=" + srv.serviceURL()},
+		"SAS":  {SASURL: srv.serviceURL() + "?sv=2023-11-03&ss=b&srt=sco&sp=rl&sig=abc"},
+		"匿名访问": {Auth: AuthAnonymous, ServiceURL: srv.serviceURL()},
+	} {
+		client, err := NewClientFromConfig(cfg)
+		if err != nil {
+			t.Errorf("%s: 创建客户端失败: %v", name, err)
+			continue
+		}
+		if _, err := client.ListAllBlobs(context.Background(), "public", ""); err != nil {
+			t.Errorf("%s: 列出失败: %v", name, err)
+		}
+	}
+}
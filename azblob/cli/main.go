@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"tmp/azblob"
+)
+
+func main() {
+	action := flag.String("action", "list", "操作类型：upload/download/list/delete/copy/sync/sas/sas-info/abort-upload/find")
+	containerName := flag.String("container", "", "容器名称")
+	blobName := flag.String("blob", "", "blob 名称；上传时为空则使用文件名")
+	file := flag.String("file", "", "本地文件路径（action=upload/download）")
+	prefix := flag.String("prefix", "", "blob 名称前缀（action=list/sync）")
+	delimiter := flag.String("delimiter", "", "分层列出时的分隔符，通常为 /（action=list）")
+	resume := flag.Bool("resume", false, "可续传上传：记录已上传的块，中断后再次运行从断点继续（action=upload）")
+	blockSize := flag.Int64("block-size", 4, "块大小，单位 MB（action=upload -resume）")
+	concurrency := flag.Int("concurrency", 4, "同时上传的块数（action=upload -resume）")
+	contentType := flag.String("content-type", "", "Content-Type，为空时按扩展名或文件内容检测（action=upload）")
+	cacheControl := flag.String("cache-control", "", "Cache-Control，如 \"public, max-age=3600\"（action=upload）")
+	contentEncoding := flag.String("content-encoding", "", "Content-Encoding，文件已压缩时设置，如 gzip（action=upload）")
+	metadata := flag.String("metadata", "", "用户元数据，如 owner=alice,source=export（action=upload）")
+	tags := flag.String("tags", "", "blob 索引标记，如 project=apollo,year=2024（action=upload 时设置，action=find 时查询全部相等的 blob）")
+	where := flag.String("where", "", "标记查询表达式，如 \"year\">='2024'，设置后忽略 -tags（action=find）")
+	showProgress := flag.Bool("progress", true, "显示进度条（action=upload/download）")
+	source := flag.String("source", "", "复制的源 blob 地址（action=copy）")
+	dir := flag.String("dir", "", "要同步的本地目录（action=sync）")
+	direction := flag.String("direction", "upload", "同步方向：upload（本地到容器）/download（容器到本地）（action=sync）")
+	deleteExtra := flag.Bool("delete", false, "删除目标中源里没有的文件（action=sync）")
+	dryRun := flag.Bool("dry-run", false, "只打印同步计划，不做任何修改（action=sync）")
+	workers := flag.Int("workers", 4, "并发传输数（action=sync）")
+	snapshots := flag.Bool("snapshots", false, "列出快照，删除时连同快照一起删除（action=list/delete）")
+	auth := flag.String("auth", "", "认证方式：sharedkey/connectionstring/sas/default/managedidentity/clientsecret/azurecli/anonymous，为空时按环境变量自动选择")
+	permissions := flag.String("permissions", "r", "SAS 权限，如 r、rw、rl（action=sas）")
+	expiry := flag.Duration("expiry", time.Hour, "SAS 有效期（action=sas）")
+	ipRange := flag.String("ip", "", "允许访问的 IP 或范围，如 203.0.113.0-203.0.113.255（action=sas）")
+	allowHTTP := flag.Bool("allow-http", false, "SAS 同时允许 HTTP，默认只允许 HTTPS（action=sas）")
+	userDelegation := flag.Bool("user-delegation", false, "签发用户委托 SAS，需要 Microsoft Entra 认证（action=sas）")
+	sasURL := flag.String("url", "", "要检查的 SAS 地址（action=sas-info）")
+	serviceURL := flag.String("service-url", "", "Blob 服务地址，为空时使用环境变量 AZURE_STORAGE_SERVICE_URL 或 https://<账号>.blob.core.windows.net/")
+	flag.Parse()
+
+	// 解析 SAS 地址不需要凭证
+	if *action == "sas-info" {
+		if *sasURL == "" {
+			fmt.Println("请使用 -url 参数指定 SAS 地址")
+			return
+		}
+		info, err := azblob.ParseSASURL(*sasURL)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		azblob.PrintSASInfo(os.Stdout, info)
+		if err := info.Validate(time.Now()); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("SAS 有效")
+		return
+	}
+
+	// .env 文件可选，环境变量中已有时不需要
+	godotenv.Load()
+	cfg := azblob.ConfigFromEnv()
+	if *auth != "" {
+		cfg.Auth = azblob.AuthMode(*auth)
+	}
+	if *serviceURL != "" {
+		cfg.ServiceURL = *serviceURL
+	}
+	// 按标记查询时容器可选，为空时查询整个账号
+	if *containerName == "" && *action != "find" {
+		fmt.Println("请使用 -container 参数指定容器")
+		return
+	}
+
+	client, err := azblob.NewClientFromConfig(cfg)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	// Ctrl+C 时取消正在进行的操作
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// 进度条输出到标准错误，不影响标准输出中的结果
+	var progress azblob.ProgressFunc
+	progressDone := func() {}
+	if *showProgress {
+		progress, progressDone = progressBar(os.Stderr)
+	}
+
+	switch *action {
+	case "upload":
+		if *file == "" {
+			fmt.Println("请使用 -file 参数指定要上传的文件")
+			return
+		}
+		uploadOpts := azblob.UploadOptions{
+			ContentType:     *contentType,
+			CacheControl:    *cacheControl,
+			ContentEncoding: *contentEncoding,
+			Progress:        progress,
+		}
+		if uploadOpts.Metadata, err = parseKeyValues(*metadata); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if uploadOpts.Tags, err = parseKeyValues(*tags); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if *resume {
+			if *cacheControl != "" || *contentEncoding != "" || *contentType != "" || *metadata != "" || *tags != "" {
+				fmt.Println("可续传上传只自动检测 Content-Type，不支持 -content-type、-cache-control、-content-encoding、-metadata 和 -tags")
+				return
+			}
+			result, err := client.ResumableUpload(ctx, *file, *containerName, *blobName, azblob.ResumableUploadOptions{
+				BlockSize:   *blockSize * 1024 * 1024,
+				Concurrency: *concurrency,
+				Progress:    progress,
+			})
+			progressDone()
+			if err != nil {
+				fmt.Println(err)
+				fmt.Println("再次运行相同的命令可以从断点继续上传，或使用 -action abort-upload 放弃并丢弃已上传的块")
+				return
+			}
+			fmt.Printf("成功上传文件 %s 到容器 %s，共 %d 块，续传跳过 %d 块，MD5 %x\n", *file, *containerName, result.Blocks, result.ResumedBlocks, result.ContentMD5)
+			return
+		}
+		err := client.UploadFileWithOptions(ctx, *file, *containerName, *blobName, uploadOpts)
+		progressDone()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("成功上传文件 %s 到容器 %s\n", *file, *containerName)
+	case "abort-upload":
+		if *file == "" {
+			fmt.Println("请使用 -file 参数指定中断上传的文件")
+			return
+		}
+		discarded, err := client.AbortResumableUpload(ctx, *file, *containerName, *blobName, azblob.ResumableUploadOptions{})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if discarded {
+			fmt.Printf("已放弃上传 %s，并丢弃了未提交的块\n", *file)
+		} else {
+			fmt.Printf("已放弃上传 %s 并删除断点文件；目标 blob 已存在，丢弃未提交的块会修改它，这些块将由服务在 7 天后自动清理\n", *file)
+		}
+	case "download":
+		if *blobName == "" || *file == "" {
+			fmt.Println("请使用 -blob 和 -file 参数指定要下载的 blob 和保存路径")
+			return
+		}
+		n, err := client.DownloadFileWithOptions(ctx, *containerName, *blobName, *file, azblob.DownloadOptions{Progress: progress})
+		progressDone()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("成功下载 %s 到 %s，共 %d 字节\n", *blobName, *file, n)
+	case "list":
+		opts := azblob.ListOptions{Prefix: *prefix, Delimiter: *delimiter, IncludeSnapshots: *snapshots}
+		for {
+			page, err := client.ListBlobs(ctx, *containerName, opts)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			for _, p := range page.Prefixes {
+				fmt.Printf("%-10s %s\n", "<DIR>", p)
+			}
+			for _, b := range page.Blobs {
+				name := b.Name
+				if b.Snapshot != "" {
+					name += " @" + b.Snapshot
+				}
+				fmt.Printf("%10d %s %s\n", b.Size, b.LastModified.Local().Format("2006-01-02 15:04:05"), name)
+			}
+			if page.NextMarker == "" {
+				break
+			}
+			opts.Marker = page.NextMarker
+		}
+	case "delete":
+		if *blobName == "" {
+			fmt.Println("请使用 -blob 参数指定要删除的 blob")
+			return
+		}
+		if err := client.DeleteBlob(ctx, *containerName, *blobName, *snapshots); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("已删除 %s\n", *blobName)
+	case "copy":
+		if *source == "" || *blobName == "" {
+			fmt.Println("请使用 -source 和 -blob 参数指定源地址和目标 blob")
+			return
+		}
+		if err := client.CopyBlob(ctx, *source, *containerName, *blobName, 0); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("已复制到 %s\n", client.BlobURL(*containerName, *blobName))
+	case "sync":
+		if *dir == "" {
+			fmt.Println("请使用 -dir 参数指定本地目录")
+			return
+		}
+		opts := azblob.SyncOptions{
+			LocalDir:  *dir,
+			Container: *containerName,
+			Prefix:    *prefix,
+			Delete:    *deleteExtra,
+			DryRun:    *dryRun,
+			Workers:   *workers,
+		}
+		switch *direction {
+		case "upload":
+			opts.Direction = azblob.SyncUpload
+		case "download":
+			opts.Direction = azblob.SyncDownload
+		default:
+			fmt.Println("无效的同步方向，请使用 upload 或 download")
+			return
+		}
+
+		// 先打印计划，再执行
+		plan, err := client.PlanSync(ctx, opts)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		azblob.PrintSyncPlan(os.Stdout, plan)
+		if *dryRun || len(plan.Operations) == 0 {
+			return
+		}
+		result, err := client.ApplySync(ctx, opts, plan)
+		for _, f := range result.Failed {
+			fmt.Printf("失败: %s %s: %v\n", f.Operation.Action, f.Operation.Path, f.Err)
+		}
+		fmt.Printf("完成 %d 项操作，传输 %d 字节\n", result.Completed, result.Bytes)
+		if err != nil {
+			fmt.Println(err)
+		}
+	case "find":
+		query := *where
+		if query == "" {
+			tagFilter, err := parseKeyValues(*tags)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if len(tagFilter) == 0 {
+				fmt.Println("请使用 -tags 或 -where 参数指定查询条件")
+				return
+			}
+			query = azblob.TagQuery(tagFilter)
+		}
+		found, err := client.FindBlobsByTags(ctx, *containerName, query)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, b := range found {
+			fmt.Printf("%s/%s %s\n", b.Container, b.Name, azblob.TagQuery(b.Tags))
+		}
+		fmt.Printf("共找到 %d 个 blob\n", len(found))
+	case "sas":
+		opts := azblob.SASOptions{
+			Permissions:    *permissions,
+			ExpiresIn:      *expiry,
+			IPRange:        *ipRange,
+			AllowHTTP:      *allowHTTP,
+			UserDelegation: *userDelegation,
+		}
+		// 未指定 blob 时签发容器 SAS
+		var signed string
+		if *blobName == "" {
+			signed, err = client.ContainerSASURL(ctx, *containerName, opts)
+		} else {
+			signed, err = client.BlobSASURL(ctx, *containerName, *blobName, opts)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(signed)
+	default:
+		fmt.Println("无效的操作类型。请使用 -action 参数指定操作类型：upload/download/list/delete/copy/sync/sas/sas-info/abort-upload/find")
+		flag.PrintDefaults()
+	}
+}
+
+// parseKeyValues 解析 k1=v1,k2=v2 形式的参数
+func parseKeyValues(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	values := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("无效的参数 %q，格式应为 key=value", pair)
+		}
+		values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return values, nil
+}
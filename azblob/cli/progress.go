@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"tmp/azblob"
+)
+
+// 进度条的宽度（字符数）
+const progressBarWidth = 30
+
+// progressBar 返回在终端同一行刷新进度条的回调，传输结束后调用 done 换行
+func progressBar(w io.Writer) (progress azblob.ProgressFunc, done func()) {
+	printed := false
+	progress = func(p azblob.Progress) {
+		filled := int(p.Percent() / 100 * progressBarWidth)
+		filled = max(0, min(filled, progressBarWidth))
+		line := fmt.Sprintf("[%s%s] %5.1f%% %s/%s %s/s",
+			strings.Repeat("█", filled), strings.Repeat("░", progressBarWidth-filled),
+			p.Percent(), formatBytes(p.Bytes), formatBytes(p.Total), formatBytes(int64(p.Rate)))
+		if p.ETA > 0 {
+			line += " 剩余 " + formatDuration(p.ETA)
+		}
+		// 末尾的空格覆盖上一次较长的输出
+		fmt.Fprintf(w, "\r%s    ", line)
+		printed = true
+	}
+	done = func() {
+		if printed {
+			fmt.Fprintln(w)
+		}
+	}
+	return progress, done
+}
+
+// formatBytes 把字节数格式化为 B、KB、MB、GB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n) / unit
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f%s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1fTB", value)
+}
+
+// formatDuration 把时间格式化为 时:分:秒 或 分:秒
+func formatDuration(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,431 @@
+package azblob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+// 复制 blob 时查询复制状态的默认间隔
+const defaultCopyPollInterval = 2 * time.Second
+
+// ErrCopyFailed 表示服务端复制的状态为 failed 或 aborted
+var ErrCopyFailed = errors.New("复制失败")
+
+// Client 封装 Blob 服务客户端；凭证和底层连接在创建时初始化，之后可以重复使用，并发调用是安全的
+type Client struct {
+	client    *azblob.Client
+	sharedKey *azblob.SharedKeyCredential // 使用账号密钥或包含密钥的连接字符串时不为空，用于签发服务 SAS
+	token     bool                        // 使用 Microsoft Entra 认证，可以签发用户委托 SAS
+}
+
+// NewClient 使用存储账号名称和密钥创建客户端；其他认证方式和自定义服务地址见 NewClientFromConfig
+func NewClient(accountName, accountKey string) (*Client, error) {
+	return NewClientFromConfig(Config{Auth: AuthSharedKey, AccountName: accountName, AccountKey: accountKey})
+}
+
+// URL 返回 Blob 服务的地址
+func (c *Client) URL() string {
+	return c.client.URL()
+}
+
+// BlobURL 返回 blob 的完整地址，可以作为 CopyBlob 的源地址
+func (c *Client) BlobURL(containerName, blobName string) string {
+	return c.blobClient(containerName, blobName).URL()
+}
+
+func (c *Client) containerClient(containerName string) *container.Client {
+	return c.client.ServiceClient().NewContainerClient(containerName)
+}
+
+func (c *Client) blobClient(containerName, blobName string) *blob.Client {
+	return c.containerClient(containerName).NewBlobClient(blobName)
+}
+
+// UploadOptions 表示上传文件的选项
+type UploadOptions struct {
+	ContentType     string            // 为空时按扩展名检测，扩展名未知时根据文件内容检测
+	CacheControl    string            // 如 "public, max-age=3600"
+	ContentEncoding string            // 文件已经压缩时设置，如 "gzip"；此时 app.js.gz 按 app.js 检测类型
+	Metadata        map[string]string // 用户元数据
+	Tags            map[string]string // blob 索引标记，可以用 FindBlobsByTags 查询
+	Progress        ProgressFunc      // 上传进度回调，可为空
+}
+
+// UploadFile 上传本地文件，blobName 为空时使用文件名
+func (c *Client) UploadFile(ctx context.Context, localFilePath, containerName, blobName string) error {
+	return c.UploadFileWithOptions(ctx, localFilePath, containerName, blobName, UploadOptions{})
+}
+
+// UploadFileWithOptions 按选项上传本地文件，blobName 为空时使用文件名
+// ctx 取消时停止上传；超过 256MB 的文件分块上传，取消时丢弃已经上传但未提交的块
+func (c *Client) UploadFileWithOptions(ctx context.Context, localFilePath, containerName, blobName string, opts UploadOptions) error {
+	if blobName == "" {
+		blobName = filepath.Base(localFilePath)
+	}
+	return c.uploadFile(ctx, localFilePath, containerName, blobName, opts, nil)
+}
+
+// uploadFile 按选项上传本地文件，contentMD5 不为空时保存为 blob 的 MD5
+func (c *Client) uploadFile(ctx context.Context, localFilePath, containerName, blobName string, opts UploadOptions, contentMD5 []byte) error {
+	if err := validateTags(opts.Tags); err != nil {
+		return err
+	}
+	file, err := os.Open(localFilePath)
+	if err != nil {
+		return fmt.Errorf("无法打开文件: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("无法读取文件信息: %v", err)
+	}
+	headers, err := opts.blobHeaders(localFilePath, file)
+	if err != nil {
+		return err
+	}
+	headers.BlobContentMD5 = contentMD5
+	tracker := newProgressTracker(opts.Progress, info.Size(), 0)
+	uploadOpts := &azblob.UploadFileOptions{
+		BlockSize:   int64(4 * 1024 * 1024), // 4MB块大小
+		Concurrency: 4,                      // 并发数
+		HTTPHeaders: headers,
+		Metadata:    metadataPointers(opts.Metadata),
+		Tags:        opts.Tags,
+	}
+	if tracker != nil {
+		uploadOpts.Progress = tracker.set
+	}
+	_, err = c.client.UploadFile(ctx, containerName, blobName, file, uploadOpts)
+	if err != nil {
+		if ctx.Err() != nil {
+			// 不超过 MaxUploadBlobBytes 的文件由 SDK 一次 Put Blob 上传，不会暂存块
+			return c.uploadCanceled(ctx.Err(), containerName, blobName, info.Size() > blockblob.MaxUploadBlobBytes)
+		}
+		return fmt.Errorf("上传失败: %v", err)
+	}
+	tracker.finish()
+	return nil
+}
+
+// uploadCanceled 返回上传被取消的错误；staged 表示上传经过了暂存块，此时先丢弃未提交的块
+func (c *Client) uploadCanceled(cause error, containerName, blobName string, staged bool) error {
+	if staged && !c.discardAfterCancel(containerName, blobName) {
+		return fmt.Errorf("上传已取消: %v（未提交的块没有丢弃，将由服务在 7 天后自动清理）", cause)
+	}
+	return fmt.Errorf("上传已取消: %v", cause)
+}
+
+// DownloadOptions 表示下载文件的选项
+type DownloadOptions struct {
+	Progress ProgressFunc // 下载进度回调，可为空；设置后会先获取 blob 的大小
+}
+
+// DownloadFile 下载 blob 到本地文件，必要时创建目录；下载失败时删除不完整的文件
+func (c *Client) DownloadFile(ctx context.Context, containerName, blobName, localFilePath string) (int64, error) {
+	return c.DownloadFileWithOptions(ctx, containerName, blobName, localFilePath, DownloadOptions{})
+}
+
+// DownloadFileWithOptions 按选项下载 blob 到本地文件；下载失败或 ctx 取消时删除不完整的文件
+func (c *Client) DownloadFileWithOptions(ctx context.Context, containerName, blobName, localFilePath string, opts DownloadOptions) (int64, error) {
+	var tracker *progressTracker
+	if opts.Progress != nil {
+		props, err := c.GetProperties(ctx, containerName, blobName)
+		if err != nil {
+			return 0, fmt.Errorf("下载失败: %v", err)
+		}
+		tracker = newProgressTracker(opts.Progress, props.Size, 0)
+	}
+	if err := os.MkdirAll(filepath.Dir(localFilePath), 0755); err != nil {
+		return 0, fmt.Errorf("无法创建目录: %v", err)
+	}
+	file, err := os.Create(localFilePath)
+	if err != nil {
+		return 0, fmt.Errorf("无法创建文件: %v", err)
+	}
+	downloadOpts := &azblob.DownloadFileOptions{
+		BlockSize:   int64(4 * 1024 * 1024),
+		Concurrency: 4,
+	}
+	if tracker != nil {
+		downloadOpts.Progress = tracker.set
+	}
+	n, err := c.client.DownloadFile(ctx, containerName, blobName, file, downloadOpts)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(localFilePath)
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("下载已取消: %v", ctx.Err())
+		}
+		return 0, fmt.Errorf("下载失败: %v", err)
+	}
+	tracker.finish()
+	return n, nil
+}
+
+// BlobProperties 表示 blob 的属性
+type BlobProperties struct {
+	Name            string
+	Size            int64
+	ContentType     string
+	ContentMD5      []byte
+	CacheControl    string
+	ContentEncoding string
+	LastModified    time.Time
+	ETag            string
+	BlobType        string
+	AccessTier      string
+	Snapshot        string            // 快照时间，列出快照时才有值
+	Metadata        map[string]string // 用户元数据
+	CopyStatus      string            // 最近一次复制的状态，GetProperties 时才有值
+}
+
+// ListOptions 表示列出 blob 的参数
+type ListOptions struct {
+	Prefix           string // 只列出以该前缀开头的 blob
+	Delimiter        string // 按分隔符（通常为 "/"）分层列出，为空时平铺列出所有 blob
+	MaxResults       int32  // 每页最多返回的数量，0 时由服务决定（最多 5000）
+	Marker           string // 上一页返回的 NextMarker，为空时从头开始
+	IncludeSnapshots bool   // 同时列出快照
+	IncludeMetadata  bool   // 同时返回用户元数据
+}
+
+// ListResult 表示一页列出结果
+type ListResult struct {
+	Blobs      []BlobProperties
+	Prefixes   []string // 分层列出时的“子目录”前缀
+	NextMarker string   // 为空时表示没有更多结果
+}
+
+// ListBlobs 列出容器中的一页 blob，使用返回的 NextMarker 继续列出下一页
+func (c *Client) ListBlobs(ctx context.Context, containerName string, opts ListOptions) (*ListResult, error) {
+	include := container.ListBlobsInclude{Snapshots: opts.IncludeSnapshots, Metadata: opts.IncludeMetadata}
+	prefix, marker := optional(opts.Prefix), optional(opts.Marker)
+	var maxResults *int32
+	if opts.MaxResults > 0 {
+		maxResults = &opts.MaxResults
+	}
+
+	result := &ListResult{}
+	containerClient := c.containerClient(containerName)
+	if opts.Delimiter == "" {
+		pager := containerClient.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+			Prefix: prefix, Include: include, Marker: marker, MaxResults: maxResults,
+		})
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("列出blob失败: %v", err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			result.Blobs = append(result.Blobs, blobItemProperties(item))
+		}
+		result.NextMarker = deref(page.NextMarker)
+		return result, nil
+	}
+
+	pager := containerClient.NewListBlobsHierarchyPager(opts.Delimiter, &container.ListBlobsHierarchyOptions{
+		Prefix: prefix, Include: include, Marker: marker, MaxResults: maxResults,
+	})
+	page, err := pager.NextPage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("列出blob失败: %v", err)
+	}
+	for _, item := range page.Segment.BlobItems {
+		result.Blobs = append(result.Blobs, blobItemProperties(item))
+	}
+	for _, prefix := range page.Segment.BlobPrefixes {
+		result.Prefixes = append(result.Prefixes, deref(prefix.Name))
+	}
+	result.NextMarker = deref(page.NextMarker)
+	return result, nil
+}
+
+// ListAllBlobs 平铺列出容器中以 prefix 开头的所有 blob
+func (c *Client) ListAllBlobs(ctx context.Context, containerName, prefix string) ([]BlobProperties, error) {
+	var blobs []BlobProperties
+	opts := ListOptions{Prefix: prefix}
+	for {
+		page, err := c.ListBlobs(ctx, containerName, opts)
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, page.Blobs...)
+		if page.NextMarker == "" {
+			return blobs, nil
+		}
+		opts.Marker = page.NextMarker
+	}
+}
+
+// blobItemProperties 把列出结果中的一项转换为 BlobProperties
+func blobItemProperties(item *container.BlobItem) BlobProperties {
+	props := BlobProperties{Name: deref(item.Name), Snapshot: deref(item.Snapshot), Metadata: derefMap(item.Metadata)}
+	if p := item.Properties; p != nil {
+		props.Size = deref(p.ContentLength)
+		props.ContentType = deref(p.ContentType)
+		props.CacheControl = deref(p.CacheControl)
+		props.ContentEncoding = deref(p.ContentEncoding)
+		props.ContentMD5 = p.ContentMD5
+		props.LastModified = deref(p.LastModified)
+		if p.ETag != nil {
+			props.ETag = string(*p.ETag)
+		}
+		if p.BlobType != nil {
+			props.BlobType = string(*p.BlobType)
+		}
+		if p.AccessTier != nil {
+			props.AccessTier = string(*p.AccessTier)
+		}
+	}
+	return props
+}
+
+// DeleteBlob 删除 blob；blob 有快照时必须设置 includeSnapshots，快照会一起删除
+func (c *Client) DeleteBlob(ctx context.Context, containerName, blobName string, includeSnapshots bool) error {
+	var opts *blob.DeleteOptions
+	if includeSnapshots {
+		opts = &blob.DeleteOptions{DeleteSnapshots: to(blob.DeleteSnapshotsOptionTypeInclude)}
+	}
+	if _, err := c.blobClient(containerName, blobName).Delete(ctx, opts); err != nil {
+		return fmt.Errorf("删除blob失败: %v", err)
+	}
+	return nil
+}
+
+// Exists 判断 blob 是否存在；容器不存在时同样返回 false
+func (c *Client) Exists(ctx context.Context, containerName, blobName string) (bool, error) {
+	_, err := c.blobClient(containerName, blobName).GetProperties(ctx, nil)
+	if err == nil {
+		return true, nil
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("查询blob失败: %v", err)
+}
+
+// GetProperties 返回 blob 的属性和用户元数据
+func (c *Client) GetProperties(ctx context.Context, containerName, blobName string) (*BlobProperties, error) {
+	resp, err := c.blobClient(containerName, blobName).GetProperties(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取blob属性失败: %v", err)
+	}
+	props := &BlobProperties{
+		Name:            blobName,
+		Size:            deref(resp.ContentLength),
+		ContentType:     deref(resp.ContentType),
+		ContentMD5:      resp.ContentMD5,
+		CacheControl:    deref(resp.CacheControl),
+		ContentEncoding: deref(resp.ContentEncoding),
+		LastModified:    deref(resp.LastModified),
+		AccessTier:      deref(resp.AccessTier),
+		Metadata:        derefMap(resp.Metadata),
+	}
+	if resp.ETag != nil {
+		props.ETag = string(*resp.ETag)
+	}
+	if resp.BlobType != nil {
+		props.BlobType = string(*resp.BlobType)
+	}
+	if resp.CopyStatus != nil {
+		props.CopyStatus = string(*resp.CopyStatus)
+	}
+	return props, nil
+}
+
+// CopyBlob 在服务端把 sourceURL 复制到目标 blob，并轮询复制状态直到完成
+// 源地址不在同一账号时需要带 SAS；pollInterval 为 0 时使用默认间隔；ctx 取消时会中止尚未完成的复制
+func (c *Client) CopyBlob(ctx context.Context, sourceURL, containerName, blobName string, pollInterval time.Duration) error {
+	if pollInterval <= 0 {
+		pollInterval = defaultCopyPollInterval
+	}
+	blobClient := c.blobClient(containerName, blobName)
+	resp, err := blobClient.StartCopyFromURL(ctx, sourceURL, nil)
+	if err != nil {
+		return fmt.Errorf("开始复制失败: %w", err)
+	}
+	// 复制可能在开始时就已经完成或失败，与轮询到的状态同样检查
+	status, description := resp.CopyStatus, ""
+	for {
+		if err := copyStatusError(status, description); err != nil {
+			return err
+		}
+		if status == nil || *status != blob.CopyStatusTypePending {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			// 使用新的 context 中止复制，避免目标留下未完成的 blob
+			if resp.CopyID != nil {
+				blobClient.AbortCopyFromURL(context.Background(), *resp.CopyID, nil)
+			}
+			return fmt.Errorf("复制已取消: %w", ctx.Err())
+		case <-time.After(pollInterval):
+		}
+		props, err := blobClient.GetProperties(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("查询复制状态失败: %w", err)
+		}
+		status, description = props.CopyStatus, deref(props.CopyStatusDescription)
+	}
+}
+
+// copyStatusError 在复制状态为 failed 或 aborted 时返回包装了 ErrCopyFailed 的错误
+func copyStatusError(status *blob.CopyStatusType, description string) error {
+	if status != nil && (*status == blob.CopyStatusTypeFailed || *status == blob.CopyStatusTypeAborted) {
+		return fmt.Errorf("%w: %s %s", ErrCopyFailed, *status, description)
+	}
+	return nil
+}
+
+// isNotFound 判断错误是否表示 blob 或容器不存在
+func isNotFound(err error) bool {
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+		return true
+	}
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == 404
+}
+
+func to[T any](v T) *T {
+	return &v
+}
+
+// optional 把空字符串转换为 nil，用于可选参数
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+func derefMap(m map[string]*string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = deref(v)
+	}
+	return out
+}
@@ -0,0 +1,221 @@
+package azblob
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+func TestDownloadFile(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	uploadTestBlob(t, client, containerName, "docs/readme.md", "# 说明")
+
+	// 自动创建目录
+	out := filepath.Join(t.TempDir(), "a", "b", "readme.md")
+	n, err := client.DownloadFile(ctx, containerName, "docs/readme.md", out)
+	if err != nil {
+		t.Fatalf("下载失败: %v", err)
+	}
+	got, _ := os.ReadFile(out)
+	if string(got) != "# 说明" || n != int64(len(got)) {
+		t.Errorf("下载内容 = %q（%d 字节）", got, n)
+	}
+
+	// 下载失败时不留下不完整的文件
+	missing := filepath.Join(t.TempDir(), "missing.md")
+	if _, err := client.DownloadFile(ctx, containerName, "docs/missing.md", missing); err == nil {
+		t.Error("下载不存在的 blob 应返回错误")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("下载失败后本地文件应被删除: %v", err)
+	}
+}
+
+func TestListBlobs(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	for _, name := range []string{"a.txt", "logs/2024/01.log", "logs/2024/02.log", "logs/2025/01.log", "logs/readme.txt"} {
+		uploadTestBlob(t, client, containerName, name, name)
+	}
+
+	page, err := client.ListBlobs(ctx, containerName, ListOptions{Prefix: "logs/"})
+	if err != nil {
+		t.Fatalf("列出失败: %v", err)
+	}
+	if got := blobNames(page.Blobs); !reflect.DeepEqual(got, []string{"logs/2024/01.log", "logs/2024/02.log", "logs/2025/01.log", "logs/readme.txt"}) {
+		t.Errorf("平铺列出 = %v", got)
+	}
+	if page.Blobs[0].Size != int64(len("logs/2024/01.log")) || page.Blobs[0].LastModified.IsZero() {
+		t.Errorf("列出结果缺少属性: %+v", page.Blobs[0])
+	}
+
+	// 分层列出
+	page, err = client.ListBlobs(ctx, containerName, ListOptions{Prefix: "logs/", Delimiter: "/"})
+	if err != nil {
+		t.Fatalf("分层列出失败: %v", err)
+	}
+	if got := blobNames(page.Blobs); !reflect.DeepEqual(got, []string{"logs/readme.txt"}) {
+		t.Errorf("分层列出的 blob = %v", got)
+	}
+	if !reflect.DeepEqual(page.Prefixes, []string{"logs/2024/", "logs/2025/"}) {
+		t.Errorf("分层列出的前缀 = %v", page.Prefixes)
+	}
+
+	// 分页
+	var names []string
+	opts := ListOptions{MaxResults: 2}
+	pages := 0
+	for {
+		page, err := client.ListBlobs(ctx, containerName, opts)
+		if err != nil {
+			t.Fatalf("分页列出失败: %v", err)
+		}
+		pages++
+		if len(page.Blobs) > 2 {
+			t.Errorf("每页最多 2 个，实际 %d 个", len(page.Blobs))
+		}
+		names = append(names, blobNames(page.Blobs)...)
+		if page.NextMarker == "" {
+			break
+		}
+		opts.Marker = page.NextMarker
+	}
+	if len(names) != 5 || pages != 3 {
+		t.Errorf("分页列出 %d 页共 %d 个: %v", pages, len(names), names)
+	}
+
+	all, err := client.ListAllBlobs(ctx, containerName, "")
+	if err != nil || len(all) != 5 {
+		t.Errorf("ListAllBlobs = %d 个, %v", len(all), err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	_, err := client.client.UploadBuffer(ctx, containerName, "report.csv", []byte("a,b\n1,2\n"), &azblob.UploadBufferOptions{
+		Metadata: map[string]*string{"owner": to("alice"), "source": to("export")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props, err := client.GetProperties(ctx, containerName, "report.csv")
+	if err != nil {
+		t.Fatalf("获取属性失败: %v", err)
+	}
+	want := map[string]string{"owner": "alice", "source": "export"}
+	if !reflect.DeepEqual(lowerKeys(props.Metadata), want) {
+		t.Errorf("GetProperties 元数据 = %v", props.Metadata)
+	}
+	if props.Size != 8 || props.ETag == "" || props.BlobType != "BlockBlob" {
+		t.Errorf("属性 = %+v", props)
+	}
+
+	page, err := client.ListBlobs(ctx, containerName, ListOptions{IncludeMetadata: true})
+	if err != nil || len(page.Blobs) != 1 {
+		t.Fatalf("列出失败: %v", err)
+	}
+	if !reflect.DeepEqual(lowerKeys(page.Blobs[0].Metadata), want) {
+		t.Errorf("列出结果中的元数据 = %v", page.Blobs[0].Metadata)
+	}
+}
+
+func TestExistsAndDelete(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	uploadTestBlob(t, client, containerName, "tmp.txt", "x")
+
+	if ok, err := client.Exists(ctx, containerName, "tmp.txt"); !ok || err != nil {
+		t.Errorf("Exists(tmp.txt) = %v, %v", ok, err)
+	}
+	if ok, err := client.Exists(ctx, containerName, "nope.txt"); ok || err != nil {
+		t.Errorf("Exists(nope.txt) = %v, %v", ok, err)
+	}
+	if ok, err := client.Exists(ctx, "no-such-container", "tmp.txt"); ok || err != nil {
+		t.Errorf("容器不存在时 Exists = %v, %v", ok, err)
+	}
+
+	if err := client.DeleteBlob(ctx, containerName, "tmp.txt", true); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+	if ok, _ := client.Exists(ctx, containerName, "tmp.txt"); ok {
+		t.Error("删除后 blob 仍然存在")
+	}
+	if err := client.DeleteBlob(ctx, containerName, "tmp.txt", false); err == nil {
+		t.Error("删除不存在的 blob 应返回错误")
+	}
+}
+
+func TestCopyBlob(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	uploadTestBlob(t, client, containerName, "src/data.json", `{"ok":true}`)
+
+	if err := client.CopyBlob(ctx, client.BlobURL(containerName, "src/data.json"), containerName, "dst/data.json", 0); err != nil {
+		t.Fatalf("复制失败: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "data.json")
+	if _, err := client.DownloadFile(ctx, containerName, "dst/data.json", out); err != nil {
+		t.Fatalf("下载副本失败: %v", err)
+	}
+	if got, _ := os.ReadFile(out); string(got) != `{"ok":true}` {
+		t.Errorf("副本内容 = %s", got)
+	}
+
+	var respErr *azcore.ResponseError
+	if err := client.CopyBlob(ctx, client.BlobURL(containerName, "src/missing.json"), containerName, "dst/missing.json", 0); !errors.As(err, &respErr) {
+		t.Errorf("复制不存在的源应返回服务端错误: %v", err)
+	}
+}
+
+func TestCopyBlobFailedStatus(t *testing.T) {
+	client, containerName, srv := newFakeTestClient(t)
+	ctx := context.Background()
+	uploadTestBlob(t, client, containerName, "src.txt", "data")
+
+	// 开始复制时返回的状态就是 failed 或 aborted，不应当作成功
+	for _, status := range []string{"failed", "aborted"} {
+		srv.copyStatus = status
+		if err := client.CopyBlob(ctx, client.BlobURL(containerName, "src.txt"), containerName, "dst.txt", time.Millisecond); !errors.Is(err, ErrCopyFailed) {
+			t.Errorf("状态 %s: 错误 = %v", status, err)
+		}
+	}
+}
+
+func TestMissingContainer(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	if _, err := client.ListBlobs(ctx, "no-such-container", ListOptions{}); err == nil {
+		t.Error("列出不存在的容器应返回错误")
+	}
+	if _, err := client.GetProperties(ctx, "no-such-container", "a.txt"); err == nil {
+		t.Error("获取不存在的容器中 blob 的属性应返回错误")
+	}
+}
+
+func blobNames(blobs []BlobProperties) []string {
+	names := make([]string, len(blobs))
+	for i, b := range blobs {
+		names[i] = b.Name
+	}
+	return names
+}
+
+// lowerKeys 把元数据的键转换为小写，不同服务返回的大小写可能不同
+func lowerKeys(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package azblob
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+)
+
+// 压缩文件的扩展名及对应的 Content-Encoding；设置了 ContentEncoding 时按去掉压缩扩展名后的文件名检测类型
+var encodingExtensions = map[string]string{
+	".gz": "gzip",
+	".br": "br",
+}
+
+// DetectContentType 检测文件的 Content-Type：先按扩展名，扩展名未知时读取文件开头的 512 字节判断
+func DetectContentType(localFilePath string) (string, error) {
+	if contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(localFilePath))); contentType != "" {
+		return contentType, nil
+	}
+	file, err := os.Open(localFilePath)
+	if err != nil {
+		return "", fmt.Errorf("无法打开文件: %v", err)
+	}
+	defer file.Close()
+	return sniffContentType(file)
+}
+
+// sniffContentType 根据内容判断类型，无法判断时为 application/octet-stream
+func sniffContentType(r io.ReaderAt) (string, error) {
+	buf := make([]byte, 512)
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("读取文件失败: %v", err)
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// blobHeaders 按上传选项生成 HTTP 头；没有指定 ContentType 时自动检测
+func (opts UploadOptions) blobHeaders(localFilePath string, file *os.File) (*blob.HTTPHeaders, error) {
+	contentType := opts.ContentType
+	if contentType == "" {
+		// app.js.gz 按 app.js 检测
+		name := localFilePath
+		ext := strings.ToLower(filepath.Ext(name))
+		if encoding, ok := encodingExtensions[ext]; ok && strings.EqualFold(opts.ContentEncoding, encoding) {
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+		contentType = mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+		if contentType == "" {
+			var err error
+			if contentType, err = sniffContentType(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &blob.HTTPHeaders{
+		BlobContentType:     to(contentType),
+		BlobCacheControl:    optional(opts.CacheControl),
+		BlobContentEncoding: optional(opts.ContentEncoding),
+	}, nil
+}
+
+// metadataPointers 把元数据转换为 SDK 需要的格式
+func metadataPointers(metadata map[string]string) map[string]*string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	out := make(map[string]*string, len(metadata))
+	for k, v := range metadata {
+		out[k] = to(v)
+	}
+	return out
+}
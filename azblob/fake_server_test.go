@@ -0,0 +1,600 @@
+package azblob
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeBlob 表示内存中的一个 blob
+type fakeBlob struct {
+	data         []byte
+	headers      http.Header // Content-Type、Content-MD5 等
+	metadata     map[string]string
+	tags         map[string]string
+	lastModified time.Time
+	etag         string
+}
+
+// fakeBlobServer 是进程内的 Blob 服务模拟，实现测试用到的 REST 接口；路径格式与 Azurite 相同：/账号/容器/blob
+type fakeBlobServer struct {
+	*httptest.Server
+	mu         sync.Mutex
+	containers map[string]map[string]*fakeBlob
+	blocks     map[string]map[string][]byte // 容器/blob -> 块ID -> 未提交的数据
+	now        func() time.Time
+	putBlocks  int                       // 收到的 Put Block 请求数
+	onPutBlock func(count int)           // 保存一个块之后调用，测试用来模拟上传中断
+	failWith   func(r *http.Request) int // 返回非 0 的状态码时拒绝请求，测试用来模拟单个请求失败
+	copyStatus string                    // 复制返回的状态，为空时为 success
+}
+
+func newFakeBlobServer() *fakeBlobServer {
+	s := &fakeBlobServer{
+		containers: map[string]map[string]*fakeBlob{},
+		blocks:     map[string]map[string][]byte{},
+		now:        time.Now,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// serviceURL 返回账号级别的服务地址
+func (s *fakeBlobServer) serviceURL() string {
+	return s.URL + "/" + testAccountName + "/"
+}
+
+func (s *fakeBlobServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query := r.URL.Query()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) == 0 || parts[0] != testAccountName {
+		fakeError(w, http.StatusBadRequest, "InvalidUri")
+		return
+	}
+	var containerName, blobName string
+	if len(parts) > 1 {
+		containerName = parts[1]
+	}
+	if len(parts) > 2 {
+		blobName = parts[2]
+	}
+
+	w.Header().Set("x-ms-version", "2023-11-03")
+	if s.failWith != nil {
+		if status := s.failWith(r); status != 0 {
+			fakeError(w, status, "AuthorizationFailure")
+			return
+		}
+	}
+	switch {
+	case containerName == "" && r.Method == http.MethodGet && query.Get("comp") == "blobs":
+		s.filterBlobs(w, r, "")
+	case containerName == "":
+		fakeError(w, http.StatusBadRequest, "UnsupportedHttpVerb")
+	case blobName == "" && query.Get("restype") == "container":
+		s.handleContainer(w, r, containerName)
+	default:
+		blobs, ok := s.containers[containerName]
+		if !ok {
+			fakeError(w, http.StatusNotFound, "ContainerNotFound")
+			return
+		}
+		s.handleBlob(w, r, containerName, blobName, blobs)
+	}
+}
+
+func (s *fakeBlobServer) handleContainer(w http.ResponseWriter, r *http.Request, name string) {
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPut:
+		if _, ok := s.containers[name]; ok {
+			fakeError(w, http.StatusConflict, "ContainerAlreadyExists")
+			return
+		}
+		s.containers[name] = map[string]*fakeBlob{}
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodDelete:
+		if _, ok := s.containers[name]; !ok {
+			fakeError(w, http.StatusNotFound, "ContainerNotFound")
+			return
+		}
+		delete(s.containers, name)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodGet && query.Get("comp") == "list":
+		blobs, ok := s.containers[name]
+		if !ok {
+			fakeError(w, http.StatusNotFound, "ContainerNotFound")
+			return
+		}
+		s.listBlobs(w, r, name, blobs)
+	case r.Method == http.MethodGet && query.Get("comp") == "blobs":
+		if _, ok := s.containers[name]; !ok {
+			fakeError(w, http.StatusNotFound, "ContainerNotFound")
+			return
+		}
+		s.filterBlobs(w, r, name)
+	default:
+		fakeError(w, http.StatusBadRequest, "UnsupportedHttpVerb")
+	}
+}
+
+// fakeListResult 对应 List Blobs 的 XML 响应
+type fakeListResult struct {
+	XMLName       xml.Name `xml:"EnumerationResults"`
+	ContainerName string   `xml:"ContainerName,attr"`
+	Prefix        string   `xml:"Prefix"`
+	Marker        string   `xml:"Marker"`
+	Delimiter     string   `xml:"Delimiter,omitempty"`
+	Blobs         struct {
+		Blob []fakeListBlob `xml:"Blob"`
+		// BlobPrefix 必须在 Blob 之后
+		BlobPrefix []struct {
+			Name string `xml:"Name"`
+		} `xml:"BlobPrefix"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+type fakeListBlob struct {
+	Name       string `xml:"Name"`
+	Properties struct {
+		LastModified  string `xml:"Last-Modified"`
+		Etag          string `xml:"Etag"`
+		ContentLength int    `xml:"Content-Length"`
+		ContentType   string `xml:"Content-Type"`
+		ContentMD5    string `xml:"Content-MD5,omitempty"`
+		BlobType      string `xml:"BlobType"`
+	} `xml:"Properties"`
+	Metadata *fakeMetadata `xml:"Metadata,omitempty"`
+}
+
+type fakeMetadata struct {
+	Items []fakeMetadataItem `xml:",any"`
+}
+
+type fakeMetadataItem struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+func (s *fakeBlobServer) listBlobs(w http.ResponseWriter, r *http.Request, containerName string, blobs map[string]*fakeBlob) {
+	query := r.URL.Query()
+	prefix, delimiter, marker := query.Get("prefix"), query.Get("delimiter"), query.Get("marker")
+	maxResults := 5000
+	if v, err := strconv.Atoi(query.Get("maxresults")); err == nil && v > 0 {
+		maxResults = v
+	}
+	includeMetadata := strings.Contains(query.Get("include"), "metadata")
+
+	names := make([]string, 0, len(blobs))
+	for name := range blobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := fakeListResult{ContainerName: containerName, Prefix: prefix, Marker: marker, Delimiter: delimiter}
+	seenPrefixes := map[string]bool{}
+	count := 0
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) || name <= marker && marker != "" {
+			continue
+		}
+		if count == maxResults {
+			result.NextMarker = result.Blobs.Blob[len(result.Blobs.Blob)-1].Name
+			break
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				p := name[:len(prefix)+i+len(delimiter)]
+				if !seenPrefixes[p] {
+					seenPrefixes[p] = true
+					result.Blobs.BlobPrefix = append(result.Blobs.BlobPrefix, struct {
+						Name string `xml:"Name"`
+					}{p})
+				}
+				continue
+			}
+		}
+		b := blobs[name]
+		item := fakeListBlob{Name: name}
+		item.Properties.LastModified = b.lastModified.UTC().Format(http.TimeFormat)
+		item.Properties.Etag = b.etag
+		item.Properties.ContentLength = len(b.data)
+		item.Properties.ContentType = b.headers.Get("Content-Type")
+		item.Properties.ContentMD5 = b.headers.Get("Content-MD5")
+		item.Properties.BlobType = "BlockBlob"
+		if includeMetadata && len(b.metadata) > 0 {
+			item.Metadata = &fakeMetadata{}
+			for k, v := range b.metadata {
+				item.Metadata.Items = append(item.Metadata.Items, fakeMetadataItem{XMLName: xml.Name{Local: k}, Value: v})
+			}
+		}
+		result.Blobs.Blob = append(result.Blobs.Blob, item)
+		count++
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (s *fakeBlobServer) handleBlob(w http.ResponseWriter, r *http.Request, containerName, blobName string, blobs map[string]*fakeBlob) {
+	query := r.URL.Query()
+	key := containerName + "/" + blobName
+	b := blobs[blobName]
+	switch {
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		data, _ := io.ReadAll(r.Body)
+		if want := r.Header.Get("Content-MD5"); want != "" {
+			sum := md5.Sum(data)
+			if want != base64.StdEncoding.EncodeToString(sum[:]) {
+				fakeError(w, http.StatusBadRequest, "Md5Mismatch")
+				return
+			}
+		}
+		if s.blocks[key] == nil {
+			s.blocks[key] = map[string][]byte{}
+		}
+		s.blocks[key][query.Get("blockid")] = data
+		s.putBlocks++
+		if s.onPutBlock != nil {
+			s.onPutBlock(s.putBlocks)
+		}
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet && query.Get("comp") == "blocklist":
+		// 只返回未提交的块，测试不需要已提交的块
+		if b == nil && len(s.blocks[key]) == 0 {
+			fakeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		type block struct {
+			Name string
+			Size int
+		}
+		var result struct {
+			XMLName           xml.Name `xml:"BlockList"`
+			CommittedBlocks   struct{}
+			UncommittedBlocks []block `xml:"UncommittedBlocks>Block"`
+		}
+		for id, data := range s.blocks[key] {
+			result.UncommittedBlocks = append(result.UncommittedBlocks, block{Name: id, Size: len(data)})
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, xml.Header)
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		if r.Header.Get("If-None-Match") == "*" && b != nil {
+			fakeError(w, http.StatusConflict, "BlobAlreadyExists")
+			return
+		}
+		var list struct {
+			Blocks []struct {
+				XMLName xml.Name
+				ID      string `xml:",chardata"`
+			} `xml:",any"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&list); err != nil {
+			fakeError(w, http.StatusBadRequest, "InvalidXmlDocument")
+			return
+		}
+		var data bytes.Buffer
+		for _, block := range list.Blocks {
+			chunk, ok := s.blocks[key][block.ID]
+			if !ok {
+				fakeError(w, http.StatusBadRequest, "InvalidBlockList")
+				return
+			}
+			data.Write(chunk)
+		}
+		delete(s.blocks, key)
+		s.putBlob(w, r, blobs, blobName, data.Bytes(), false)
+	case query.Get("comp") == "tags":
+		if b == nil {
+			fakeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		if r.Method == http.MethodPut {
+			var body fakeTags
+			if err := xml.NewDecoder(r.Body).Decode(&body); err != nil {
+				fakeError(w, http.StatusBadRequest, "InvalidXmlDocument")
+				return
+			}
+			b.tags = map[string]string{}
+			for _, tag := range body.Tags {
+				b.tags[tag.Key] = tag.Value
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, xml.Header)
+		xml.NewEncoder(w).Encode(newFakeTags(b.tags, nil))
+	case r.Method == http.MethodPut && query.Get("comp") == "metadata":
+		if b == nil {
+			fakeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		b.metadata = requestMetadata(r)
+		s.touch(b)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.Header.Get("x-ms-copy-source") != "":
+		source := r.Header.Get("x-ms-copy-source")
+		src := s.lookupURL(source)
+		if src == nil {
+			fakeError(w, http.StatusNotFound, "CannotVerifyCopySource")
+			return
+		}
+		copied := &fakeBlob{data: src.data, headers: src.headers.Clone(), metadata: src.metadata}
+		blobs[blobName] = copied
+		s.touch(copied)
+		status := firstNonEmpty(s.copyStatus, "success")
+		copied.headers.Set("x-ms-copy-status", status)
+		w.Header().Set("x-ms-copy-id", "copy-"+copied.etag)
+		w.Header().Set("x-ms-copy-status", status)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && query.Get("comp") == "":
+		data, _ := io.ReadAll(r.Body)
+		s.putBlob(w, r, blobs, blobName, data, true)
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && query.Get("comp") == "":
+		if b == nil {
+			fakeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		s.writeProperties(w, b)
+		data := b.data
+		status := http.StatusOK
+		if rng := firstNonEmpty(r.Header.Get("x-ms-range"), r.Header.Get("Range")); rng != "" && r.Method == http.MethodGet {
+			var start, end int
+			if _, err := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); err == nil {
+				end = min(end, len(data)-1)
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+				data = data[start : end+1]
+				status = http.StatusPartialContent
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		if b == nil {
+			fakeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(blobs, blobName)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		fakeError(w, http.StatusBadRequest, "UnsupportedHttpVerb")
+	}
+}
+
+// putBlob 保存上传的 blob；Put Blob 会自动计算 Content-MD5，提交块列表时只保存客户端指定的值
+func (s *fakeBlobServer) putBlob(w http.ResponseWriter, r *http.Request, blobs map[string]*fakeBlob, name string, data []byte, computeMD5 bool) {
+	headers := http.Header{}
+	headers.Set("Content-Type", firstNonEmpty(r.Header.Get("x-ms-blob-content-type"), "application/octet-stream"))
+	for _, h := range []string{"Cache-Control", "Content-Encoding", "Content-Language", "Content-Disposition"} {
+		if v := r.Header.Get("x-ms-blob-" + strings.ToLower(h)); v != "" {
+			headers.Set(h, v)
+		}
+	}
+	md5Header := r.Header.Get("x-ms-blob-content-md5")
+	if md5Header == "" && computeMD5 {
+		sum := md5.Sum(data)
+		md5Header = base64.StdEncoding.EncodeToString(sum[:])
+	}
+	if md5Header != "" {
+		headers.Set("Content-MD5", md5Header)
+	}
+	b := &fakeBlob{data: data, headers: headers, metadata: requestMetadata(r)}
+	if raw := r.Header.Get("x-ms-tags"); raw != "" {
+		values, err := url.ParseQuery(raw)
+		if err != nil {
+			fakeError(w, http.StatusBadRequest, "InvalidTag")
+			return
+		}
+		b.tags = map[string]string{}
+		for k, v := range values {
+			b.tags[k] = v[0]
+		}
+	}
+	blobs[name] = b
+	s.touch(b)
+	w.Header().Set("ETag", b.etag)
+	w.Header().Set("Last-Modified", b.lastModified.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *fakeBlobServer) touch(b *fakeBlob) {
+	b.lastModified = s.now().Truncate(time.Second)
+	b.etag = fmt.Sprintf("\"0x%X\"", s.now().UnixNano())
+}
+
+func (s *fakeBlobServer) writeProperties(w http.ResponseWriter, b *fakeBlob) {
+	for k, v := range b.headers {
+		w.Header()[k] = v
+	}
+	for k, v := range b.metadata {
+		w.Header().Set("x-ms-meta-"+k, v)
+	}
+	w.Header().Set("x-ms-blob-type", "BlockBlob")
+	w.Header().Set("ETag", b.etag)
+	w.Header().Set("Last-Modified", b.lastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+}
+
+// lookupURL 按地址查找 blob，用于复制
+func (s *fakeBlobServer) lookupURL(raw string) *fakeBlob {
+	raw = strings.TrimPrefix(raw, s.URL+"/"+testAccountName+"/")
+	if i := strings.Index(raw, "?"); i >= 0 {
+		raw = raw[:i]
+	}
+	raw, err := url.PathUnescape(raw)
+	if err != nil {
+		return nil
+	}
+	containerName, blobName, ok := strings.Cut(raw, "/")
+	if !ok {
+		return nil
+	}
+	return s.containers[containerName][blobName]
+}
+
+func requestMetadata(r *http.Request) map[string]string {
+	var metadata map[string]string
+	for k, v := range r.Header {
+		if name, ok := strings.CutPrefix(strings.ToLower(k), "x-ms-meta-"); ok {
+			if metadata == nil {
+				metadata = map[string]string{}
+			}
+			metadata[name] = v[0]
+		}
+	}
+	return metadata
+}
+
+func fakeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s<Error><Code>%s</Code><Message>%s</Message></Error>", xml.Header, code, code)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// fakeTags 对应标记的 XML
+type fakeTags struct {
+	XMLName xml.Name `xml:"Tags"`
+	Tags    []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"TagSet>Tag"`
+}
+
+// newFakeTags 返回按键排序的标记，keys 不为空时只包含其中的键
+func newFakeTags(tags map[string]string, keys map[string]bool) fakeTags {
+	var result fakeTags
+	names := make([]string, 0, len(tags))
+	for k := range tags {
+		if keys == nil || keys[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		result.Tags = append(result.Tags, struct {
+			Key   string `xml:"Key"`
+			Value string `xml:"Value"`
+		}{k, tags[k]})
+	}
+	return result
+}
+
+// 标记查询中的一个条件，如 "env"='prod' 或 @container='docs'
+var fakeTagCondition = regexp.MustCompile(`^\s*(?:"([^"]+)"|(@container))\s*(=|>=|<=|>|<)\s*'([^']*)'\s*$`)
+
+// filterBlobs 实现 Find Blobs by Tags，只支持用 AND 连接的条件；containerName 为空时查询所有容器
+func (s *fakeBlobServer) filterBlobs(w http.ResponseWriter, r *http.Request, containerName string) {
+	where := r.URL.Query().Get("where")
+	type condition struct{ key, op, value string }
+	var conditions []condition
+	keys := map[string]bool{}
+	for _, part := range strings.Split(where, " AND ") {
+		m := fakeTagCondition.FindStringSubmatch(part)
+		if m == nil {
+			fakeError(w, http.StatusBadRequest, "InvalidQueryParameterValue")
+			return
+		}
+		key := m[1] + m[2]
+		conditions = append(conditions, condition{key, m[3], m[4]})
+		if m[1] != "" {
+			keys[m[1]] = true
+		}
+	}
+
+	type fakeFilterBlob struct {
+		Name          string   `xml:"Name"`
+		ContainerName string   `xml:"ContainerName"`
+		Tags          fakeTags `xml:"Tags"`
+	}
+	var result struct {
+		XMLName         xml.Name         `xml:"EnumerationResults"`
+		ServiceEndpoint string           `xml:"ServiceEndpoint,attr"`
+		Where           string           `xml:"Where"`
+		Blobs           []fakeFilterBlob `xml:"Blobs>Blob"`
+		NextMarker      string           `xml:"NextMarker"`
+	}
+	result.ServiceEndpoint = s.serviceURL()
+	result.Where = where
+
+	containerNames := make([]string, 0, len(s.containers))
+	for name := range s.containers {
+		if containerName == "" || name == containerName {
+			containerNames = append(containerNames, name)
+		}
+	}
+	sort.Strings(containerNames)
+	for _, cname := range containerNames {
+		blobs := s.containers[cname]
+		names := make([]string, 0, len(blobs))
+		for name := range blobs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	blob:
+		for _, name := range names {
+			tags := blobs[name].tags
+			for _, c := range conditions {
+				actual, ok := tags[c.key]
+				if c.key == "@container" {
+					actual, ok = cname, true
+				}
+				if !ok || !compareTag(actual, c.op, c.value) {
+					continue blob
+				}
+			}
+			tagged := newFakeTags(tags, keys)
+			result.Blobs = append(result.Blobs, fakeFilterBlob{Name: name, ContainerName: cname, Tags: tagged})
+		}
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(result)
+}
+
+// compareTag 按字符串比较标记的值，与服务的行为相同
+func compareTag(actual, op, value string) bool {
+	switch op {
+	case "=":
+		return actual == value
+	case ">":
+		return actual > value
+	case ">=":
+		return actual >= value
+	case "<":
+		return actual < value
+	default:
+		return actual <= value
+	}
+}
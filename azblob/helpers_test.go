@@ -0,0 +1,86 @@
+package azblob
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试使用的账号，与 Azurite 的默认账号相同
+const (
+	testAccountName = "devstoreaccount1"
+	testAccountKey  = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+var containerSeq atomic.Int64
+
+// newTestClient 返回连接测试服务的客户端和一个新建的空容器
+// 设置 AZURITE_BLOB_ENDPOINT（如 http://127.0.0.1:10000/devstoreaccount1）时使用本地启动的 Azurite，否则使用进程内的模拟服务
+func newTestClient(t *testing.T) (*Client, string) {
+	t.Helper()
+	endpoint := os.Getenv("AZURITE_BLOB_ENDPOINT")
+	if endpoint == "" {
+		srv := newFakeBlobServer()
+		t.Cleanup(srv.Close)
+		endpoint = srv.serviceURL()
+	}
+	return newTestClientAt(t, endpoint)
+}
+
+// newFakeTestClient 总是使用进程内的模拟服务，用于需要控制服务端行为的测试
+func newFakeTestClient(t *testing.T) (*Client, string, *fakeBlobServer) {
+	t.Helper()
+	srv := newFakeBlobServer()
+	t.Cleanup(srv.Close)
+	client, containerName := newTestClientAt(t, srv.serviceURL())
+	return client, containerName, srv
+}
+
+func newTestClientAt(t *testing.T, endpoint string) (*Client, string) {
+	t.Helper()
+	client, err := NewClientFromConfig(Config{
+		Auth:        AuthSharedKey,
+		ServiceURL:  endpoint,
+		AccountName: testAccountName,
+		AccountKey:  testAccountKey,
+	})
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+
+	containerName := fmt.Sprintf("test-%d-%d", time.Now().UnixNano(), containerSeq.Add(1))
+	ctx := context.Background()
+	if _, err := client.client.CreateContainer(ctx, containerName, nil); err != nil {
+		t.Fatalf("创建容器失败: %v", err)
+	}
+	t.Cleanup(func() {
+		client.client.DeleteContainer(context.Background(), containerName, nil)
+	})
+	return client, containerName
+}
+
+// writeTestFile 在目录中创建文件，必要时创建子目录，返回文件路径
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// uploadTestBlob 上传一段内容作为 blob
+func uploadTestBlob(t *testing.T, client *Client, containerName, blobName, content string) {
+	t.Helper()
+	p := writeTestFile(t, t.TempDir(), "blob", content)
+	if err := client.UploadFile(context.Background(), p, containerName, blobName); err != nil {
+		t.Fatalf("上传 %s 失败: %v", blobName, err)
+	}
+}
@@ -0,0 +1,96 @@
+package azblob
+
+import (
+	"sync"
+	"time"
+)
+
+// 两次进度回调之间的最小间隔，避免刷新过于频繁；传输结束时总会回调一次
+const progressInterval = 200 * time.Millisecond
+
+// Progress 表示传输进度
+type Progress struct {
+	Bytes   int64         // 已传输的字节数，续传时包括之前已上传的部分
+	Total   int64         // 总字节数
+	Elapsed time.Duration // 本次传输已用的时间
+	Rate    float64       // 本次传输的平均速度，字节/秒
+	ETA     time.Duration // 预计剩余时间，速度未知时为 0
+}
+
+// Percent 返回完成的百分比
+func (p Progress) Percent() float64 {
+	if p.Total <= 0 {
+		return 100
+	}
+	return float64(p.Bytes) * 100 / float64(p.Total)
+}
+
+// ProgressFunc 接收传输进度；同一次传输中的回调是串行的，回调中不要做耗时的操作
+type ProgressFunc func(Progress)
+
+// progressTracker 汇总并发传输的字节数，按间隔调用回调；回调为空时为 nil，所有方法都可以在 nil 上调用
+type progressTracker struct {
+	mu       sync.Mutex
+	fn       ProgressFunc
+	total    int64
+	base     int64 // 开始前已完成的字节数，不计入速度
+	bytes    int64
+	start    time.Time
+	reported time.Time
+}
+
+func newProgressTracker(fn ProgressFunc, total, done int64) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	return &progressTracker{fn: fn, total: total, base: done, bytes: done, start: time.Now()}
+}
+
+// set 设置本次传输的累计字节数，SDK 的进度回调报告的是累计值
+func (t *progressTracker) set(transferred int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bytes = t.base + transferred
+	t.report(false)
+}
+
+// add 增加已传输的字节数；重试时 n 可能为负
+func (t *progressTracker) add(n int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bytes += n
+	t.report(false)
+}
+
+// finish 报告最终进度
+func (t *progressTracker) finish() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.report(true)
+}
+
+// report 在持有锁时调用
+func (t *progressTracker) report(force bool) {
+	now := time.Now()
+	if !force && now.Sub(t.reported) < progressInterval {
+		return
+	}
+	t.reported = now
+	p := Progress{Bytes: t.bytes, Total: t.total, Elapsed: now.Sub(t.start)}
+	if seconds := p.Elapsed.Seconds(); seconds > 0 {
+		p.Rate = float64(t.bytes-t.base) / seconds
+	}
+	if p.Rate > 0 && p.Total > p.Bytes {
+		p.ETA = time.Duration(float64(p.Total-p.Bytes) / p.Rate * float64(time.Second))
+	}
+	t.fn(p)
+}
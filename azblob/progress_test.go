@@ -0,0 +1,263 @@
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+)
+
+// progressRecorder 记录所有进度回调
+type progressRecorder struct {
+	mu      sync.Mutex
+	reports []Progress
+}
+
+func (r *progressRecorder) record(p Progress) {
+	r.mu.Lock()
+	r.reports = append(r.reports, p)
+	r.mu.Unlock()
+}
+
+func (r *progressRecorder) last(t *testing.T) Progress {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.reports) == 0 {
+		t.Fatal("没有收到进度回调")
+	}
+	return r.reports[len(r.reports)-1]
+}
+
+func TestProgressTracker(t *testing.T) {
+	// 没有回调时为 nil，方法可以直接调用
+	none := newProgressTracker(nil, 100, 0)
+	if none != nil {
+		t.Fatal("没有回调时应返回 nil")
+	}
+	none.set(10)
+	none.add(10)
+	none.finish()
+
+	var rec progressRecorder
+	tracker := newProgressTracker(rec.record, 1000, 200)
+	tracker.start = time.Now().Add(-2 * time.Second)
+	tracker.set(300)
+	p := rec.last(t)
+	if p.Bytes != 500 || p.Total != 1000 || p.Percent() != 50 {
+		t.Errorf("进度 = %+v", p)
+	}
+	// 已完成的 200 字节不计入速度：2 秒传输了 300 字节
+	if p.Rate < 140 || p.Rate > 151 {
+		t.Errorf("速度 = %.1f 字节/秒", p.Rate)
+	}
+	if p.ETA < 3*time.Second || p.ETA > 4*time.Second {
+		t.Errorf("剩余时间 = %v", p.ETA)
+	}
+
+	// 间隔内的更新被合并，finish 总会报告
+	tracker.add(100)
+	tracker.add(400)
+	if n := len(rec.reports); n != 1 {
+		t.Errorf("间隔内回调了 %d 次", n)
+	}
+	tracker.finish()
+	if p := rec.last(t); p.Bytes != 1000 || p.ETA != 0 || p.Percent() != 100 {
+		t.Errorf("最终进度 = %+v", p)
+	}
+}
+
+func TestTransferProgress(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	data := testData(9*1024*1024 + 17)
+	localFilePath := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(localFilePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var up progressRecorder
+	if err := client.UploadFileWithOptions(ctx, localFilePath, containerName, "", UploadOptions{Progress: up.record}); err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	if p := up.last(t); p.Bytes != int64(len(data)) || p.Total != int64(len(data)) {
+		t.Errorf("上传的最终进度 = %+v", p)
+	}
+
+	var down progressRecorder
+	out := filepath.Join(t.TempDir(), "big.bin")
+	if _, err := client.DownloadFileWithOptions(ctx, containerName, "big.bin", out, DownloadOptions{Progress: down.record}); err != nil {
+		t.Fatalf("下载失败: %v", err)
+	}
+	if p := down.last(t); p.Bytes != int64(len(data)) || p.Total != int64(len(data)) {
+		t.Errorf("下载的最终进度 = %+v", p)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, data) {
+		t.Error("下载内容与上传内容不一致")
+	}
+}
+
+func TestResumableUploadProgress(t *testing.T) {
+	client, containerName, srv := newFakeTestClient(t)
+	data := testData(6 * testBlockSize)
+	localFilePath := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(localFilePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	interruptedUpload(t, client, srv, localFilePath, containerName, "data.bin", 3)
+
+	var rec progressRecorder
+	result, err := client.ResumableUpload(context.Background(), localFilePath, containerName, "data.bin", ResumableUploadOptions{BlockSize: testBlockSize, Progress: rec.record})
+	if err != nil {
+		t.Fatalf("续传失败: %v", err)
+	}
+	// 续传时进度从已暂存的部分开始
+	if first := rec.reports[0]; first.Bytes < int64(result.ResumedBlocks*testBlockSize) {
+		t.Errorf("第一次进度 = %+v，已暂存 %d 块", first, result.ResumedBlocks)
+	}
+	if p := rec.last(t); p.Bytes != int64(len(data)) || p.Total != int64(len(data)) {
+		t.Errorf("最终进度 = %+v", p)
+	}
+}
+
+func TestUploadCancel(t *testing.T) {
+	client, containerName := newTestClient(t)
+	localFilePath := writeTestFile(t, t.TempDir(), "report.txt", "data")
+
+	// 取消上传时丢弃未提交的块，不留下 blob
+	canceled, stop := context.WithCancel(context.Background())
+	stop()
+	if err := client.UploadFileWithOptions(canceled, localFilePath, containerName, "", UploadOptions{}); err == nil {
+		t.Fatal("取消的上传应返回错误")
+	}
+	if ok, err := client.Exists(context.Background(), containerName, "report.txt"); ok || err != nil {
+		t.Errorf("取消上传后 blob 不应存在: %v, %v", ok, err)
+	}
+
+	// 取消下载时删除不完整的文件
+	uploadTestBlob(t, client, containerName, "small.txt", "hello")
+	out := filepath.Join(t.TempDir(), "small.txt")
+	if _, err := client.DownloadFileWithOptions(canceled, containerName, "small.txt", out, DownloadOptions{}); err == nil {
+		t.Error("取消的下载应返回错误")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("取消下载后本地文件应被删除: %v", err)
+	}
+}
+
+func TestUploadCancelStagedBlocks(t *testing.T) {
+	client, containerName, srv := newFakeTestClient(t)
+	ctx := context.Background()
+	uncommitted := func(blobName string) int {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return len(srv.blocks[containerName+"/"+blobName])
+	}
+	stage := func(blobName string) {
+		t.Helper()
+		blockClient := client.containerClient(containerName).NewBlockBlobClient(blobName)
+		id := (&uploadCheckpoint{UploadID: "test"}).blockID(0)
+		if _, err := blockClient.StageBlock(ctx, id, streaming.NopCloser(bytes.NewReader([]byte("block"))), nil); err != nil {
+			t.Fatalf("暂存块失败: %v", err)
+		}
+	}
+
+	// 经过暂存块的上传被取消时丢弃块，不留下 blob
+	stage("big.bin")
+	if err := client.uploadCanceled(context.Canceled, containerName, "big.bin", true); strings.Contains(err.Error(), "7 天") {
+		t.Errorf("块应当已丢弃: %v", err)
+	}
+	if n := uncommitted("big.bin"); n != 0 {
+		t.Errorf("取消后仍有 %d 个未提交的块", n)
+	}
+	if ok, _ := client.Exists(ctx, containerName, "big.bin"); ok {
+		t.Error("取消上传后不应留下 blob")
+	}
+
+	// blob 已存在时不修改它，错误中说明块由服务过期清理
+	uploadTestBlob(t, client, containerName, "keep.txt", "keep")
+	stage("keep.txt")
+	if err := client.uploadCanceled(context.Canceled, containerName, "keep.txt", true); !strings.Contains(err.Error(), "7 天") {
+		t.Errorf("错误 = %v", err)
+	}
+	checkUploaded(t, client, containerName, "keep.txt", []byte("keep"))
+
+	// 一次 Put Blob 上传的小文件在请求过程中取消，不提交块列表，也不会短暂创建空的 blob
+	var commits int
+	canceled, cancel := context.WithCancel(ctx)
+	defer cancel()
+	srv.failWith = func(r *http.Request) int {
+		if r.URL.Query().Get("comp") == "blocklist" {
+			commits++
+		}
+		if r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/small.txt") && r.URL.Query().Get("comp") == "" {
+			cancel()
+			return http.StatusForbidden
+		}
+		return 0
+	}
+	localFilePath := writeTestFile(t, t.TempDir(), "small.txt", "data")
+	if err := client.UploadFileWithOptions(canceled, localFilePath, containerName, "", UploadOptions{}); err == nil || !strings.Contains(err.Error(), "上传已取消") || strings.Contains(err.Error(), "7 天") {
+		t.Errorf("错误 = %v", err)
+	}
+	if commits != 0 {
+		t.Errorf("取消单次上传时提交了 %d 次块列表", commits)
+	}
+	if ok, _ := client.Exists(ctx, containerName, "small.txt"); ok {
+		t.Error("取消上传后不应留下 blob")
+	}
+}
+
+func TestDiscardUncommittedBlocks(t *testing.T) {
+	client, containerName, srv := newFakeTestClient(t)
+	ctx := context.Background()
+	uncommitted := func(blobName string) int {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return len(srv.blocks[containerName+"/"+blobName])
+	}
+
+	// blob 不存在时丢弃块，不留下空的 blob
+	localFilePath := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(localFilePath, testData(6*testBlockSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	interruptedUpload(t, client, srv, localFilePath, containerName, "data.bin", 3)
+	if uncommitted("data.bin") == 0 {
+		t.Fatal("中断后服务端应有未提交的块")
+	}
+	if discarded, err := client.AbortResumableUpload(ctx, localFilePath, containerName, "", ResumableUploadOptions{}); err != nil || !discarded {
+		t.Fatalf("放弃上传失败: %v, %v", discarded, err)
+	}
+	if n := uncommitted("data.bin"); n != 0 {
+		t.Errorf("放弃后仍有 %d 个未提交的块", n)
+	}
+	if ok, _ := client.Exists(ctx, containerName, "data.bin"); ok {
+		t.Error("放弃上传后不应留下 blob")
+	}
+	if _, err := os.Stat(localFilePath + checkpointSuffix); !os.IsNotExist(err) {
+		t.Errorf("放弃上传后应删除断点文件: %v", err)
+	}
+
+	// blob 已存在时不修改它，块留给服务过期清理，断点文件仍被删除
+	uploadTestBlob(t, client, containerName, "keep.txt", "keep")
+	interruptedUpload(t, client, srv, localFilePath, containerName, "keep.txt", 3)
+	discarded, err := client.AbortResumableUpload(ctx, localFilePath, containerName, "keep.txt", ResumableUploadOptions{})
+	if err != nil || discarded {
+		t.Fatalf("放弃上传: %v, %v", discarded, err)
+	}
+	if uncommitted("keep.txt") == 0 {
+		t.Error("blob 已存在时不应丢弃未提交的块")
+	}
+	if _, err := os.Stat(localFilePath + checkpointSuffix); !os.IsNotExist(err) {
+		t.Errorf("放弃上传后应删除断点文件: %v", err)
+	}
+	checkUploaded(t, client, containerName, "keep.txt", []byte("keep"))
+}
@@ -0,0 +1,371 @@
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+)
+
+const (
+	defaultBlockSize   = 4 * 1024 * 1024
+	defaultConcurrency = 4
+	// 断点文件默认保存在本地文件旁边
+	checkpointSuffix = ".upload.json"
+	// 取消上传后丢弃未提交块的超时时间
+	discardTimeout = 30 * time.Second
+)
+
+// ResumableUploadOptions 表示可续传上传的选项
+type ResumableUploadOptions struct {
+	BlockSize      int64        // 块大小，默认 4MB，最大 4000MB；续传时必须与断点文件中的一致，否则重新上传
+	Concurrency    int          // 同时上传的块数，默认 4
+	CheckpointFile string       // 断点文件路径，默认为 <本地文件>.upload.json
+	Progress       ProgressFunc // 上传进度回调，可为空；已暂存的块计入进度，但不计入速度
+}
+
+// ResumableUploadResult 表示可续传上传的结果
+type ResumableUploadResult struct {
+	Blocks        int    // 总块数
+	ResumedBlocks int    // 续传时已在服务端、不需要重新上传的块数
+	Bytes         int64  // 本次实际上传的字节数
+	ContentMD5    []byte // 整个文件的 MD5
+}
+
+// uploadCheckpoint 记录已暂存的块；块在提交前保存在服务端，最长保留 7 天
+type uploadCheckpoint struct {
+	Container string          `json:"container"`
+	Blob      string          `json:"blob"`
+	FileSize  int64           `json:"fileSize"`
+	ModTime   time.Time       `json:"modTime"`
+	BlockSize int64           `json:"blockSize"`
+	UploadID  string          `json:"uploadId"` // 块ID的前缀，区分不同的上传
+	Staged    map[string]bool `json:"staged"`   // 已暂存的块ID
+}
+
+// blockID 返回第 i 块的块ID；同一个 blob 的块ID长度必须相同
+func (cp *uploadCheckpoint) blockID(i int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s-%06d", cp.UploadID, i)))
+}
+
+// matches 检查断点是否属于同一个文件和目标，文件修改过或块大小变化时不能续传
+func (cp *uploadCheckpoint) matches(containerName, blobName string, info os.FileInfo, blockSize int64) bool {
+	return cp.Container == containerName && cp.Blob == blobName && cp.FileSize == info.Size() &&
+		cp.ModTime.Equal(info.ModTime()) && cp.BlockSize == blockSize && cp.UploadID != ""
+}
+
+// loadCheckpoint 读取断点文件，文件不存在或已损坏时返回 nil
+func loadCheckpoint(path string) *uploadCheckpoint {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cp uploadCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil
+	}
+	return &cp
+}
+
+// save 先写临时文件再重命名，进程在写入时退出也不会留下损坏的断点文件
+func (cp *uploadCheckpoint) save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("无法保存断点文件: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("无法保存断点文件: %v", err)
+	}
+	return nil
+}
+
+// newUploadID 生成随机的上传ID
+func newUploadID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("无法生成上传ID: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ResumableUpload 分块上传本地文件，每暂存一块就记录到断点文件中；进程中断后使用相同的参数再次调用会跳过已暂存的块
+// 每块上传时由服务校验 MD5，全部暂存后提交块列表，再核对 blob 的大小和 MD5；成功后删除断点文件
+func (c *Client) ResumableUpload(ctx context.Context, localFilePath, containerName, blobName string, opts ResumableUploadOptions) (*ResumableUploadResult, error) {
+	if blobName == "" {
+		blobName = filepath.Base(localFilePath)
+	}
+	blockSize := opts.BlockSize
+	if blockSize == 0 {
+		blockSize = defaultBlockSize
+	}
+	if blockSize < 0 || blockSize > blockblob.MaxStageBlockBytes {
+		return nil, fmt.Errorf("块大小必须在 1 字节到 4000MB 之间")
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	checkpointPath := opts.CheckpointFile
+	if checkpointPath == "" {
+		checkpointPath = localFilePath + checkpointSuffix
+	}
+
+	file, err := os.Open(localFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开文件: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("无法读取文件信息: %v", err)
+	}
+	blockCount := int((info.Size() + blockSize - 1) / blockSize)
+	if blockCount > blockblob.MaxBlocks {
+		return nil, fmt.Errorf("文件需要 %d 块，超过了 %d 块的上限，请增大块大小", blockCount, blockblob.MaxBlocks)
+	}
+
+	blockClient := c.containerClient(containerName).NewBlockBlobClient(blobName)
+	cp := loadCheckpoint(checkpointPath)
+	if cp == nil || !cp.matches(containerName, blobName, info, blockSize) {
+		uploadID, err := newUploadID()
+		if err != nil {
+			return nil, err
+		}
+		cp = &uploadCheckpoint{
+			Container: containerName,
+			Blob:      blobName,
+			FileSize:  info.Size(),
+			ModTime:   info.ModTime(),
+			BlockSize: blockSize,
+			UploadID:  uploadID,
+			Staged:    map[string]bool{},
+		}
+	} else if err := c.dropExpiredBlocks(ctx, blockClient, cp); err != nil {
+		return nil, err
+	}
+	if err := cp.save(checkpointPath); err != nil {
+		return nil, err
+	}
+
+	// 整个文件的 MD5 在提交时保存到 blob 的属性中
+	sum, err := fileMD5(localFilePath)
+	if err != nil {
+		return nil, err
+	}
+	result := &ResumableUploadResult{Blocks: blockCount, ContentMD5: sum}
+	var pending []int
+	var resumedBytes int64
+	for i := 0; i < blockCount; i++ {
+		if cp.Staged[cp.blockID(i)] {
+			result.ResumedBlocks++
+			resumedBytes += min(blockSize, info.Size()-int64(i)*blockSize)
+		} else {
+			pending = append(pending, i)
+		}
+	}
+
+	// ctx 取消时保留已暂存的块和断点文件，以便续传；不再续传时调用 AbortResumableUpload 丢弃
+	tracker := newProgressTracker(opts.Progress, info.Size(), resumedBytes)
+	if err := c.stageBlocks(ctx, blockClient, file, cp, checkpointPath, pending, concurrency, result, tracker); err != nil {
+		return result, err
+	}
+
+	ids := make([]string, blockCount)
+	for i := range ids {
+		ids[i] = cp.blockID(i)
+	}
+	// 与 UploadFile 一样自动检测 Content-Type
+	headers, err := UploadOptions{}.blobHeaders(localFilePath, file)
+	if err != nil {
+		return result, err
+	}
+	headers.BlobContentMD5 = sum
+	_, err = blockClient.CommitBlockList(ctx, ids, &blockblob.CommitBlockListOptions{HTTPHeaders: headers})
+	if err != nil {
+		return result, fmt.Errorf("提交块列表失败: %v", err)
+	}
+
+	props, err := c.GetProperties(ctx, containerName, blobName)
+	if err != nil {
+		return result, fmt.Errorf("上传后校验失败: %v", err)
+	}
+	if props.Size != info.Size() || !bytes.Equal(props.ContentMD5, sum) {
+		return result, fmt.Errorf("上传后校验失败：blob 大小 %d、MD5 %x，本地文件大小 %d、MD5 %x", props.Size, props.ContentMD5, info.Size(), sum)
+	}
+	os.Remove(checkpointPath)
+	tracker.finish()
+	return result, nil
+}
+
+// AbortResumableUpload 放弃中断的可续传上传：丢弃服务端未提交的块并删除断点文件；opts 中只使用 CheckpointFile
+// blob 已存在时无法在不修改它的情况下丢弃块，discarded 返回 false，这些块由服务在 7 天后自动清理，断点文件仍会删除
+func (c *Client) AbortResumableUpload(ctx context.Context, localFilePath, containerName, blobName string, opts ResumableUploadOptions) (discarded bool, err error) {
+	if blobName == "" {
+		blobName = filepath.Base(localFilePath)
+	}
+	checkpointPath := opts.CheckpointFile
+	if checkpointPath == "" {
+		checkpointPath = localFilePath + checkpointSuffix
+	}
+	if discarded, err = c.discardUncommittedBlocks(ctx, containerName, blobName); err != nil {
+		return false, err
+	}
+	if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+		return discarded, fmt.Errorf("无法删除断点文件: %v", err)
+	}
+	return discarded, nil
+}
+
+// discardUncommittedBlocks 丢弃 blob 未提交的块，返回块是否已丢弃
+// blob 不存在时提交一个空的块列表再删除，未提交的块随之丢弃；blob 已存在时重新提交会改变它的属性，
+// 只能留给服务在 7 天后自动清理，此时返回 false
+func (c *Client) discardUncommittedBlocks(ctx context.Context, containerName, blobName string) (bool, error) {
+	blockClient := c.containerClient(containerName).NewBlockBlobClient(blobName)
+	resp, err := blockClient.CommitBlockList(ctx, nil, &blockblob.CommitBlockListOptions{
+		AccessConditions: &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: to(azcore.ETagAny)},
+		},
+	})
+	if bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ConditionNotMet) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("无法丢弃未提交的块: %v", err)
+	}
+	_, err = blockClient.Delete(ctx, &blob.DeleteOptions{
+		AccessConditions: &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfMatch: resp.ETag},
+		},
+	})
+	if err != nil && !isNotFound(err) {
+		return false, fmt.Errorf("无法丢弃未提交的块: %v", err)
+	}
+	return true, nil
+}
+
+// discardAfterCancel 在上传被取消后丢弃未提交的块，返回块是否已丢弃；原来的 ctx 已经取消，使用新的带超时的 ctx
+func (c *Client) discardAfterCancel(containerName, blobName string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), discardTimeout)
+	defer cancel()
+	discarded, _ := c.discardUncommittedBlocks(ctx, containerName, blobName)
+	return discarded
+}
+
+// dropExpiredBlocks 从断点中去掉服务端已经没有的块；未提交的块过期或 blob 被其他上传覆盖时会被丢弃
+func (c *Client) dropExpiredBlocks(ctx context.Context, blockClient *blockblob.Client, cp *uploadCheckpoint) error {
+	resp, err := blockClient.GetBlockList(ctx, blockblob.BlockListTypeUncommitted, nil)
+	if isNotFound(err) {
+		cp.Staged = map[string]bool{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("无法获取已暂存的块: %v", err)
+	}
+	onServer := map[string]bool{}
+	for _, b := range resp.UncommittedBlocks {
+		onServer[deref(b.Name)] = true
+	}
+	for id := range cp.Staged {
+		if !onServer[id] {
+			delete(cp.Staged, id)
+		}
+	}
+	return nil
+}
+
+// stageBlocks 并发暂存块，每完成一块更新断点文件；任何一块失败时停止其余的上传
+func (c *Client) stageBlocks(ctx context.Context, blockClient *blockblob.Client, file *os.File, cp *uploadCheckpoint, checkpointPath string, pending []int, concurrency int, result *ResumableUploadResult, tracker *progressTracker) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var firstErr error
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+		cancel()
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(concurrency, len(pending)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, cp.BlockSize)
+			for i := range jobs {
+				offset := int64(i) * cp.BlockSize
+				n, err := file.ReadAt(buf, offset)
+				if err != nil && err != io.EOF {
+					fail(fmt.Errorf("读取文件失败: %v", err))
+					continue
+				}
+				data := buf[:n]
+				sum := md5.Sum(data)
+				id := cp.blockID(i)
+				body := streaming.NopCloser(bytes.NewReader(data))
+				if tracker != nil {
+					var sent int64
+					body = streaming.NewRequestProgress(body, func(n int64) {
+						tracker.add(n - sent)
+						sent = n
+					})
+				}
+				_, err = blockClient.StageBlock(ctx, id, body, &blockblob.StageBlockOptions{
+					TransactionalValidation: blob.TransferValidationTypeMD5(sum[:]),
+				})
+				if err != nil {
+					fail(fmt.Errorf("上传第 %d 块失败: %v", i+1, err))
+					continue
+				}
+
+				mu.Lock()
+				cp.Staged[id] = true
+				result.Bytes += int64(n)
+				err = cp.save(checkpointPath)
+				mu.Unlock()
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, i := range pending {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
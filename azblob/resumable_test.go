@@ -0,0 +1,180 @@
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testBlockSize = 64 * 1024
+
+// testData 返回 n 字节不重复的测试数据
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7 / 5)
+	}
+	return data
+}
+
+// checkUploaded 下载 blob 并与期望的内容比较
+func checkUploaded(t *testing.T, client *Client, containerName, blobName string, want []byte) {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "download")
+	if _, err := client.DownloadFile(context.Background(), containerName, blobName, out); err != nil {
+		t.Fatalf("下载失败: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, want) {
+		t.Errorf("下载内容与上传内容不一致：%d 字节，期望 %d 字节", len(got), len(want))
+	}
+}
+
+// interruptedUpload 在服务端收到 blocks 个块后取消上传，模拟进程中断
+func interruptedUpload(t *testing.T, client *Client, srv *fakeBlobServer, localFilePath, containerName, blobName string, blocks int) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv.mu.Lock()
+	srv.putBlocks = 0
+	srv.onPutBlock = func(count int) {
+		if count == blocks {
+			cancel()
+		}
+	}
+	srv.mu.Unlock()
+
+	_, err := client.ResumableUpload(ctx, localFilePath, containerName, blobName, ResumableUploadOptions{BlockSize: testBlockSize, Concurrency: 1})
+	if err == nil {
+		t.Fatal("中断的上传应返回错误")
+	}
+	srv.mu.Lock()
+	srv.putBlocks = 0
+	srv.onPutBlock = nil
+	srv.mu.Unlock()
+	if _, err := os.Stat(localFilePath + checkpointSuffix); err != nil {
+		t.Fatalf("中断后应保留断点文件: %v", err)
+	}
+}
+
+func TestResumableUpload(t *testing.T) {
+	client, containerName := newTestClient(t)
+	data := testData(10*testBlockSize + 123)
+	localFilePath := filepath.Join(t.TempDir(), "video.bin")
+	if err := os.WriteFile(localFilePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.ResumableUpload(context.Background(), localFilePath, containerName, "", ResumableUploadOptions{BlockSize: testBlockSize, Concurrency: 3})
+	if err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	sum := md5.Sum(data)
+	if result.Blocks != 11 || result.ResumedBlocks != 0 || result.Bytes != int64(len(data)) || !bytes.Equal(result.ContentMD5, sum[:]) {
+		t.Errorf("上传结果 = %+v", result)
+	}
+	checkUploaded(t, client, containerName, "video.bin", data)
+	props, err := client.GetProperties(context.Background(), containerName, "video.bin")
+	if err != nil || !bytes.Equal(props.ContentMD5, sum[:]) {
+		t.Errorf("blob 的 MD5 = %x, %v", props.ContentMD5, err)
+	}
+	if _, err := os.Stat(localFilePath + checkpointSuffix); !os.IsNotExist(err) {
+		t.Errorf("上传成功后应删除断点文件: %v", err)
+	}
+
+	// 空文件
+	empty := writeTestFile(t, t.TempDir(), "empty.txt", "")
+	if _, err := client.ResumableUpload(context.Background(), empty, containerName, "", ResumableUploadOptions{}); err != nil {
+		t.Fatalf("上传空文件失败: %v", err)
+	}
+	checkUploaded(t, client, containerName, "empty.txt", nil)
+}
+
+func TestResumableUploadResume(t *testing.T) {
+	client, containerName, srv := newFakeTestClient(t)
+	data := testData(8 * testBlockSize)
+	localFilePath := filepath.Join(t.TempDir(), "backup.tar")
+	if err := os.WriteFile(localFilePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	interruptedUpload(t, client, srv, localFilePath, containerName, "backup.tar", 3)
+	if ok, _ := client.Exists(context.Background(), containerName, "backup.tar"); ok {
+		t.Fatal("中断的上传不应提交 blob")
+	}
+
+	result, err := client.ResumableUpload(context.Background(), localFilePath, containerName, "backup.tar", ResumableUploadOptions{BlockSize: testBlockSize})
+	if err != nil {
+		t.Fatalf("续传失败: %v", err)
+	}
+	// 第 3 块在服务端保存后才取消，客户端可能没有记录，需要重新上传
+	if result.ResumedBlocks < 2 || result.ResumedBlocks > 3 {
+		t.Errorf("续传跳过了 %d 块，期望 2 或 3 块", result.ResumedBlocks)
+	}
+	srv.mu.Lock()
+	uploaded := srv.putBlocks
+	srv.mu.Unlock()
+	if uploaded != result.Blocks-result.ResumedBlocks || result.Bytes != int64(uploaded*testBlockSize) {
+		t.Errorf("续传上传了 %d 块（%d 字节），结果 %+v", uploaded, result.Bytes, result)
+	}
+	checkUploaded(t, client, containerName, "backup.tar", data)
+}
+
+func TestResumableUploadRestart(t *testing.T) {
+	client, containerName, srv := newFakeTestClient(t)
+	localFilePath := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(localFilePath, testData(6*testBlockSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 服务端丢弃了未提交的块（过期或被其他上传覆盖）时重新上传
+	interruptedUpload(t, client, srv, localFilePath, containerName, "data.bin", 3)
+	srv.mu.Lock()
+	srv.blocks = map[string]map[string][]byte{}
+	srv.mu.Unlock()
+	result, err := client.ResumableUpload(context.Background(), localFilePath, containerName, "data.bin", ResumableUploadOptions{BlockSize: testBlockSize})
+	if err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	if result.ResumedBlocks != 0 {
+		t.Errorf("服务端没有暂存的块时跳过了 %d 块", result.ResumedBlocks)
+	}
+
+	// 文件修改后不能续传
+	interruptedUpload(t, client, srv, localFilePath, containerName, "data.bin", 3)
+	changed := bytes.Repeat([]byte("x"), 6*testBlockSize)
+	if err := os.WriteFile(localFilePath, changed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(localFilePath, later, later)
+	result, err = client.ResumableUpload(context.Background(), localFilePath, containerName, "data.bin", ResumableUploadOptions{BlockSize: testBlockSize})
+	if err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	if result.ResumedBlocks != 0 {
+		t.Errorf("文件修改后跳过了 %d 块", result.ResumedBlocks)
+	}
+	checkUploaded(t, client, containerName, "data.bin", changed)
+}
+
+func TestResumableUploadErrors(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	localFilePath := writeTestFile(t, t.TempDir(), "a.txt", string(testData(60000)))
+
+	if _, err := client.ResumableUpload(ctx, localFilePath, containerName, "", ResumableUploadOptions{BlockSize: -1}); err == nil {
+		t.Error("负的块大小应返回错误")
+	}
+	if _, err := client.ResumableUpload(ctx, localFilePath, containerName, "", ResumableUploadOptions{BlockSize: 1}); err == nil {
+		t.Error("超过 50000 块时应返回错误")
+	}
+	if _, err := client.ResumableUpload(ctx, filepath.Join(t.TempDir(), "missing"), containerName, "", ResumableUploadOptions{}); err == nil {
+		t.Error("上传不存在的文件应返回错误")
+	}
+	if _, err := client.ResumableUpload(ctx, localFilePath, "no-such-container", "", ResumableUploadOptions{}); err == nil {
+		t.Error("上传到不存在的容器应返回错误")
+	}
+}
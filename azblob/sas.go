@@ -0,0 +1,241 @@
+package azblob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+)
+
+const (
+	// 默认有效期
+	defaultSASExpiry = time.Hour
+	// 生效时间提前一段时间，避免客户端和服务端的时钟误差导致 SAS 暂时不可用
+	sasClockSkew = 5 * time.Minute
+	// 用户委托密钥最长有效 7 天，用户委托 SAS 的有效期不能超过它
+	maxUserDelegationExpiry = 7 * 24 * time.Hour
+)
+
+// SASOptions 表示签发 SAS 的选项
+//
+// blob 的权限字符：r 读取、a 追加、c 创建、w 写入、d 删除、x 删除版本、t 标记、m 移动、e 执行、i 设置不可变策略
+// 容器还可以使用 l 列出、f 按标记查找
+type SASOptions struct {
+	Permissions    string        // 权限，如 "r"、"rw"、"rl"
+	ExpiresIn      time.Duration // 有效期，为 0 时为 1 小时
+	StartTime      time.Time     // 生效时间，为空时为当前时间前 5 分钟
+	IPRange        string        // 允许访问的 IP 或 IP 范围，如 "203.0.113.5" 或 "203.0.113.0-203.0.113.255"，为空时不限制
+	AllowHTTP      bool          // 同时允许 HTTP，默认只允许 HTTPS；Azurite 等使用 HTTP 的模拟器需要设置
+	UserDelegation bool          // 签发用户委托 SAS，需要使用 Microsoft Entra 认证创建的客户端
+}
+
+// BlobSASURL 签发 blob 的 SAS，返回带 SAS 的 blob 地址
+func (c *Client) BlobSASURL(ctx context.Context, containerName, blobName string, opts SASOptions) (string, error) {
+	if blobName == "" {
+		return "", fmt.Errorf("未指定 blob 名称")
+	}
+	return c.signedURL(ctx, c.BlobURL(containerName, blobName), containerName, blobName, opts)
+}
+
+// ContainerSASURL 签发容器的 SAS，返回带 SAS 的容器地址
+func (c *Client) ContainerSASURL(ctx context.Context, containerName string, opts SASOptions) (string, error) {
+	return c.signedURL(ctx, c.containerClient(containerName).URL(), containerName, "", opts)
+}
+
+// signedURL 签发 SAS 并附加到地址上；blobName 为空时签发容器 SAS
+func (c *Client) signedURL(ctx context.Context, resourceURL, containerName, blobName string, opts SASOptions) (string, error) {
+	if containerName == "" {
+		return "", fmt.Errorf("未指定容器名称")
+	}
+	if opts.Permissions == "" {
+		return "", fmt.Errorf("未指定 SAS 权限")
+	}
+	expiresIn := opts.ExpiresIn
+	if expiresIn == 0 {
+		expiresIn = defaultSASExpiry
+	}
+	if expiresIn < 0 {
+		return "", fmt.Errorf("SAS 有效期必须大于 0")
+	}
+	start := opts.StartTime
+	if start.IsZero() {
+		start = time.Now().Add(-sasClockSkew)
+	}
+	ipRange, err := parseIPRange(opts.IPRange)
+	if err != nil {
+		return "", err
+	}
+	protocol := sas.ProtocolHTTPS
+	if opts.AllowHTTP {
+		protocol = sas.ProtocolHTTPSandHTTP
+	}
+	values := sas.BlobSignatureValues{
+		Protocol:      protocol,
+		StartTime:     start.UTC(),
+		ExpiryTime:    start.Add(expiresIn).UTC(),
+		Permissions:   opts.Permissions,
+		IPRange:       ipRange,
+		ContainerName: containerName,
+		BlobName:      blobName,
+	}
+
+	var params sas.QueryParameters
+	if opts.UserDelegation {
+		if !c.token {
+			return "", fmt.Errorf("签发用户委托 SAS 需要使用 Microsoft Entra 认证")
+		}
+		if values.ExpiryTime.Sub(time.Now()) > maxUserDelegationExpiry {
+			return "", fmt.Errorf("用户委托 SAS 的有效期不能超过 7 天")
+		}
+		// 用户委托密钥的有效期与 SAS 相同
+		credential, err := c.client.ServiceClient().GetUserDelegationCredential(ctx, service.KeyInfo{
+			Start:  to(values.StartTime.Format(sas.TimeFormat)),
+			Expiry: to(values.ExpiryTime.Format(sas.TimeFormat)),
+		}, nil)
+		if err != nil {
+			return "", fmt.Errorf("无法获取用户委托密钥: %v", err)
+		}
+		params, err = values.SignWithUserDelegation(credential)
+		if err != nil {
+			return "", fmt.Errorf("签发 SAS 失败: %v", err)
+		}
+	} else {
+		if c.sharedKey == nil {
+			return "", fmt.Errorf("签发服务 SAS 需要账号密钥，使用 Microsoft Entra 认证时请签发用户委托 SAS")
+		}
+		params, err = values.SignWithSharedKey(c.sharedKey)
+		if err != nil {
+			return "", fmt.Errorf("签发 SAS 失败: %v", err)
+		}
+	}
+	return resourceURL + "?" + params.Encode(), nil
+}
+
+// parseIPRange 解析 "a" 或 "a-b" 形式的 IP 范围
+func parseIPRange(s string) (sas.IPRange, error) {
+	if s == "" {
+		return sas.IPRange{}, nil
+	}
+	startText, endText, hasEnd := strings.Cut(s, "-")
+	start := net.ParseIP(strings.TrimSpace(startText))
+	if start == nil {
+		return sas.IPRange{}, fmt.Errorf("无效的 IP 范围: %s", s)
+	}
+	if !hasEnd {
+		return sas.IPRange{Start: start}, nil
+	}
+	end := net.ParseIP(strings.TrimSpace(endText))
+	if end == nil {
+		return sas.IPRange{}, fmt.Errorf("无效的 IP 范围: %s", s)
+	}
+	return sas.IPRange{Start: start, End: end}, nil
+}
+
+// SASInfo 表示从 SAS 地址中解析出的信息
+type SASInfo struct {
+	URL            string // 不带 SAS 的资源地址
+	AccountName    string // 使用 IP 形式的地址（如 Azurite）时为路径中的账号名称，否则为空
+	ContainerName  string
+	BlobName       string
+	Resource       string // b 为 blob，c 为容器，bs 为快照，bv 为版本；账号级 SAS 为空
+	Permissions    string
+	StartTime      time.Time // 为空时表示签发后立即生效
+	ExpiryTime     time.Time
+	IPRange        string
+	Protocol       string // https 或 https,http
+	Version        string
+	UserDelegation bool // 是否为用户委托 SAS
+}
+
+// ParseSASURL 解析带 SAS 的地址，检查签名、权限和有效期是否齐全；不检查是否过期，见 SASInfo.Validate
+func ParseSASURL(rawURL string) (*SASInfo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("无效的 SAS 地址")
+	}
+	parts, err := sas.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("无法解析 SAS 地址: %v", err)
+	}
+	params := parts.SAS
+	switch {
+	case params.Signature() == "":
+		return nil, fmt.Errorf("SAS 地址中缺少签名（sig）")
+	case params.ExpiryTime().IsZero() && params.Identifier() == "":
+		return nil, fmt.Errorf("SAS 地址中缺少过期时间（se）")
+	case params.Permissions() == "" && params.Identifier() == "":
+		return nil, fmt.Errorf("SAS 地址中缺少权限（sp）")
+	}
+
+	ipRange := params.IPRange()
+	info := &SASInfo{
+		AccountName:    parts.IPEndpointStyleInfo.AccountName,
+		ContainerName:  parts.ContainerName,
+		BlobName:       parts.BlobName,
+		Resource:       params.Resource(),
+		Permissions:    params.Permissions(),
+		StartTime:      params.StartTime(),
+		ExpiryTime:     params.ExpiryTime(),
+		IPRange:        ipRange.String(),
+		Protocol:       string(params.Protocol()),
+		Version:        params.Version(),
+		UserDelegation: params.SignedOID() != "",
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	info.URL = u.String()
+	return info, nil
+}
+
+// Validate 检查 SAS 在 now 时是否有效
+func (info *SASInfo) Validate(now time.Time) error {
+	if !info.StartTime.IsZero() && !info.ExpiryTime.IsZero() && !info.StartTime.Before(info.ExpiryTime) {
+		return fmt.Errorf("SAS 的生效时间 %s 不早于过期时间 %s", info.StartTime.Format(time.RFC3339), info.ExpiryTime.Format(time.RFC3339))
+	}
+	if !info.StartTime.IsZero() && now.Before(info.StartTime) {
+		return fmt.Errorf("SAS 尚未生效，生效时间 %s", info.StartTime.Local().Format(time.RFC3339))
+	}
+	if !info.ExpiryTime.IsZero() && !now.Before(info.ExpiryTime) {
+		return fmt.Errorf("SAS 已于 %s 过期", info.ExpiryTime.Local().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Allows 检查 SAS 是否包含所有指定的权限字符
+func (info *SASInfo) Allows(permissions string) bool {
+	for _, p := range permissions {
+		if !strings.ContainsRune(info.Permissions, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// PrintSASInfo 打印 SAS 信息
+func PrintSASInfo(w io.Writer, info *SASInfo) {
+	kind := "服务 SAS"
+	if info.UserDelegation {
+		kind = "用户委托 SAS"
+	}
+	fmt.Fprintf(w, "地址:     %s\n", info.URL)
+	fmt.Fprintf(w, "类型:     %s（资源 %s）\n", kind, info.Resource)
+	fmt.Fprintf(w, "权限:     %s\n", info.Permissions)
+	if !info.StartTime.IsZero() {
+		fmt.Fprintf(w, "生效时间: %s\n", info.StartTime.Local().Format("2006-01-02 15:04:05"))
+	}
+	if !info.ExpiryTime.IsZero() {
+		fmt.Fprintf(w, "过期时间: %s\n", info.ExpiryTime.Local().Format("2006-01-02 15:04:05"))
+	}
+	if info.IPRange != "" {
+		fmt.Fprintf(w, "IP 范围:  %s\n", info.IPRange)
+	}
+	if info.Protocol != "" {
+		fmt.Fprintf(w, "协议:     %s\n", info.Protocol)
+	}
+}
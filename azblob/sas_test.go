@@ -0,0 +1,160 @@
+package azblob
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+)
+
+func TestBlobSASURL(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	uploadTestBlob(t, client, containerName, "share/report.txt", "quarterly")
+
+	// 测试服务使用 HTTP，需要允许 HTTP
+	signed, err := client.BlobSASURL(ctx, containerName, "share/report.txt", SASOptions{Permissions: "r", ExpiresIn: 30 * time.Minute, AllowHTTP: true})
+	if err != nil {
+		t.Fatalf("签发 SAS 失败: %v", err)
+	}
+	info, err := ParseSASURL(signed)
+	if err != nil {
+		t.Fatalf("解析 SAS 失败: %v", err)
+	}
+	if info.ContainerName != containerName || info.BlobName != "share/report.txt" || info.Resource != "b" || info.Permissions != "r" {
+		t.Errorf("SAS 信息 = %+v", info)
+	}
+	if info.UserDelegation || info.Protocol != "https,http" || info.IPRange != "" {
+		t.Errorf("SAS 信息 = %+v", info)
+	}
+	if d := info.ExpiryTime.Sub(info.StartTime); d != 30*time.Minute {
+		t.Errorf("有效期 = %v", d)
+	}
+	if err := info.Validate(time.Now()); err != nil {
+		t.Errorf("新签发的 SAS 应有效: %v", err)
+	}
+	if !strings.HasPrefix(signed, info.URL+"?") {
+		t.Errorf("资源地址 = %s，SAS 地址 = %s", info.URL, signed)
+	}
+
+	// 只凭 SAS 地址就能下载
+	blobClient, err := blob.NewClientWithNoCredential(signed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := blobClient.DownloadStream(ctx, nil)
+	if err != nil {
+		t.Fatalf("使用 SAS 下载失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if got, _ := io.ReadAll(resp.Body); string(got) != "quarterly" {
+		t.Errorf("下载内容 = %q", got)
+	}
+}
+
+func TestContainerSASURL(t *testing.T) {
+	client, containerName := newTestClient(t)
+	signed, err := client.ContainerSASURL(context.Background(), containerName, SASOptions{Permissions: "lr", IPRange: "203.0.113.0-203.0.113.255"})
+	if err != nil {
+		t.Fatalf("签发 SAS 失败: %v", err)
+	}
+	info, err := ParseSASURL(signed)
+	if err != nil {
+		t.Fatalf("解析 SAS 失败: %v", err)
+	}
+	// 权限按服务要求的顺序排列
+	if info.Resource != "c" || info.Permissions != "rl" || info.BlobName != "" {
+		t.Errorf("SAS 信息 = %+v", info)
+	}
+	if info.IPRange != "203.0.113.0-203.0.113.255" || info.Protocol != "https" {
+		t.Errorf("SAS 信息 = %+v", info)
+	}
+	if !info.Allows("l") || !info.Allows("rl") || info.Allows("w") {
+		t.Errorf("Allows 与权限 %s 不符", info.Permissions)
+	}
+	if d := info.ExpiryTime.Sub(info.StartTime); d != defaultSASExpiry {
+		t.Errorf("默认有效期 = %v", d)
+	}
+}
+
+func TestSASErrors(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	cases := map[string]SASOptions{
+		"缺少权限":   {},
+		"无效的权限":  {Permissions: "rz"},
+		"负的有效期":  {Permissions: "r", ExpiresIn: -time.Minute},
+		"无效的 IP": {Permissions: "r", IPRange: "203.0.113.0-x"},
+		"账号密钥客户端签发用户委托 SAS": {Permissions: "r", UserDelegation: true},
+	}
+	for name, opts := range cases {
+		if _, err := client.BlobSASURL(ctx, containerName, "a.txt", opts); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+	if _, err := client.BlobSASURL(ctx, containerName, "", SASOptions{Permissions: "r"}); err == nil {
+		t.Error("缺少 blob 名称时应返回错误")
+	}
+
+	// 匿名客户端没有账号密钥
+	anonymous, err := NewClientFromConfig(Config{Auth: AuthAnonymous, ServiceURL: client.URL()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := anonymous.ContainerSASURL(ctx, containerName, SASOptions{Permissions: "r"}); err == nil {
+		t.Error("没有账号密钥时签发服务 SAS 应返回错误")
+	}
+}
+
+func TestConnectionStringSAS(t *testing.T) {
+	withKey, err := NewClientFromConnectionString("DefaultEndpointsProtocol=http;AccountName=" + testAccountName + ";AccountKey=" + testAccountKey + ";BlobEndpoint=http://127.0.0.1:10000/" + testAccountName + ";")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := withKey.ContainerSASURL(context.Background(), "docs", SASOptions{Permissions: "r"}); err != nil {
+		t.Errorf("包含密钥的连接字符串应能签发 SAS: %v", err)
+	}
+
+	withSAS, err := NewClientFromConnectionString("BlobEndpoint=https://example.blob.core.windows.net/;SharedAccessSignature=sv=2021-08-06&ss=b&srt=co&sp=r&se=2030-01-01T00:00:00Z&sig=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := withSAS.ContainerSASURL(context.Background(), "docs", SASOptions{Permissions: "r"}); err == nil {
+		t.Error("使用 SAS 的连接字符串不能签发服务 SAS")
+	}
+}
+
+func TestParseSASURL(t *testing.T) {
+	if _, err := ParseSASURL("https://acct.blob.core.windows.net/docs/a.txt?sv=2021-08-06&sp=r&se=2030-01-01T00:00:00Z"); err == nil {
+		t.Error("缺少签名时应返回错误")
+	}
+	if _, err := ParseSASURL("https://acct.blob.core.windows.net/docs/a.txt?sv=2021-08-06&se=2030-01-01T00:00:00Z&sig=abc"); err == nil {
+		t.Error("缺少权限时应返回错误")
+	}
+	if _, err := ParseSASURL("docs/a.txt?sig=abc"); err == nil {
+		t.Error("相对地址应返回错误")
+	}
+
+	info, err := ParseSASURL("https://acct.blob.core.windows.net/docs/a%20b.txt?sv=2021-08-06&spr=https&st=2030-01-01T00:00:00Z&se=2030-01-02T00:00:00Z&sr=b&sp=r&skoid=00000000-0000-0000-0000-000000000001&sig=abc")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if !info.UserDelegation || info.ContainerName != "docs" || info.BlobName != "a b.txt" {
+		t.Errorf("SAS 信息 = %+v", info)
+	}
+	if info.URL != "https://acct.blob.core.windows.net/docs/a%20b.txt" {
+		t.Errorf("资源地址 = %s", info.URL)
+	}
+	if err := info.Validate(time.Date(2029, 12, 31, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("生效前应返回错误")
+	}
+	if err := info.Validate(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("有效期内应有效: %v", err)
+	}
+	if err := info.Validate(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("过期后应返回错误")
+	}
+}
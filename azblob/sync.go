@@ -0,0 +1,360 @@
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 同步的默认并发数
+const defaultSyncWorkers = 4
+
+// SyncDirection 表示同步方向
+type SyncDirection int
+
+const (
+	SyncUpload   SyncDirection = iota // 本地目录 -> 容器
+	SyncDownload                      // 容器 -> 本地目录
+)
+
+// SyncAction 表示同步计划中的操作
+type SyncAction string
+
+const (
+	SyncActionUpload   SyncAction = "upload"
+	SyncActionDownload SyncAction = "download"
+	SyncActionDelete   SyncAction = "delete"
+)
+
+// SyncOptions 表示同步参数
+type SyncOptions struct {
+	Direction SyncDirection
+	LocalDir  string // 本地目录
+	Container string // 容器名称
+	Prefix    string // 容器中的“目录”前缀，为空时同步整个容器
+	Delete    bool   // 删除目标中源里没有的文件
+	DryRun    bool   // 只生成同步计划，不做任何修改
+	Workers   int    // 并发传输数，0 时使用默认值
+}
+
+// SyncOperation 表示同步计划中的一项操作
+type SyncOperation struct {
+	Action SyncAction
+	Path   string // 相对路径，使用 "/" 分隔
+	Size   int64
+	Reason string // 需要同步的原因
+}
+
+// SyncPlan 表示同步计划
+type SyncPlan struct {
+	Operations []SyncOperation
+	Unchanged  int // 未变化而跳过的文件数
+}
+
+// SyncResult 表示同步结果
+type SyncResult struct {
+	Plan      *SyncPlan
+	Completed int   // 成功完成的操作数
+	Bytes     int64 // 传输的字节数
+	Failed    []SyncFailure
+}
+
+// SyncFailure 表示失败的操作
+type SyncFailure struct {
+	Operation SyncOperation
+	Err       error
+}
+
+// syncEntry 表示同步时比较的一个文件
+type syncEntry struct {
+	size    int64
+	modTime time.Time
+	md5     []byte // 只有 blob 才可能有，本地文件按需计算
+}
+
+// Sync 按 rsync 的方式在本地目录和容器之间同步
+// 大小不同的文件直接传输；大小相同时，blob 有 Content-MD5 就比较 MD5，否则比较修改时间，源较新时才传输
+// DryRun 时只返回计划；执行时单个文件失败不会中断其他文件，失败项记录在 Failed 中
+func (c *Client) Sync(ctx context.Context, opts SyncOptions) (*SyncResult, error) {
+	plan, err := c.PlanSync(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.ApplySync(ctx, opts, plan)
+}
+
+// ApplySync 并发执行 PlanSync 生成的计划，用于先展示计划、确认后再执行
+func (c *Client) ApplySync(ctx context.Context, opts SyncOptions, plan *SyncPlan) (*SyncResult, error) {
+	result := &SyncResult{Plan: plan}
+	if opts.DryRun || len(plan.Operations) == 0 {
+		return result, nil
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultSyncWorkers
+	}
+
+	var mu sync.Mutex
+	jobs := make(chan SyncOperation)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(plan.Operations)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for op := range jobs {
+				n, err := c.runSyncOperation(ctx, opts, op)
+				mu.Lock()
+				if err != nil {
+					result.Failed = append(result.Failed, SyncFailure{Operation: op, Err: err})
+				} else {
+					result.Completed++
+					result.Bytes += n
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, op := range plan.Operations {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- op
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return result, fmt.Errorf("同步已取消: %v", err)
+	}
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("%d 个文件同步失败", len(result.Failed))
+	}
+	return result, nil
+}
+
+// PlanSync 比较本地目录和容器，返回需要执行的操作，不做任何修改
+func (c *Client) PlanSync(ctx context.Context, opts SyncOptions) (*SyncPlan, error) {
+	if opts.LocalDir == "" || opts.Container == "" {
+		return nil, fmt.Errorf("必须指定本地目录和容器")
+	}
+	local, err := scanLocalDir(opts.LocalDir)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := c.scanContainer(ctx, opts.Container, syncPrefix(opts.Prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	source, target := local, remote
+	transfer := SyncActionUpload
+	if opts.Direction == SyncDownload {
+		source, target = remote, local
+		transfer = SyncActionDownload
+	}
+
+	plan := &SyncPlan{}
+	for _, rel := range sortedKeys(source) {
+		src := source[rel]
+		if _, ok := target[rel]; !ok {
+			plan.Operations = append(plan.Operations, SyncOperation{transfer, rel, src.size, "目标不存在"})
+			continue
+		}
+		reason, err := syncReason(opts, rel, local[rel], remote[rel])
+		if err != nil {
+			return nil, err
+		}
+		if reason == "" {
+			plan.Unchanged++
+			continue
+		}
+		plan.Operations = append(plan.Operations, SyncOperation{transfer, rel, src.size, reason})
+	}
+	if opts.Delete {
+		for _, rel := range sortedKeys(target) {
+			if _, ok := source[rel]; !ok {
+				plan.Operations = append(plan.Operations, SyncOperation{SyncActionDelete, rel, target[rel].size, "源中不存在"})
+			}
+		}
+	}
+	return plan, nil
+}
+
+// syncReason 比较两端都存在的文件，返回需要传输的原因，未变化时返回空字符串
+func syncReason(opts SyncOptions, rel string, local, remote syncEntry) (string, error) {
+	if local.size != remote.size {
+		return "大小不同", nil
+	}
+	if len(remote.md5) > 0 {
+		sum, err := fileMD5(filepath.Join(opts.LocalDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return "", err
+		}
+		if bytes.Equal(sum, remote.md5) {
+			return "", nil
+		}
+		return "MD5不同", nil
+	}
+	// 没有 MD5 时按修改时间判断，精确到秒
+	localTime, remoteTime := local.modTime.Truncate(time.Second), remote.modTime.Truncate(time.Second)
+	if opts.Direction == SyncUpload && localTime.After(remoteTime) {
+		return "本地较新", nil
+	}
+	if opts.Direction == SyncDownload && remoteTime.After(localTime) {
+		return "blob较新", nil
+	}
+	return "", nil
+}
+
+// runSyncOperation 执行一项同步操作，返回传输的字节数
+func (c *Client) runSyncOperation(ctx context.Context, opts SyncOptions, op SyncOperation) (int64, error) {
+	localPath := filepath.Join(opts.LocalDir, filepath.FromSlash(op.Path))
+	blobName := syncPrefix(opts.Prefix) + op.Path
+	switch op.Action {
+	case SyncActionUpload:
+		// 上传时写入 Content-MD5，下次同步可以按内容比较
+		sum, err := fileMD5(localPath)
+		if err != nil {
+			return 0, err
+		}
+		if err := c.uploadFile(ctx, localPath, opts.Container, blobName, UploadOptions{}, sum); err != nil {
+			return 0, err
+		}
+		return op.Size, nil
+	case SyncActionDownload:
+		n, err := c.DownloadFile(ctx, opts.Container, blobName, localPath)
+		if err != nil {
+			return 0, err
+		}
+		// 本地修改时间设为 blob 的修改时间，下次同步时不会被当作较新的文件；设置失败时记为失败，避免下次被误判为本地较新
+		props, err := c.GetProperties(ctx, opts.Container, blobName)
+		if err != nil {
+			return n, fmt.Errorf("已下载，但无法获取 blob 的修改时间: %v", err)
+		}
+		if err := os.Chtimes(localPath, props.LastModified, props.LastModified); err != nil {
+			return n, fmt.Errorf("已下载，但无法设置本地文件的修改时间: %v", err)
+		}
+		return n, nil
+	case SyncActionDelete:
+		if opts.Direction == SyncUpload {
+			return 0, c.DeleteBlob(ctx, opts.Container, blobName, true)
+		}
+		if err := os.Remove(localPath); err != nil {
+			return 0, fmt.Errorf("删除本地文件失败: %v", err)
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("未知的同步操作: %s", op.Action)
+}
+
+// scanLocalDir 递归列出目录中的所有普通文件，键为使用 "/" 分隔的相对路径；目录不存在时返回空结果
+func scanLocalDir(dir string) (map[string]syncEntry, error) {
+	entries := map[string]syncEntry{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		entries[filepath.ToSlash(rel)] = syncEntry{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("扫描本地目录失败: %v", err)
+	}
+	return entries, nil
+}
+
+// scanContainer 列出前缀下的所有 blob，键为去掉前缀后的相对路径
+// 目录占位 blob（以 "/" 结尾）和会逃出本地目录的名称会被忽略
+func (c *Client) scanContainer(ctx context.Context, containerName, prefix string) (map[string]syncEntry, error) {
+	blobs, err := c.ListAllBlobs(ctx, containerName, prefix)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]syncEntry, len(blobs))
+	for _, b := range blobs {
+		rel := strings.TrimPrefix(b.Name, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") || !safeRelativePath(rel) {
+			continue
+		}
+		entries[rel] = syncEntry{size: b.Size, modTime: b.LastModified, md5: b.ContentMD5}
+	}
+	return entries, nil
+}
+
+// safeRelativePath 判断 blob 名称能否安全地作为本地相对路径
+func safeRelativePath(rel string) bool {
+	if strings.HasPrefix(rel, "/") || strings.Contains(rel, "\\") {
+		return false
+	}
+	cleaned := path.Clean(rel)
+	return cleaned == rel && cleaned != ".." && !strings.HasPrefix(cleaned, "../")
+}
+
+// syncPrefix 规范化前缀，非空时以 "/" 结尾
+func syncPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// fileMD5 计算文件的 MD5
+func fileMD5(p string) ([]byte, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开文件: %v", err)
+	}
+	defer file.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	return h.Sum(nil), nil
+}
+
+func sortedKeys(m map[string]syncEntry) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PrintSyncPlan 打印同步计划
+func PrintSyncPlan(w io.Writer, plan *SyncPlan) {
+	var total int64
+	for _, op := range plan.Operations {
+		fmt.Fprintf(w, "%-8s %s (%d 字节，%s)\n", op.Action, op.Path, op.Size, op.Reason)
+		if op.Action != SyncActionDelete {
+			total += op.Size
+		}
+	}
+	fmt.Fprintf(w, "共 %d 项操作，需传输 %d 字节，%d 个文件未变化\n", len(plan.Operations), total, plan.Unchanged)
+}
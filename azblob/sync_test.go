@@ -0,0 +1,162 @@
+package azblob
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// planActions 把同步计划转换为 "操作 路径" 列表，便于比较
+func planActions(plan *SyncPlan) []string {
+	var actions []string
+	for _, op := range plan.Operations {
+		actions = append(actions, string(op.Action)+" "+op.Path)
+	}
+	return actions
+}
+
+func TestSyncUpload(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "index.html", "<h1>hi</h1>")
+	writeTestFile(t, dir, "css/site.css", "body{}")
+	opts := SyncOptions{LocalDir: dir, Container: containerName, Prefix: "site"}
+
+	// DryRun 不做修改
+	dry := opts
+	dry.DryRun = true
+	result, err := client.Sync(ctx, dry)
+	if err != nil {
+		t.Fatalf("DryRun 失败: %v", err)
+	}
+	if got := planActions(result.Plan); !reflect.DeepEqual(got, []string{"upload css/site.css", "upload index.html"}) {
+		t.Errorf("计划 = %v", got)
+	}
+	if blobs, _ := client.ListAllBlobs(ctx, containerName, ""); len(blobs) != 0 {
+		t.Errorf("DryRun 后容器中有 %d 个 blob", len(blobs))
+	}
+
+	result, err = client.Sync(ctx, opts)
+	if err != nil || result.Completed != 2 {
+		t.Fatalf("同步失败: %v, 完成 %d 项", err, result.Completed)
+	}
+	if ok, _ := client.Exists(ctx, containerName, "site/css/site.css"); !ok {
+		t.Error("site/css/site.css 没有上传")
+	}
+
+	// 未变化的文件跳过
+	plan, err := client.PlanSync(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Operations) != 0 || plan.Unchanged != 2 {
+		t.Errorf("再次同步的计划 = %v，未变化 %d 个", planActions(plan), plan.Unchanged)
+	}
+
+	// 大小相同但内容不同时按 MD5 判断；删除多余的 blob
+	writeTestFile(t, dir, "index.html", "<h1>HI</h1>")
+	os.Remove(filepath.Join(dir, "css/site.css"))
+	opts.Delete = true
+	result, err = client.Sync(ctx, opts)
+	if err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	if got := planActions(result.Plan); !reflect.DeepEqual(got, []string{"upload index.html", "delete css/site.css"}) {
+		t.Errorf("计划 = %v", got)
+	}
+	if ok, _ := client.Exists(ctx, containerName, "site/css/site.css"); ok {
+		t.Error("多余的 blob 没有删除")
+	}
+}
+
+func TestSyncDownload(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	uploadTestBlob(t, client, containerName, "data/a.txt", "aaa")
+	uploadTestBlob(t, client, containerName, "data/sub/b.txt", "bbbb")
+	uploadTestBlob(t, client, containerName, "other/c.txt", "c")
+
+	dir := t.TempDir()
+	writeTestFile(t, dir, "stale.txt", "old")
+	opts := SyncOptions{Direction: SyncDownload, LocalDir: dir, Container: containerName, Prefix: "data/", Delete: true}
+	result, err := client.Sync(ctx, opts)
+	if err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	if got := planActions(result.Plan); !reflect.DeepEqual(got, []string{"download a.txt", "download sub/b.txt", "delete stale.txt"}) {
+		t.Errorf("计划 = %v", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "sub", "b.txt")); string(got) != "bbbb" {
+		t.Errorf("sub/b.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stale.txt")); !os.IsNotExist(err) {
+		t.Error("多余的本地文件没有删除")
+	}
+
+	plan, err := client.PlanSync(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Operations) != 0 || plan.Unchanged != 2 {
+		t.Errorf("再次同步的计划 = %v，未变化 %d 个", planActions(plan), plan.Unchanged)
+	}
+}
+
+func TestSyncDownloadReportsModTimeErrors(t *testing.T) {
+	client, containerName, srv := newFakeTestClient(t)
+	ctx := context.Background()
+	uploadTestBlob(t, client, containerName, "a.txt", "aaa")
+	uploadTestBlob(t, client, containerName, "b.txt", "bbb")
+	props, err := client.GetProperties(ctx, containerName, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 下载 b.txt 之后获取属性失败：文件已下载，但无法设置修改时间，记为失败
+	heads := 0
+	srv.mu.Lock()
+	srv.failWith = func(r *http.Request) int {
+		if r.Method == http.MethodHead && strings.HasSuffix(r.URL.Path, "/b.txt") {
+			if heads++; heads > 1 {
+				return http.StatusForbidden
+			}
+		}
+		return 0
+	}
+	srv.mu.Unlock()
+	dir := t.TempDir()
+	result, err := client.Sync(ctx, SyncOptions{Direction: SyncDownload, LocalDir: dir, Container: containerName})
+	if err == nil || result.Completed != 1 || len(result.Failed) != 1 || result.Failed[0].Operation.Path != "b.txt" ||
+		!strings.Contains(result.Failed[0].Err.Error(), "修改时间") {
+		t.Fatalf("同步结果 = %+v, %v", result, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "a.txt"))
+	if err != nil || !info.ModTime().Equal(props.LastModified) {
+		t.Errorf("a.txt 的修改时间 = %v，期望 %v", info.ModTime(), props.LastModified)
+	}
+}
+
+func TestSafeRelativePath(t *testing.T) {
+	for rel, want := range map[string]bool{
+		"a.txt":       true,
+		"dir/a.txt":   true,
+		"../a.txt":    false,
+		"dir/../../a": false,
+		"/etc/passwd": false,
+		"dir\\a.txt":  false,
+		"dir//a.txt":  false,
+		"./dir/a.txt": false,
+		"dir/./a.txt": false,
+		"..":          false,
+		"..a/b.txt":   true,
+	} {
+		if got := safeRelativePath(rel); got != want {
+			t.Errorf("safeRelativePath(%q) = %v, 期望 %v", rel, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package azblob
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+)
+
+// blob 索引标记的限制
+const (
+	maxTags        = 10
+	maxTagKeyLen   = 128
+	maxTagValueLen = 256
+)
+
+// TaggedBlob 表示按标记查询到的 blob
+type TaggedBlob struct {
+	Container string
+	Name      string
+	Tags      map[string]string // 查询条件中用到的标记
+}
+
+// validateTags 检查标记是否符合服务的要求：最多 10 个，键 1-128 个字符，值最多 256 个字符，
+// 只能包含字母、数字、空格和 + - . / : = _
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("标记最多 %d 个，实际 %d 个", maxTags, len(tags))
+	}
+	for k, v := range tags {
+		if len(k) == 0 || len(k) > maxTagKeyLen {
+			return fmt.Errorf("标记键 %q 的长度必须在 1 到 %d 之间", k, maxTagKeyLen)
+		}
+		if len(v) > maxTagValueLen {
+			return fmt.Errorf("标记 %s 的值超过 %d 个字符", k, maxTagValueLen)
+		}
+		if !validTagText(k) || !validTagText(v) {
+			return fmt.Errorf("标记 %s=%s 包含不允许的字符", k, v)
+		}
+	}
+	return nil
+}
+
+func validTagText(s string) bool {
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune(" +-./:=_", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// TagQuery 把标记转换为要求全部相等的查询表达式，如 "env"='prod' AND "team"='data'
+func TagQuery(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	conditions := make([]string, len(keys))
+	for i, k := range keys {
+		conditions[i] = fmt.Sprintf(`"%s"='%s'`, k, tags[k])
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// GetTags 返回 blob 的索引标记
+func (c *Client) GetTags(ctx context.Context, containerName, blobName string) (map[string]string, error) {
+	resp, err := c.blobClient(containerName, blobName).GetTags(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取标记失败: %v", err)
+	}
+	tags := map[string]string{}
+	for _, tag := range resp.BlobTagSet {
+		tags[deref(tag.Key)] = deref(tag.Value)
+	}
+	return tags, nil
+}
+
+// SetTags 替换 blob 的全部索引标记，tags 为空时清除标记
+func (c *Client) SetTags(ctx context.Context, containerName, blobName string, tags map[string]string) error {
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+	if _, err := c.blobClient(containerName, blobName).SetTags(ctx, tags, nil); err != nil {
+		return fmt.Errorf("设置标记失败: %v", err)
+	}
+	return nil
+}
+
+// FindBlobsByTags 按索引标记查询 blob，返回所有结果；containerName 为空时在整个账号中查询
+// where 的写法如 "project"='apollo' AND "year">='2024'，可以用 TagQuery 生成；新设置的标记可能要稍后才能查询到
+func (c *Client) FindBlobsByTags(ctx context.Context, containerName, where string) ([]TaggedBlob, error) {
+	if where == "" {
+		return nil, fmt.Errorf("未指定查询条件")
+	}
+	var blobs []TaggedBlob
+	var marker *string
+	for {
+		var segment *service.FilterBlobSegment
+		if containerName == "" {
+			resp, err := c.client.ServiceClient().FilterBlobs(ctx, where, &service.FilterBlobsOptions{Marker: marker})
+			if err != nil {
+				return nil, fmt.Errorf("按标记查询失败: %v", err)
+			}
+			segment = &resp.FilterBlobSegment
+		} else {
+			resp, err := c.containerClient(containerName).FilterBlobs(ctx, where, &container.FilterBlobsOptions{Marker: marker})
+			if err != nil {
+				return nil, fmt.Errorf("按标记查询失败: %v", err)
+			}
+			segment = &resp.FilterBlobSegment
+		}
+
+		for _, item := range segment.Blobs {
+			b := TaggedBlob{Container: deref(item.ContainerName), Name: deref(item.Name), Tags: map[string]string{}}
+			if item.Tags != nil {
+				for _, tag := range item.Tags.BlobTagSet {
+					b.Tags[deref(tag.Key)] = deref(tag.Value)
+				}
+			}
+			blobs = append(blobs, b)
+		}
+		if deref(segment.NextMarker) == "" {
+			return blobs, nil
+		}
+		marker = segment.NextMarker
+	}
+}
@@ -0,0 +1,174 @@
+package azblob
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name, content, want string
+	}{
+		{"data.json", `{"a":1}`, "application/json"},
+		{"INDEX.HTML", "<p>hi</p>", "text/html; charset=utf-8"},
+		{"logo.unknownext", "\x89PNG\r\n\x1a\n0000", "image/png"},
+		{"README", "plain text", "text/plain; charset=utf-8"},
+	}
+	for _, c := range cases {
+		got, err := DetectContentType(writeTestFile(t, dir, c.name, c.content))
+		if err != nil || got != c.want {
+			t.Errorf("DetectContentType(%s) = %q, %v，期望 %q", c.name, got, err, c.want)
+		}
+	}
+}
+
+func TestUploadOptions(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// 自动检测类型，设置缓存、元数据和标记
+	err := client.UploadFileWithOptions(ctx, writeTestFile(t, dir, "report.json", `{"ok":true}`), containerName, "", UploadOptions{
+		CacheControl: "public, max-age=60",
+		Metadata:     map[string]string{"owner": "alice"},
+		Tags:         map[string]string{"project": "apollo", "stage": "final"},
+	})
+	if err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	props, err := client.GetProperties(ctx, containerName, "report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props.ContentType != "application/json" || props.CacheControl != "public, max-age=60" {
+		t.Errorf("属性 = %+v", props)
+	}
+	if !reflect.DeepEqual(lowerKeys(props.Metadata), map[string]string{"owner": "alice"}) {
+		t.Errorf("元数据 = %v", props.Metadata)
+	}
+	tags, err := client.GetTags(ctx, containerName, "report.json")
+	if err != nil || !reflect.DeepEqual(tags, map[string]string{"project": "apollo", "stage": "final"}) {
+		t.Errorf("标记 = %v, %v", tags, err)
+	}
+
+	// 已压缩的文件按去掉压缩扩展名后的文件名检测类型
+	for _, c := range []struct{ name, encoding, want string }{
+		{"bundle.js.gz", "gzip", "text/javascript; charset=utf-8"},
+		{"site.css.br", "br", "text/css; charset=utf-8"},
+	} {
+		err = client.UploadFileWithOptions(ctx, writeTestFile(t, dir, c.name, "compressed"), containerName, "", UploadOptions{ContentEncoding: c.encoding})
+		if err != nil {
+			t.Fatalf("上传失败: %v", err)
+		}
+		props, err = client.GetProperties(ctx, containerName, c.name)
+		if err != nil || props.ContentType != c.want || props.ContentEncoding != c.encoding {
+			t.Errorf("%s 的属性 = %+v, %v", c.name, props, err)
+		}
+	}
+
+	// 显式指定的类型优先
+	err = client.UploadFileWithOptions(ctx, writeTestFile(t, dir, "notes.txt", "x"), containerName, "", UploadOptions{ContentType: "text/markdown"})
+	if err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	if props, _ := client.GetProperties(ctx, containerName, "notes.txt"); props == nil || props.ContentType != "text/markdown" {
+		t.Errorf("类型 = %+v", props)
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	if err := validateTags(map[string]string{"env": "prod", "path": "a/b:c=d_e-f.g+h i"}); err != nil {
+		t.Errorf("合法的标记返回错误: %v", err)
+	}
+	tooMany := map[string]string{}
+	for i := 0; i < 11; i++ {
+		tooMany[fmt.Sprintf("k%d", i)] = "v"
+	}
+	invalid := map[string]map[string]string{
+		"超过 10 个": tooMany,
+		"空的键":     {"": "v"},
+		"键过长":     {strings.Repeat("k", 129): "v"},
+		"值过长":     {"k": strings.Repeat("v", 257)},
+		"不允许的字符":  {"owner": "alice@example.com"},
+	}
+	for name, tags := range invalid {
+		if err := validateTags(tags); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+
+	client, containerName := newTestClient(t)
+	p := writeTestFile(t, t.TempDir(), "a.txt", "a")
+	if err := client.UploadFileWithOptions(context.Background(), p, containerName, "", UploadOptions{Tags: map[string]string{"owner": "a@b"}}); err == nil {
+		t.Error("标记不合法时上传应返回错误")
+	}
+}
+
+func TestTagQuery(t *testing.T) {
+	got := TagQuery(map[string]string{"team": "data", "env": "prod"})
+	if got != `"env"='prod' AND "team"='data'` {
+		t.Errorf("TagQuery = %s", got)
+	}
+}
+
+func TestFindBlobsByTags(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	// 使用容器名称作为标记值，Azurite 中其他测试留下的 blob 不会被查询到
+	run := strings.ReplaceAll(containerName, "-", "")
+	upload := func(containerName, blobName string, tags map[string]string) {
+		t.Helper()
+		err := client.UploadFileWithOptions(ctx, writeTestFile(t, dir, "f", blobName), containerName, blobName, UploadOptions{Tags: tags})
+		if err != nil {
+			t.Fatalf("上传 %s 失败: %v", blobName, err)
+		}
+	}
+	other := containerName + "-b"
+	if _, err := client.client.CreateContainer(ctx, other, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.client.DeleteContainer(context.Background(), other, nil) })
+
+	upload(containerName, "2023.csv", map[string]string{"run": run, "year": "2023"})
+	upload(containerName, "2024.csv", map[string]string{"run": run, "year": "2024", "status": "done"})
+	upload(other, "2025.csv", map[string]string{"run": run, "year": "2025"})
+	upload(containerName, "untagged.csv", nil)
+
+	found, err := client.FindBlobsByTags(ctx, containerName, TagQuery(map[string]string{"run": run}))
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if got := taggedNames(found); !reflect.DeepEqual(got, []string{"2023.csv", "2024.csv"}) {
+		t.Errorf("容器内查询 = %v", got)
+	}
+
+	found, err = client.FindBlobsByTags(ctx, "", fmt.Sprintf(`"run"='%s' AND "year">='2024'`, run))
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if got := taggedNames(found); !reflect.DeepEqual(got, []string{"2024.csv", "2025.csv"}) {
+		t.Errorf("账号内查询 = %v", got)
+	}
+	for _, b := range found {
+		if b.Name == "2025.csv" && (b.Container != other || b.Tags["year"] != "2025") {
+			t.Errorf("查询结果 = %+v", b)
+		}
+	}
+
+	if _, err := client.FindBlobsByTags(ctx, containerName, ""); err == nil {
+		t.Error("查询条件为空时应返回错误")
+	}
+}
+
+func taggedNames(blobs []TaggedBlob) []string {
+	names := make([]string, len(blobs))
+	for i, b := range blobs {
+		names[i] = b.Name
+	}
+	return names
+}
@@ -1,30 +1,65 @@
 package azblob
 
 import (
-	"fmt"
-	"log"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
-func TestUploadFileToAzure(t *testing.T) {
-	// Azure存储账号信息
-	// 这些凭证可以在Azure门户网站中找到:
-	// 1. 登录Azure门户(https://portal.azure.com)
-	// 2. 导航到你的存储账号
-	// 3. 在"访问密钥"部分可以找到存储账号名称和密钥
-	// 4. 或者在"共享访问签名"部分创建SAS令牌
-	accountName := ""   // 存储账号(可以理解为数据库)名称
-	accountKey := ""    // 密钥
-	containerName := "" // 容器(可以理解为数据库中的表)名称
+func TestUploadFile(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
 
-	// 本地文件路径
-	localFilePath := "learn.txt"
-
-	// 调用azblob包中的上传函数
-	err := UploadFileToAzure(localFilePath, accountName, accountKey, containerName)
+	// blob 名称为空时使用文件名
+	localFilePath := writeTestFile(t, t.TempDir(), "learn.txt", "hello azure blob")
+	if err := client.UploadFile(ctx, localFilePath, containerName, ""); err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	props, err := client.GetProperties(ctx, containerName, "learn.txt")
 	if err != nil {
-		log.Fatalf("上传失败: %v", err)
+		t.Fatalf("获取属性失败: %v", err)
+	}
+	if props.Size != int64(len("hello azure blob")) {
+		t.Errorf("大小 = %d, 期望 %d", props.Size, len("hello azure blob"))
 	}
 
-	fmt.Println("文件上传完成")
+	// 超过块大小但不超过 MaxUploadBlobBytes 的文件，SDK 仍然一次 Put Blob 上传
+	large := bytes.Repeat([]byte("0123456789abcdef"), 5*1024*1024/16+3)
+	largePath := filepath.Join(t.TempDir(), "large.bin")
+	if err := os.WriteFile(largePath, large, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UploadFile(ctx, largePath, containerName, "dir/large.bin"); err != nil {
+		t.Fatalf("上传大文件失败: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "large.bin")
+	if _, err := client.DownloadFile(ctx, containerName, "dir/large.bin", out); err != nil {
+		t.Fatalf("下载失败: %v", err)
+	}
+	got, _ := os.ReadFile(out)
+	if !bytes.Equal(got, large) {
+		t.Errorf("下载内容与上传内容不一致：%d 字节，期望 %d 字节", len(got), len(large))
+	}
+}
+
+func TestUploadFileErrors(t *testing.T) {
+	client, containerName := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.UploadFile(ctx, filepath.Join(t.TempDir(), "missing.txt"), containerName, ""); err == nil {
+		t.Error("上传不存在的文件应返回错误")
+	}
+	localFilePath := writeTestFile(t, t.TempDir(), "learn.txt", "hello")
+	if err := client.UploadFile(ctx, localFilePath, "no-such-container", ""); err == nil {
+		t.Error("上传到不存在的容器应返回错误")
+	}
+}
+
+func TestUploadFileToAzureRequiresCredentials(t *testing.T) {
+	// 没有账号和密钥时在发送请求之前返回错误
+	if err := UploadFileToAzure("learn.txt", "", "", "docs"); err == nil {
+		t.Error("缺少账号和密钥时应返回错误")
+	}
 }
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// 默认的全局终结点；自定义域名形如 https://<资源名>.cognitiveservices.azure.com/translator/text/v3.0，
+// 主权云如 https://api.translator.azure.cn
+const defaultTranslatorEndpoint = "https://api.cognitive.microsofttranslator.com"
+
+// 使用 Entra ID 认证时申请令牌的作用域
+const cognitiveServicesScope = "https://cognitiveservices.azure.com/.default"
+
+// TranslatorConfig 表示翻译服务的连接配置
+type TranslatorConfig struct {
+	Endpoint        string                 // 服务终结点，为空时使用全局终结点
+	SubscriptionKey string                 // 订阅密钥，与 Credential 二选一
+	Region          string                 // 资源所在区域，多区域资源可以为空
+	Credential      azcore.TokenCredential // Entra ID 凭据，设置后使用 Bearer 令牌认证
+	ResourceID      string                 // 资源的完整 ID，使用 Entra ID 访问全局终结点时需要
+	MaxRetries      int                    // 网络错误和 429/5xx 时的最大重试次数，0 使用默认值 3，负数表示不重试
+	Timeout         time.Duration          // 单次请求超时时间
+}
+
+// translatorConfigFromEnv 从环境变量读取连接配置
+// AZURE_TRANSLATOR_AUTH=entra 时使用 DefaultAzureCredential（托管标识、Azure CLI、环境变量中的服务主体等）
+func translatorConfigFromEnv() (TranslatorConfig, error) {
+	cfg := TranslatorConfig{
+		Endpoint:        os.Getenv("AZURE_TRANSLATOR_ENDPOINT"),
+		SubscriptionKey: os.Getenv("AZURE_TRANSLATOR_KEY"),
+		Region:          os.Getenv("AZURE_TRANSLATOR_REGION"),
+		ResourceID:      os.Getenv("AZURE_TRANSLATOR_RESOURCE_ID"),
+	}
+	if strings.EqualFold(os.Getenv("AZURE_TRANSLATOR_AUTH"), "entra") {
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return cfg, fmt.Errorf("创建 Entra ID 凭据失败: %v", err)
+		}
+		cfg.Credential = cred
+	}
+	return cfg, nil
+}
+
+// translatorClient 封装对翻译服务的HTTP调用，创建一次后重复使用
+type translatorClient struct {
+	cfg        TranslatorConfig
+	httpClient *http.Client
+}
+
+// newTranslatorClient 根据配置创建客户端
+func newTranslatorClient(cfg TranslatorConfig) (*translatorClient, error) {
+	if cfg.SubscriptionKey == "" && cfg.Credential == nil {
+		return nil, fmt.Errorf("必须提供订阅密钥或 Entra ID 凭据")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultTranslatorEndpoint
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	} else if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return &translatorClient{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// 按错误类别区分的错误，可配合 errors.Is 使用
+var (
+	ErrUnauthorized       = errors.New("认证失败")
+	ErrForbidden          = errors.New("操作不被允许")
+	ErrQuotaExceeded      = errors.New("配额已用完")
+	ErrRateLimited        = errors.New("请求过于频繁")
+	ErrInvalidRequest     = errors.New("请求参数无效")
+	ErrServiceUnavailable = errors.New("翻译服务暂时不可用")
+)
+
+// TranslatorError 表示翻译服务返回的错误
+// 服务错误码为6位数字，前三位是HTTP状态码，例如 400036 表示目标语言无效，429001 表示超出请求速率
+type TranslatorError struct {
+	StatusCode int           // HTTP状态码
+	Code       int           // 服务错误码
+	Message    string        // 错误信息
+	RequestID  string        // X-RequestId，便于向微软支持反馈
+	RetryAfter time.Duration // 服务通过 Retry-After 要求的等待时间
+}
+
+func (e *TranslatorError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("翻译服务返回错误 %d（HTTP %d）: %s", e.Code, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("API返回非成功状态码: %d", e.StatusCode)
+}
+
+// 需要单独归类的服务错误码
+const (
+	codeForbidden     = 403000 // 操作不被允许
+	codeQuotaExceeded = 403001 // 免费配额已用完
+)
+
+// Unwrap 按服务错误码把错误归入对应的类别，没有错误码时按HTTP状态码归类
+func (e *TranslatorError) Unwrap() error {
+	switch e.Code {
+	case codeQuotaExceeded:
+		return ErrQuotaExceeded
+	case codeForbidden:
+		return ErrForbidden
+	}
+	status := e.StatusCode
+	if e.Code >= 100000 && e.Code <= 999999 {
+		status = e.Code / 1000
+	}
+	switch {
+	case status == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case status == http.StatusForbidden:
+		return ErrForbidden
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusRequestTimeout || status >= 500:
+		return ErrServiceUnavailable
+	case status >= 400:
+		return ErrInvalidRequest
+	}
+	return nil
+}
+
+// retryable 判断错误是否值得重试
+func (e *TranslatorError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// parseTranslatorError 解析服务返回的错误内容：{"error":{"code":400036,"message":"..."}}
+func parseTranslatorError(resp *http.Response, body []byte) *TranslatorError {
+	e := &TranslatorError{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-RequestId")}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	var payload struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		e.Code = payload.Error.Code
+		e.Message = payload.Error.Message
+	}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+// authorize 设置认证相关的请求头
+func (c *translatorClient) authorize(ctx context.Context, req *http.Request) error {
+	if c.cfg.Credential != nil {
+		token, err := c.cfg.Credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{cognitiveServicesScope}})
+		if err != nil {
+			return fmt.Errorf("获取 Entra ID 令牌失败: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		if c.cfg.ResourceID != "" {
+			req.Header.Set("Ocp-Apim-ResourceId", c.cfg.ResourceID)
+		}
+	} else {
+		req.Header.Set("Ocp-Apim-Subscription-Key", c.cfg.SubscriptionKey)
+	}
+	if c.cfg.Region != "" {
+		req.Header.Set("Ocp-Apim-Subscription-Region", c.cfg.Region)
+	}
+	return nil
+}
+
+// post 发送 POST 请求并返回响应体；遇到网络错误、429 和 5xx 时按指数退避重试，优先遵循 Retry-After
+func (c *translatorClient) post(ctx context.Context, path string, query url.Values, payload any) ([]byte, error) {
+	bodyBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("JSON编码失败: %v", err)
+	}
+	uri := c.cfg.Endpoint + path + "?" + query.Encode()
+
+	var lastErr error
+	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, retryDelay(attempt, lastErr)); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", uri, bytes.NewReader(bodyBytes))
+		if err != nil {
+			return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if err := c.authorize(ctx, req); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("发送HTTP请求失败: %v", err)
+			continue
+		}
+		respBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("读取响应失败: %v", err)
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			return respBytes, nil
+		}
+		apiErr := parseTranslatorError(resp, respBytes)
+		if !apiErr.retryable() {
+			return nil, apiErr
+		}
+		lastErr = apiErr
+	}
+	return nil, lastErr
+}
+
+// 两次重试之间的最长等待时间，Retry-After 超过该值时也只等待这么久
+const maxRetryDelay = 30 * time.Second
+
+// retryDelay 计算第 attempt 次重试前的等待时间
+func retryDelay(attempt int, lastErr error) time.Duration {
+	var apiErr *TranslatorError
+	if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, maxRetryDelay)
+	}
+	// 0.5s、1s、2s……加上随机抖动，最长 30s
+	delay := min(time.Duration(1<<min(attempt-1, 16))*500*time.Millisecond, maxRetryDelay)
+	return delay + time.Duration(rand.Int63n(int64(delay/4)+1))
+}
+
+// sleepContext 等待指定时间，context 取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
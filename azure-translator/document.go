@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// docPart 表示文档中的一个组成部分
+// Segment 为空时 Fixed 原样写回，否则写回 Segment 的翻译结果
+type docPart struct {
+	Fixed   string
+	Segment *docSegment
+}
+
+// docSegment 表示需要发送给翻译服务的一个片段
+// 所有片段都以 textType=html 发送，不需要翻译的内容（代码、标签、换行等）
+// 会被替换为 class="notranslate" 的占位符，翻译完成后再还原
+type docSegment struct {
+	Text       string   // 发送给翻译服务的HTML片段
+	Keep       []string // 占位符对应的原始内容
+	Raw        bool     // 原文本身就是HTML，还原时不需要反转义
+	Translated string   // 还原后的译文
+}
+
+// 占位符格式；翻译服务不会翻译 notranslate 中的内容
+var (
+	keepPattern = regexp.MustCompile(`<span class="notranslate">⟦(\d+)⟧</span>|⟦(\d+)⟧`)
+	keepMarker  = regexp.MustCompile(`\x{E000}(\d+)\x{E001}`)
+)
+
+// Markdown 中需要保护的行内内容：行内代码、链接地址、HTML标签、裸URL
+var markdownProtect = regexp.MustCompile("`[^`]+`|\\]\\([^)]*\\)|<[^>]+>|https?://[^\\s)]+")
+
+// 字幕中需要保护的内容：样式标签和换行（保留原有的 \r\n）
+var subtitleProtect = regexp.MustCompile(`<[^>]+>|\r?\n`)
+
+// 纯文本段落内的换行，以及段落之间的空行
+var (
+	newlineProtect = regexp.MustCompile(`\r?\n`)
+	paragraphBreak = regexp.MustCompile(`\r?\n(?:[ \t]*\r?\n)+`)
+)
+
+// newTextSegment 从纯文本创建片段，protect 匹配到的内容不会被翻译
+func newTextSegment(text string, protect *regexp.Regexp) *docSegment {
+	seg := &docSegment{}
+	var sb strings.Builder
+	last := 0
+	for _, loc := range protect.FindAllStringIndex(text, -1) {
+		sb.WriteString(html.EscapeString(text[last:loc[0]]))
+		sb.WriteString(seg.keep(text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	sb.WriteString(html.EscapeString(text[last:]))
+	seg.Text = sb.String()
+	return seg
+}
+
+// keep 记录一段不翻译的内容并返回对应的占位符
+func (s *docSegment) keep(original string) string {
+	s.Keep = append(s.Keep, original)
+	return fmt.Sprintf(`<span class="notranslate">⟦%d⟧</span>`, len(s.Keep)-1)
+}
+
+// restore 将译文中的占位符还原为原始内容
+func (s *docSegment) restore(translated string) (string, error) {
+	// 先换成不会被反转义影响的标记，再还原
+	seen := make([]bool, len(s.Keep))
+	var bad error
+	marked := keepPattern.ReplaceAllStringFunc(translated, func(m string) string {
+		sub := keepPattern.FindStringSubmatch(m)
+		idx := sub[1]
+		if idx == "" {
+			idx = sub[2]
+		}
+		n, err := strconv.Atoi(idx)
+		if err != nil || n >= len(s.Keep) {
+			bad = fmt.Errorf("译文中出现未知占位符: %s", m)
+			return m
+		}
+		seen[n] = true
+		return "\uE000" + idx + "\uE001"
+	})
+	if bad != nil {
+		return "", bad
+	}
+	for i, ok := range seen {
+		if !ok {
+			return "", fmt.Errorf("译文丢失了受保护的内容: %q", s.Keep[i])
+		}
+	}
+	if !s.Raw {
+		marked = html.UnescapeString(marked)
+	}
+	return keepMarker.ReplaceAllStringFunc(marked, func(m string) string {
+		n, _ := strconv.Atoi(keepMarker.FindStringSubmatch(m)[1])
+		return s.Keep[n]
+	}), nil
+}
+
+// addText 向文档追加一段文本片段；首尾空白保持原样，全空白的文本不翻译
+func addText(parts []docPart, text string, protect *regexp.Regexp) []docPart {
+	core := strings.TrimSpace(text)
+	if core == "" {
+		return append(parts, docPart{Fixed: text})
+	}
+	lead := text[:strings.Index(text, core)]
+	trail := text[len(lead)+len(core):]
+	if lead != "" {
+		parts = append(parts, docPart{Fixed: lead})
+	}
+	parts = append(parts, docPart{Segment: newTextSegment(core, protect)})
+	if trail != "" {
+		parts = append(parts, docPart{Fixed: trail})
+	}
+	return parts
+}
+
+// splitLines 按行拆分内容，每行保留换行符，便于原样拼回
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// 行尾换行符（兼容 \r\n）
+func splitEOL(line string) (string, string) {
+	body := strings.TrimRight(line, "\r\n")
+	return body, line[len(body):]
+}
+
+// Markdown 行首的结构标记：引用、标题、列表、任务列表、有序列表
+var markdownPrefix = regexp.MustCompile(`^(\s*(?:>\s*)*(?:#{1,6}\s+|[-*+]\s+(?:\[[ xX]\]\s+)?|\d+[.)]\s+)?)(.*)$`)
+
+// 不需要翻译的 Markdown 行：分隔线、表格对齐行、链接引用定义
+var markdownFixedLine = regexp.MustCompile(`^\s*(?:(?:[-*_]\s*){3,}|\|?(?:\s*:?-+:?\s*\|)+\s*:?-*:?\s*|\[[^\]]+\]:\s*\S+.*)$`)
+
+// Markdown 列表项的开头
+var markdownListItem = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+
+// indentWidth 返回行首缩进的宽度，制表符按 4 列对齐
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// parseMarkdown 拆分 Markdown 文档；围栏代码块、缩进代码块和 front matter 原样保留
+func parseMarkdown(content string) []docPart {
+	var parts []docPart
+	lines := splitLines(content)
+	fence := ""
+	// 缩进代码块不能打断段落，只出现在空行或其他块之后，且不属于列表（列表项的后续段落同样是缩进的）
+	indented, afterBlank, inList := false, true, false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		body, eol := splitEOL(line)
+		trimmed := strings.TrimSpace(body)
+
+		// front matter
+		if i == 0 && trimmed == "---" {
+			j := i + 1
+			for j < len(lines) && strings.TrimSpace(lines[j]) != "---" {
+				j++
+			}
+			if j < len(lines) {
+				parts = append(parts, docPart{Fixed: strings.Join(lines[i:j+1], "")})
+				i = j
+				continue
+			}
+		}
+
+		// 围栏代码块
+		if fence != "" {
+			parts = append(parts, docPart{Fixed: line})
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+				afterBlank = true
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			indented = false
+			parts = append(parts, docPart{Fixed: line})
+			continue
+		}
+
+		if trimmed == "" {
+			parts = append(parts, docPart{Fixed: line})
+			afterBlank = true
+			continue
+		}
+
+		// 缩进代码块
+		if indentWidth(body) >= 4 && (indented || (afterBlank && !inList)) {
+			parts = append(parts, docPart{Fixed: line})
+			indented, afterBlank = true, false
+			continue
+		}
+		switch {
+		case markdownListItem.MatchString(body):
+			inList = true
+		case afterBlank && indentWidth(body) == 0:
+			inList = false
+		}
+		indented, afterBlank = false, false
+
+		if markdownFixedLine.MatchString(body) {
+			parts = append(parts, docPart{Fixed: line})
+			continue
+		}
+
+		// 表格行逐个单元格翻译
+		if strings.HasPrefix(trimmed, "|") {
+			cells := strings.Split(body, "|")
+			for j, cell := range cells {
+				if j > 0 {
+					parts = append(parts, docPart{Fixed: "|"})
+				}
+				parts = addText(parts, cell, markdownProtect)
+			}
+			parts = append(parts, docPart{Fixed: eol})
+			continue
+		}
+
+		m := markdownPrefix.FindStringSubmatch(body)
+		parts = append(parts, docPart{Fixed: m[1]})
+		parts = addText(parts, m[2], markdownProtect)
+		parts = append(parts, docPart{Fixed: eol})
+	}
+	return parts
+}
+
+// parsePlainText 按段落拆分纯文本，段落内的换行保持不变
+func parsePlainText(content string) []docPart {
+	var parts []docPart
+	last := 0
+	for _, loc := range paragraphBreak.FindAllStringIndex(content, -1) {
+		parts = addText(parts, content[last:loc[0]], newlineProtect)
+		parts = append(parts, docPart{Fixed: content[loc[0]:loc[1]]})
+		last = loc[1]
+	}
+	return addText(parts, content[last:], newlineProtect)
+}
+
+// parseSubtitles 拆分 SRT/VTT 字幕；序号、时间轴、WEBVTT 头以及 NOTE/STYLE/REGION 块原样保留
+func parseSubtitles(content string) []docPart {
+	var parts []docPart
+	lines := splitLines(content)
+	for i := 0; i < len(lines); {
+		// 每个字幕块以空行分隔
+		j := i
+		for j < len(lines) && strings.TrimSpace(lines[j]) != "" {
+			j++
+		}
+		block := lines[i:j]
+		first := ""
+		if len(block) > 0 {
+			first = strings.TrimSpace(block[0])
+		}
+
+		timing := -1
+		for k, line := range block {
+			if strings.Contains(line, "-->") {
+				timing = k
+				break
+			}
+		}
+
+		switch {
+		case len(block) == 0,
+			strings.HasPrefix(first, "WEBVTT"),
+			strings.HasPrefix(first, "NOTE"),
+			strings.HasPrefix(first, "STYLE"),
+			strings.HasPrefix(first, "REGION"),
+			timing < 0:
+			parts = append(parts, docPart{Fixed: strings.Join(block, "")})
+		default:
+			parts = append(parts, docPart{Fixed: strings.Join(block[:timing+1], "")})
+			text := strings.Join(block[timing+1:], "")
+			body := strings.TrimRight(text, "\r\n")
+			if body != "" {
+				parts = append(parts, docPart{Segment: newTextSegment(body, subtitleProtect)})
+			}
+			parts = append(parts, docPart{Fixed: text[len(body):]})
+		}
+
+		// 空行原样保留
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+			parts = append(parts, docPart{Fixed: lines[j]})
+			j++
+		}
+		i = j
+	}
+	return parts
+}
+
+// HTML 标记：注释、声明、处理指令和普通标签
+var htmlToken = regexp.MustCompile(`(?s)<!--.*?-->|<![^>]*>|<\?[^>]*>|</?([a-zA-Z][a-zA-Z0-9]*)[^>]*>`)
+
+// 内容整体保留的元素
+var htmlSkipElements = map[string]bool{
+	"script": true, "style": true, "pre": true, "textarea": true,
+}
+
+// 行内元素中整体保留、不翻译的部分
+var htmlKeepInline = map[string]bool{
+	"code": true, "kbd": true, "samp": true, "var": true,
+}
+
+// 整体保留的元素对应的结束标签
+var htmlCloseTags = func() map[string]*regexp.Regexp {
+	tags := map[string]*regexp.Regexp{}
+	for _, elements := range []map[string]bool{htmlSkipElements, htmlKeepInline} {
+		for name := range elements {
+			tags[name] = regexp.MustCompile(`(?i)</` + name + `\s*>`)
+		}
+	}
+	return tags
+}()
+
+// 行内元素；其余标签都视为块级边界
+var htmlInline = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "br": true, "cite": true,
+	"dfn": true, "em": true, "i": true, "mark": true, "q": true, "s": true, "small": true,
+	"span": true, "strong": true, "sub": true, "sup": true, "time": true, "u": true,
+}
+
+// parseHTML 拆分 HTML 文档，以块级元素为边界形成片段，行内标签随片段一起发送
+func parseHTML(content string) []docPart {
+	var parts []docPart
+	var run strings.Builder
+	seg := &docSegment{Raw: true}
+
+	// flush 把当前累积的行内内容作为一个片段
+	flush := func() {
+		text := run.String()
+		run.Reset()
+		if text == "" {
+			return
+		}
+		core := strings.TrimSpace(text)
+		if strings.TrimSpace(keepPattern.ReplaceAllString(htmlToken.ReplaceAllString(core, ""), "")) == "" {
+			// 没有可翻译的文字，还原占位符后原样写回
+			restored := keepPattern.ReplaceAllStringFunc(text, func(m string) string {
+				sub := keepPattern.FindStringSubmatch(m)
+				n, _ := strconv.Atoi(sub[1] + sub[2])
+				return seg.Keep[n]
+			})
+			parts = append(parts, docPart{Fixed: restored})
+		} else {
+			lead := text[:strings.Index(text, core)]
+			trail := text[len(lead)+len(core):]
+			seg.Text = core
+			parts = append(parts, docPart{Fixed: lead}, docPart{Segment: seg}, docPart{Fixed: trail})
+		}
+		seg = &docSegment{Raw: true}
+	}
+
+	last := 0
+	for last < len(content) {
+		loc := htmlToken.FindStringSubmatchIndex(content[last:])
+		if loc == nil {
+			run.WriteString(content[last:])
+			break
+		}
+		start, end := last+loc[0], last+loc[1]
+		run.WriteString(content[last:start])
+		tag := content[start:end]
+		name := ""
+		if loc[2] >= 0 {
+			name = strings.ToLower(content[last+loc[2] : last+loc[3]])
+		}
+		closing := strings.HasPrefix(tag, "</")
+
+		switch {
+		case name != "" && !closing && (htmlSkipElements[name] || htmlKeepInline[name]):
+			// 找到对应的结束标签，整个元素原样保留
+			stop := len(content)
+			if m := htmlCloseTags[name].FindStringIndex(content[end:]); m != nil {
+				stop = end + m[1]
+			}
+			if htmlSkipElements[name] {
+				flush()
+				parts = append(parts, docPart{Fixed: content[start:stop]})
+			} else {
+				run.WriteString(seg.keep(content[start:stop]))
+			}
+			last = stop
+			continue
+		case name != "" && htmlInline[name]:
+			run.WriteString(tag)
+		default:
+			flush()
+			parts = append(parts, docPart{Fixed: tag})
+		}
+		last = end
+	}
+	flush()
+	return parts
+}
+
+// parseDocument 根据文件扩展名选择拆分方式
+func parseDocument(path, content string) ([]docPart, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return parseMarkdown(content), nil
+	case ".html", ".htm":
+		return parseHTML(content), nil
+	case ".txt":
+		return parsePlainText(content), nil
+	case ".srt", ".vtt":
+		return parseSubtitles(content), nil
+	default:
+		return nil, fmt.Errorf("不支持的文档格式: %s", filepath.Ext(path))
+	}
+}
+
+// defaultOutputPath 生成默认输出路径，如 guide.md -> guide.ko.md
+func defaultOutputPath(path, targetLang string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + targetLang + ext
+}
+
+// translateDocument 翻译结构化文档并保留原有格式
+// 参数:
+//   - ctx: 请求的上下文，取消时中止请求和重试等待
+//   - inPath: 源文档路径
+//   - outPath: 输出文档路径
+//   - targetLang: 目标语言代码
+//   - opts: 源语言、术语表等可选参数，文本类型固定为 html
+//   - client: 翻译服务客户端
+//
+// 返回:
+//   - 实际翻译的片段数量
+//   - 可能的错误
+func translateDocument(ctx context.Context, inPath, outPath, targetLang string, opts TranslateOptions, client *translatorClient) (int, error) {
+	data, err := os.ReadFile(inPath)
+	if err != nil {
+		return 0, fmt.Errorf("读取文档失败: %v", err)
+	}
+
+	parts, err := parseDocument(inPath, string(data))
+	if err != nil {
+		return 0, err
+	}
+
+	// 收集所有需要翻译的片段，一次批量发送
+	var segments []*docSegment
+	var texts []string
+	for _, part := range parts {
+		if part.Segment != nil {
+			segments = append(segments, part.Segment)
+			texts = append(texts, part.Segment.Text)
+		}
+	}
+
+	opts.TextType = "html"
+	translated, err := translateBatch(ctx, texts, targetLang, opts, client)
+	if err != nil {
+		return 0, err
+	}
+	for i, seg := range segments {
+		if seg.Translated, err = seg.restore(translated[i]); err != nil {
+			return 0, fmt.Errorf("还原第 %d 个片段失败: %v", i+1, err)
+		}
+	}
+
+	// 重新拼装文档
+	var sb strings.Builder
+	for _, part := range parts {
+		if part.Segment != nil {
+			sb.WriteString(part.Segment.Translated)
+		} else {
+			sb.WriteString(part.Fixed)
+		}
+	}
+
+	if err := os.WriteFile(outPath, []byte(sb.String()), 0644); err != nil {
+		return 0, fmt.Errorf("写入文档失败: %v", err)
+	}
+	return len(segments), nil
+}
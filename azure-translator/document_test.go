@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// segmentTexts 返回需要翻译的片段，占位符还原为原始内容
+func segmentTexts(t *testing.T, parts []docPart) []string {
+	t.Helper()
+	var texts []string
+	for _, part := range parts {
+		if part.Segment != nil {
+			text, err := part.Segment.restore(part.Segment.Text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
+
+// reassemble 用 translate 处理每个片段后拼回文档，模拟翻译服务的往返
+func reassemble(t *testing.T, parts []docPart, translate func(string) string) string {
+	t.Helper()
+	var sb strings.Builder
+	for _, part := range parts {
+		if part.Segment == nil {
+			sb.WriteString(part.Fixed)
+			continue
+		}
+		text, err := part.Segment.restore(translate(part.Segment.Text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+func identity(s string) string { return s }
+
+func TestParseMarkdownProtectsCode(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"围栏代码块", "# Title\n\n```go\nfmt.Println(\"hi\")\n```\n\nAfter.\n", []string{"Title", "After."}},
+		{"波浪线围栏", "~~~\nraw text\n~~~\nAfter.\n", []string{"After."}},
+		{"缩进代码块", "Intro:\n\n    go run main.go\n    ./app\n\nDone.\n", []string{"Intro:", "Done."}},
+		{"代码块中的空行", "Intro:\n\n    line one\n\n    line two\nDone.\n", []string{"Intro:", "Done."}},
+		{"制表符缩进", "Intro:\n\n\tmake build\n", []string{"Intro:"}},
+		{"围栏之后的缩进代码", "```\na\n```\n    indented code\n", nil},
+		{"段落中的缩进行", "First line\n    continued\n", []string{"First line", "continued"}},
+		{"列表项的后续段落", "- item\n\n    more about item\n", []string{"item", "more about item"}},
+		{"列表结束后的代码块", "- item\n\nText\n\n    code\n", []string{"item", "Text"}},
+		{"行内代码和链接", "Run `make` or see [docs](http://x.io/a).\n", []string{"Run `make` or see [docs](http://x.io/a)."}},
+		{"front matter", "---\ntitle: Guide\n---\nBody\n", []string{"Body"}},
+	}
+	for _, c := range cases {
+		parts := parseMarkdown(c.content)
+		if got := segmentTexts(t, parts); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: 片段 = %q，期望 %q", c.name, got, c.want)
+		}
+		if got := reassemble(t, parts, identity); got != c.content {
+			t.Errorf("%s: 往返后内容不一致:\n%q", c.name, got)
+		}
+	}
+
+	// 行内代码以占位符发送，不会被翻译
+	seg := parseMarkdown("Run `make` now\n")[1].Segment
+	if strings.Contains(seg.Text, "make") || !reflect.DeepEqual(seg.Keep, []string{"`make`"}) {
+		t.Errorf("行内代码没有被保护: %q", seg.Text)
+	}
+}
+
+func TestParseSubtitlesRoundTrip(t *testing.T) {
+	translate := strings.NewReplacer("Hello", "Hallo", "world", "Welt", "Bye", "Tschüss").Replace
+	cases := []struct {
+		name, content, want string
+		segments            int
+	}{
+		{
+			name:     "SRT",
+			content:  "1\n00:00:01,000 --> 00:00:02,000\nHello\nworld\n\n2\n00:00:03,000 --> 00:00:04,000\n<i>Bye</i>\n",
+			want:     "1\n00:00:01,000 --> 00:00:02,000\nHallo\nWelt\n\n2\n00:00:03,000 --> 00:00:04,000\n<i>Tschüss</i>\n",
+			segments: 2,
+		},
+		{
+			name:     "CRLF 换行的 SRT",
+			content:  "1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\nworld\r\n\r\n",
+			want:     "1\r\n00:00:01,000 --> 00:00:02,000\r\nHallo\r\nWelt\r\n\r\n",
+			segments: 1,
+		},
+		{
+			name:     "VTT",
+			content:  "WEBVTT\n\nNOTE Hello is kept\n\nSTYLE\n::cue { color: red }\n\nintro\n00:01.000 --> 00:02.000 align:start\nHello\n",
+			want:     "WEBVTT\n\nNOTE Hello is kept\n\nSTYLE\n::cue { color: red }\n\nintro\n00:01.000 --> 00:02.000 align:start\nHallo\n",
+			segments: 1,
+		},
+	}
+	for _, c := range cases {
+		parts := parseSubtitles(c.content)
+		if got := len(segmentTexts(t, parts)); got != c.segments {
+			t.Errorf("%s: 片段数量 = %d，期望 %d", c.name, got, c.segments)
+		}
+		if got := reassemble(t, parts, identity); got != c.content {
+			t.Errorf("%s: 往返后内容不一致:\n%q", c.name, got)
+		}
+		if got := reassemble(t, parts, translate); got != c.want {
+			t.Errorf("%s: 译文 = %q，期望 %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseHTMLKeepsCode(t *testing.T) {
+	content := "<h1>Title</h1>\n<p>Use <code>go <b>test</b></code> and <CODE>vet</CODE> here.</p>\n<pre>keep\n</pre><script>var a = '<p>';</script>"
+	parts := parseHTML(content)
+	want := []string{"Title", "Use <code>go <b>test</b></code> and <CODE>vet</CODE> here."}
+	if got := segmentTexts(t, parts); !reflect.DeepEqual(got, want) {
+		t.Errorf("片段 = %q", got)
+	}
+	if got := reassemble(t, parts, identity); got != content {
+		t.Errorf("往返后内容不一致:\n%q", got)
+	}
+}
+
+func TestTranslateDocumentKeepsLineEndings(t *testing.T) {
+	client := newStubClient(t)
+	dir := t.TempDir()
+	in := filepath.Join(dir, "movie.srt")
+	if err := os.WriteFile(in, []byte("1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\nworld\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := defaultOutputPath(in, "ko")
+	count, err := translateDocument(context.Background(), in, out, "ko", TranslateOptions{}, client)
+	if err != nil {
+		t.Fatalf("翻译失败: %v", err)
+	}
+	data, _ := os.ReadFile(out)
+	if count != 1 || string(data) != "1\r\n00:00:01,000 --> 00:00:02,000\r\n[ko]Hello\r\nworld\r\n" {
+		t.Errorf("翻译了 %d 个片段，结果 %q", count, data)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 术语保护方式
+const (
+	glossaryPlaceholder = "placeholder" // 替换为 notranslate 占位符，翻译后再写入指定译文
+	glossaryDictionary  = "dictionary"  // 使用翻译服务的动态词典标记 <mstrans:dictionary>
+)
+
+// glossaryTerm 表示术语表中的一条术语
+type glossaryTerm struct {
+	Source string // 原文中的术语
+	Target string // 指定译文；为空表示不翻译，保留原文
+}
+
+// translation 返回术语在译文中应出现的形式
+func (t glossaryTerm) translation() string {
+	if t.Target == "" {
+		return t.Source
+	}
+	return t.Target
+}
+
+// glossary 表示一个语言对的术语表
+type glossary struct {
+	Terms       []glossaryTerm
+	Mode        string
+	htmlPattern *regexp.Regexp // 匹配 HTML 文本中转义后的术语
+	textPattern *regexp.Regexp // 匹配纯文本中的术语
+	index       map[string]int
+}
+
+// missingTerm 表示译文中没有按术语表出现的术语
+type missingTerm struct {
+	Index int    `json:"index"` // 文本在本次批量翻译中的下标
+	Term  string `json:"term"`  // 原文中的术语
+}
+
+// 术语占位符，使用 g 前缀与文档中的占位符区分
+var glossaryKeep = regexp.MustCompile(`<span class="notranslate">⟦g(\d+)⟧</span>|⟦g(\d+)⟧`)
+
+// 按标签拆分HTML，只在标签之外替换术语
+var glossaryTag = regexp.MustCompile(`<[^>]+>`)
+
+// 判断术语首尾是否为单词字符，决定是否按整词匹配
+var (
+	wordStart = regexp.MustCompile(`^\w`)
+	wordEnd   = regexp.MustCompile(`\w$`)
+)
+
+// loadGlossary 加载 dir 下对应语言对的术语表 <from>-<to>.csv
+// 每行两列：术语,译文；译文为空表示不翻译。以 # 开头的行为注释，首行为 source,target 时视为表头
+// 文件不存在时返回 nil
+func loadGlossary(dir, from, to, mode string) (*glossary, error) {
+	if from == "" {
+		return nil, fmt.Errorf("使用术语表时必须指定源语言")
+	}
+	if mode == "" {
+		mode = glossaryPlaceholder
+	}
+	if mode != glossaryPlaceholder && mode != glossaryDictionary {
+		return nil, fmt.Errorf("未知的术语保护方式: %s", mode)
+	}
+
+	path := filepath.Join(dir, from+"-"+to+".csv")
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开术语表失败: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	g := &glossary{Mode: mode, index: map[string]int{}}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析术语表 %s 失败: %v", path, err)
+		}
+		source := strings.TrimSpace(record[0])
+		target := ""
+		if len(record) > 1 {
+			target = strings.TrimSpace(record[1])
+		}
+		if line == 1 && strings.EqualFold(source, "source") && strings.EqualFold(target, "target") {
+			continue
+		}
+		if source == "" {
+			continue
+		}
+		if _, ok := g.index[source]; ok {
+			return nil, fmt.Errorf("术语表 %s 中术语重复: %s", path, source)
+		}
+		g.index[source] = len(g.Terms)
+		g.Terms = append(g.Terms, glossaryTerm{Source: source, Target: target})
+	}
+	if len(g.Terms) == 0 {
+		return nil, nil
+	}
+
+	// 较长的术语优先匹配
+	sorted := make([]string, 0, len(g.Terms))
+	for _, term := range g.Terms {
+		sorted = append(sorted, term.Source)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	g.htmlPattern = termPattern(sorted, html.EscapeString)
+	g.textPattern = termPattern(sorted, func(s string) string { return s })
+	return g, nil
+}
+
+// termPattern 生成匹配所有术语的正则表达式，术语先经过 escape 处理，与待匹配文本的形式一致
+// 以字母数字开头或结尾的术语按整词匹配
+func termPattern(terms []string, escape func(string) string) *regexp.Regexp {
+	alternatives := make([]string, len(terms))
+	for i, term := range terms {
+		expr := regexp.QuoteMeta(escape(term))
+		if wordStart.MatchString(term) {
+			expr = `\b` + expr
+		}
+		if wordEnd.MatchString(term) {
+			expr += `\b`
+		}
+		alternatives[i] = expr
+	}
+	return regexp.MustCompile(strings.Join(alternatives, "|"))
+}
+
+// glossaryText 表示替换术语后的待翻译文本
+type glossaryText struct {
+	Text    string // 发送给翻译服务的文本
+	Used    []int  // 出现的术语下标
+	Escaped bool   // 原文为纯文本，发送前已转为HTML
+}
+
+// protect 替换文本中的术语
+// 占位符模式需要以 html 方式发送，纯文本会先转义；词典模式保持原有文本类型
+func (g *glossary) protect(text string, isHTML bool) glossaryText {
+	result := glossaryText{}
+	if !isHTML && g.Mode == glossaryPlaceholder {
+		text = html.EscapeString(text)
+		result.Escaped = true
+		isHTML = true
+	}
+
+	pattern := g.textPattern
+	if isHTML {
+		pattern = g.htmlPattern
+	}
+	replace := func(s string) string {
+		return pattern.ReplaceAllStringFunc(s, func(m string) string {
+			source := m
+			if isHTML {
+				source = html.UnescapeString(m)
+			}
+			i := g.index[source]
+			result.Used = append(result.Used, i)
+			if g.Mode == glossaryDictionary {
+				return `<mstrans:dictionary translation="` + html.EscapeString(g.Terms[i].translation()) + `">` + m + `</mstrans:dictionary>`
+			}
+			return `<span class="notranslate">⟦g` + strconv.Itoa(i) + `⟧</span>`
+		})
+	}
+
+	if !isHTML {
+		result.Text = replace(text)
+		return result
+	}
+	// 只替换标签之外的文字
+	var sb strings.Builder
+	last := 0
+	for _, loc := range glossaryTag.FindAllStringIndex(text, -1) {
+		sb.WriteString(replace(text[last:loc[0]]))
+		sb.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	sb.WriteString(replace(text[last:]))
+	result.Text = sb.String()
+	return result
+}
+
+// restore 还原占位符并检查术语是否按要求出现在译文中
+// 返回还原后的译文以及未按术语表翻译的术语
+func (g *glossary) restore(prepared glossaryText, translated string) (string, []string) {
+	if g.Mode == glossaryPlaceholder {
+		translated = glossaryKeep.ReplaceAllStringFunc(translated, func(m string) string {
+			sub := glossaryKeep.FindStringSubmatch(m)
+			i, _ := strconv.Atoi(sub[1] + sub[2])
+			if i >= len(g.Terms) {
+				return m
+			}
+			// 译文是HTML，术语需要转义；纯文本输入稍后会整体反转义
+			return html.EscapeString(g.Terms[i].translation())
+		})
+	}
+	if prepared.Escaped {
+		translated = html.UnescapeString(translated)
+	}
+
+	var missing []string
+	for _, i := range prepared.Used {
+		expected := g.Terms[i].translation()
+		if !strings.Contains(translated, expected) && !strings.Contains(translated, html.EscapeString(expected)) {
+			missing = append(missing, g.Terms[i].Source)
+		}
+	}
+	return translated, missing
+}
+
+// translateWithGlossary 按术语表保护术语后批量翻译，并校验译文中的术语
+// 未按术语表翻译的术语记录到 opts.MissingTerms，未指定时输出警告
+func translateWithGlossary(ctx context.Context, texts []string, targetLang string, opts TranslateOptions, client *translatorClient) ([]string, error) {
+	g := opts.Glossary
+	prepared := make([]glossaryText, len(texts))
+	payload := make([]string, len(texts))
+	for i, text := range texts {
+		prepared[i] = g.protect(text, opts.TextType == "html")
+		payload[i] = prepared[i].Text
+	}
+
+	inner := opts
+	inner.Glossary = nil
+	inner.MissingTerms = nil
+	if g.Mode == glossaryPlaceholder {
+		inner.TextType = "html"
+	}
+	translated, err := translateBatch(ctx, payload, targetLang, inner, client)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range translated {
+		var missing []string
+		translated[i], missing = g.restore(prepared[i], translated[i])
+		if len(missing) == 0 {
+			continue
+		}
+		if opts.MissingTerms == nil {
+			log.Printf("警告: 第 %d 段译文未按术语表翻译: %s", i+1, strings.Join(missing, ", "))
+			continue
+		}
+		for _, term := range missing {
+			*opts.MissingTerms = append(*opts.MissingTerms, missingTerm{Index: i, Term: term})
+		}
+	}
+	return translated, nil
+}
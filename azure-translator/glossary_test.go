@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// loadTestGlossary 从 CSV 内容加载 en-de 术语表
+func loadTestGlossary(t *testing.T, mode, content string) *glossary {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "en-de.csv", content)
+	g, err := loadGlossary(dir, "en", "de", mode)
+	if err != nil || g == nil {
+		t.Fatalf("加载术语表失败: %v", err)
+	}
+	return g
+}
+
+const testGlossaryCSV = "source,target\n# 注释\nR&D,Forschung\nAzure,\n\"<b>\",<b>\nAPI,Schnittstelle\n"
+
+func TestGlossaryProtect(t *testing.T) {
+	cases := []struct {
+		name   string
+		mode   string
+		text   string
+		isHTML bool
+		want   string
+		used   []string
+	}{
+		{
+			name: "占位符模式的纯文本先转义",
+			mode: glossaryPlaceholder, text: `R&D uses "Azure" APIs`,
+			want: `<span class="notranslate">⟦g0⟧</span> uses &#34;<span class="notranslate">⟦g1⟧</span>&#34; APIs`,
+			used: []string{"R&D", "Azure"},
+		},
+		{
+			name: "词典模式的纯文本不转义",
+			mode: glossaryDictionary, text: "R&D and Azure <b> API",
+			want: `<mstrans:dictionary translation="Forschung">R&D</mstrans:dictionary> and ` +
+				`<mstrans:dictionary translation="Azure">Azure</mstrans:dictionary> ` +
+				`<mstrans:dictionary translation="&lt;b&gt;"><b></mstrans:dictionary> ` +
+				`<mstrans:dictionary translation="Schnittstelle">API</mstrans:dictionary>`,
+			used: []string{"R&D", "Azure", "<b>", "API"},
+		},
+		{
+			name: "词典模式的HTML匹配转义后的术语",
+			mode: glossaryDictionary, text: "<p title=\"API\">R&amp;D &lt;b&gt;</p>", isHTML: true,
+			want: `<p title="API"><mstrans:dictionary translation="Forschung">R&amp;D</mstrans:dictionary> ` +
+				`<mstrans:dictionary translation="&lt;b&gt;">&lt;b&gt;</mstrans:dictionary></p>`,
+			used: []string{"R&D", "<b>"},
+		},
+		{
+			name: "HTML 标签内的文字不替换",
+			mode: glossaryPlaceholder, text: `<a href="/Azure">Azure</a>`, isHTML: true,
+			want: `<a href="/Azure"><span class="notranslate">⟦g1⟧</span></a>`,
+			used: []string{"Azure"},
+		},
+		{
+			name: "按整词匹配",
+			mode: glossaryPlaceholder, text: "APIs and RAPID",
+			want: "APIs and RAPID",
+		},
+	}
+	for _, c := range cases {
+		g := loadTestGlossary(t, c.mode, testGlossaryCSV)
+		prepared := g.protect(c.text, c.isHTML)
+		if prepared.Text != c.want {
+			t.Errorf("%s:\n得到 %s\n期望 %s", c.name, prepared.Text, c.want)
+		}
+		var used []string
+		for _, i := range prepared.Used {
+			used = append(used, g.Terms[i].Source)
+		}
+		if !reflect.DeepEqual(used, c.used) {
+			t.Errorf("%s: 使用的术语 = %q", c.name, used)
+		}
+	}
+}
+
+func TestGlossaryRestore(t *testing.T) {
+	g := loadTestGlossary(t, glossaryPlaceholder, testGlossaryCSV)
+	prepared := g.protect("R&D & Azure", false)
+
+	got, missing := g.restore(prepared, `<span class="notranslate">⟦g0⟧</span> &amp; ⟦g1⟧`)
+	if got != "Forschung & Azure" || len(missing) != 0 {
+		t.Errorf("还原结果 = %q，缺失 %q", got, missing)
+	}
+	// 译文丢失了占位符
+	if _, missing := g.restore(prepared, "nothing"); !reflect.DeepEqual(missing, []string{"R&D", "Azure"}) {
+		t.Errorf("缺失的术语 = %q", missing)
+	}
+}
+
+func TestTranslateWithGlossaryReportsMissingTerms(t *testing.T) {
+	// 翻译服务丢掉了词典标记
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []TranslationRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		resp := make([]map[string]any, len(body))
+		for i := range body {
+			resp[i] = map[string]any{"translations": []map[string]string{{"text": "ohne Begriff", "to": "de"}}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	client, _ := newTranslatorClient(TranslatorConfig{Endpoint: server.URL, SubscriptionKey: "k", MaxRetries: -1})
+
+	var missing []missingTerm
+	opts := TranslateOptions{From: "en", Glossary: loadTestGlossary(t, glossaryDictionary, testGlossaryCSV), MissingTerms: &missing}
+	if _, err := translateBatch(context.Background(), []string{"no terms", "R&D team"}, "de", opts, client); err != nil {
+		t.Fatalf("翻译失败: %v", err)
+	}
+	if want := []missingTerm{{Index: 1, Term: "R&D"}}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("缺失的术语 = %+v", missing)
+	}
+}
+
+func TestLoadGlossaryErrors(t *testing.T) {
+	dir := t.TempDir()
+	if g, err := loadGlossary(dir, "en", "fr", glossaryPlaceholder); g != nil || err != nil {
+		t.Errorf("术语表不存在时应返回 nil: %v, %v", g, err)
+	}
+	writeFile(t, dir, "en-de.csv", "API,a\nAPI,b\n")
+	if _, err := loadGlossary(dir, "en", "de", glossaryPlaceholder); err == nil {
+		t.Error("术语重复时应返回错误")
+	}
+	if _, err := loadGlossary(dir, "en", "de", "unknown"); err == nil {
+		t.Error("未知的保护方式应返回错误")
+	}
+	if _, err := loadGlossary(dir, "", "de", glossaryPlaceholder); err == nil {
+		t.Error("未指定源语言时应返回错误")
+	}
+}
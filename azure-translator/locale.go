@@ -0,0 +1,842 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// localeEntry 表示资源文件中的一条可翻译字符串
+type localeEntry struct {
+	Path  []string // 键路径，嵌套结构逐层展开
+	Value string
+}
+
+// 键名中的 . 和 \ 需要转义，否则扁平的 "a.b" 与嵌套的 a → b 会得到相同的键名
+var localeKeyEscaper = strings.NewReplacer(`\`, `\\`, `.`, `\.`)
+
+// key 返回用于比较和报告的键名，各层以 . 连接
+func (e localeEntry) key() string {
+	parts := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		parts[i] = localeKeyEscaper.Replace(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// localeReport 表示一个目标语言文件的更新报告
+type localeReport struct {
+	Target    string   `json:"target"`             // 目标文件路径
+	Added     []string `json:"added"`              // 新翻译的键（目标文件中缺失）
+	Updated   []string `json:"updated"`            // 源文本变化后重新翻译的键
+	Removed   []string `json:"removed"`            // 源文件中已不存在、从目标文件删除的键
+	Unchanged int      `json:"unchanged"`          // 保持原译文的键数量
+	Chars     int      `json:"chars"`              // 发送翻译的字符数
+	Warnings  []string `json:"warnings,omitempty"` // 需要译者检查的问题
+
+	MissingTerms map[string][]string `json:"missingTerms,omitempty"` // 译文未按术语表翻译的术语，按键列出
+}
+
+// 需要原样保留的占位符：{name}、{{name}}、%s、%1$d、%(name)s、${name}、HTML标签和转义换行
+var localePlaceholder = regexp.MustCompile(`\{\{[^{}]*\}\}|\{[^{}]*\}|%\([^)]+\)[sdif]|%(?:\d+\$)?[-+#0]*\d*(?:\.\d+)?[sdifuxXoeEgGcqvT%@]|\$\{[^}]*\}|<[^>]+>|\\n|\n`)
+
+// localeTargetPath 根据源文件路径推导目标语言文件路径
+// 例如 locales/en.json -> locales/ko.json，en/app.yaml -> ko/app.yaml，
+// messages.en.json -> messages.ko.json，messages.pot -> ko.po
+func localeTargetPath(src, from, to string) string {
+	dir, base := filepath.Split(src)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	switch {
+	case ext == ".pot":
+		return filepath.Join(dir, to+".po")
+	case name == from:
+		return filepath.Join(dir, to+ext)
+	case filepath.Base(filepath.Clean(dir)) == from:
+		return filepath.Join(filepath.Dir(filepath.Clean(dir)), to, base)
+	case strings.HasSuffix(name, "."+from):
+		return filepath.Join(dir, strings.TrimSuffix(name, from)+to+ext)
+	default:
+		return filepath.Join(dir, name+"."+to+ext)
+	}
+}
+
+// translateLocaleFile 把源语言资源文件中缺失或变化的键翻译到目标语言文件
+// 参数:
+//   - ctx: 请求的上下文，取消时中止请求和重试等待
+//   - src: 源语言资源文件（.json/.yaml/.yml/.po/.pot）
+//   - to: 目标语言代码
+//   - opts: 翻译参数，opts.From 为源语言代码，必须指定
+//   - client: 翻译服务客户端
+//
+// 返回:
+//   - 更新报告
+//   - 可能的错误
+func translateLocaleFile(ctx context.Context, src, to string, opts TranslateOptions, client *translatorClient) (*localeReport, error) {
+	dst := localeTargetPath(src, opts.From, to)
+	switch strings.ToLower(filepath.Ext(src)) {
+	case ".po", ".pot":
+		return translatePOFile(ctx, src, dst, to, opts, client)
+	case ".json", ".yaml", ".yml":
+		return translateKeyValueFile(ctx, src, dst, to, opts, client)
+	default:
+		return nil, fmt.Errorf("不支持的资源文件格式: %s", filepath.Ext(src))
+	}
+}
+
+// translateLocaleTexts 翻译一组资源字符串，占位符不会被翻译，并检查译文中占位符是否完整
+// 未按术语表翻译的术语记录到报告中
+func translateLocaleTexts(ctx context.Context, keys, texts []string, to string, opts TranslateOptions, client *translatorClient, report *localeReport) ([]string, error) {
+	segments := make([]*docSegment, len(texts))
+	payload := make([]string, len(texts))
+	for i, text := range texts {
+		segments[i] = newTextSegment(text, localePlaceholder)
+		payload[i] = segments[i].Text
+	}
+
+	var missing []missingTerm
+	opts.TextType = "html"
+	opts.MissingTerms = &missing
+	translated, err := translateBatch(ctx, payload, to, opts, client)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range missing {
+		if report.MissingTerms == nil {
+			report.MissingTerms = map[string][]string{}
+		}
+		report.MissingTerms[keys[m.Index]] = append(report.MissingTerms[keys[m.Index]], m.Term)
+	}
+	for i, seg := range segments {
+		if translated[i], err = seg.restore(translated[i]); err != nil {
+			return nil, fmt.Errorf("键 %s: %v", keys[i], err)
+		}
+	}
+	return translated, nil
+}
+
+// translateKeyValueFile 处理 JSON/YAML 资源文件
+// 目标文件旁会保存一份 .<文件名>.source.json，记录每个键上次翻译时的源文本，用于识别变化的键；
+// 没有这份记录时只重新翻译译文与源文本相同的键，并在报告中给出警告
+func translateKeyValueFile(ctx context.Context, src, dst, to string, opts TranslateOptions, client *translatorClient) (*localeReport, error) {
+	source, err := readLocale(src)
+	if err != nil {
+		return nil, err
+	}
+	// Rails 风格的文件以语言代码作为根键，比较时去掉根键
+	rooted := hasLocaleRoot(source, opts.From)
+	if rooted {
+		source = stripLocaleRoot(source)
+	}
+
+	existing := map[string]string{}
+	var existingOrder []string
+	if _, err := os.Stat(dst); err == nil {
+		target, err := readLocale(dst)
+		if err != nil {
+			return nil, err
+		}
+		if hasLocaleRoot(target, to) {
+			target = stripLocaleRoot(target)
+		}
+		for _, entry := range target {
+			existing[entry.key()] = entry.Value
+			existingOrder = append(existingOrder, entry.key())
+		}
+	}
+
+	statePath := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".source.json")
+	state := map[string]string{}
+	data, err := os.ReadFile(statePath)
+	stateFound := err == nil
+	if stateFound {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %v", statePath, err)
+		}
+	}
+
+	report := &localeReport{Target: dst}
+	// 没有记录时（升级后第一次运行或记录被删除）无法知道哪些源文本变了，
+	// 只能重新翻译译文与源文本相同、看起来没有翻译过的键，其余变化需要人工检查
+	if !stateFound && len(existing) > 0 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("没有找到 %s，无法识别源文本变化的键，只重新翻译了译文与源文本相同的键", filepath.Base(statePath)))
+	}
+	output := make([]localeEntry, len(source))
+	var pending []int
+	var keys, texts []string
+	sourceKeys := map[string]bool{}
+	for i, entry := range source {
+		key := entry.key()
+		sourceKeys[key] = true
+		output[i] = localeEntry{Path: entry.Path}
+
+		value, ok := existing[key]
+		previous, tracked := state[key]
+		switch {
+		case !ok || value == "":
+			report.Added = append(report.Added, key)
+		case tracked && previous != entry.Value, !stateFound && value == entry.Value:
+			report.Updated = append(report.Updated, key)
+		default:
+			output[i].Value = value
+			report.Unchanged++
+			continue
+		}
+		pending = append(pending, i)
+		keys = append(keys, key)
+		texts = append(texts, entry.Value)
+		report.Chars += len([]rune(entry.Value))
+	}
+	for _, key := range existingOrder {
+		if !sourceKeys[key] {
+			report.Removed = append(report.Removed, key)
+		}
+	}
+
+	if len(texts) > 0 {
+		translated, err := translateLocaleTexts(ctx, keys, texts, to, opts, client, report)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range pending {
+			output[i].Value = translated[j]
+		}
+	}
+
+	if rooted {
+		for i := range output {
+			output[i].Path = append([]string{to}, output[i].Path...)
+		}
+	}
+	if err := writeLocale(dst, output); err != nil {
+		return nil, err
+	}
+
+	// 记录本次使用的源文本
+	newState := make(map[string]string, len(source))
+	for _, entry := range source {
+		newState[entry.key()] = entry.Value
+	}
+	data, err = json.MarshalIndent(newState, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(statePath, data, 0644); err != nil {
+		return nil, fmt.Errorf("写入 %s 失败: %v", statePath, err)
+	}
+	return report, nil
+}
+
+// hasLocaleRoot 判断所有键是否都位于以语言代码命名的根键下
+func hasLocaleRoot(entries []localeEntry, lang string) bool {
+	if len(entries) == 0 {
+		return false
+	}
+	for _, entry := range entries {
+		if len(entry.Path) < 2 || entry.Path[0] != lang {
+			return false
+		}
+	}
+	return true
+}
+
+// stripLocaleRoot 去掉每个键路径的第一层
+func stripLocaleRoot(entries []localeEntry) []localeEntry {
+	stripped := make([]localeEntry, len(entries))
+	for i, entry := range entries {
+		stripped[i] = localeEntry{Path: entry.Path[1:], Value: entry.Value}
+	}
+	return stripped
+}
+
+// readLocale 读取 JSON/YAML 资源文件，按文件中的顺序展开为键值列表
+func readLocale(path string) ([]localeEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取资源文件失败: %v", err)
+	}
+
+	var entries []localeEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return nil, fmt.Errorf("%s: 根节点必须是对象", path)
+		}
+		err = walkJSONObject(dec, nil, &entries)
+	default:
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %v", path, err)
+		}
+		if len(doc.Content) == 0 {
+			return nil, nil
+		}
+		if doc.Content[0].Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: 根节点必须是映射", path)
+		}
+		err = walkYAMLMapping(doc.Content[0], nil, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	return entries, nil
+}
+
+// walkJSONObject 按顺序遍历 JSON 对象，收集字符串值
+func walkJSONObject(dec *json.Decoder, path []string, entries *[]localeEntry) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		keyPath := append(append([]string{}, path...), tok.(string))
+
+		tok, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		switch value := tok.(type) {
+		case json.Delim:
+			if value != '{' {
+				return fmt.Errorf("%s: 不支持数组", strings.Join(keyPath, "."))
+			}
+			if err := walkJSONObject(dec, keyPath, entries); err != nil {
+				return err
+			}
+		case string:
+			*entries = append(*entries, localeEntry{Path: keyPath, Value: value})
+		default:
+			return fmt.Errorf("%s: 只支持字符串值", strings.Join(keyPath, "."))
+		}
+	}
+	// 读取对象结束符
+	_, err := dec.Token()
+	return err
+}
+
+// walkYAMLMapping 按顺序遍历 YAML 映射，收集字符串值
+func walkYAMLMapping(node *yaml.Node, path []string, entries *[]localeEntry) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyPath := append(append([]string{}, path...), node.Content[i].Value)
+		value := node.Content[i+1]
+		switch {
+		case value.Kind == yaml.MappingNode:
+			if err := walkYAMLMapping(value, keyPath, entries); err != nil {
+				return err
+			}
+		case value.Kind == yaml.ScalarNode && value.ShortTag() == "!!str":
+			*entries = append(*entries, localeEntry{Path: keyPath, Value: value.Value})
+		default:
+			return fmt.Errorf("%s: 只支持字符串值", strings.Join(keyPath, "."))
+		}
+	}
+	return nil
+}
+
+// localeTree 按插入顺序保存嵌套的键值结构，用于写回文件
+type localeTree struct {
+	keys     []string
+	values   map[string]string
+	children map[string]*localeTree
+}
+
+// buildLocaleTree 由展开的键值列表重建嵌套结构
+func buildLocaleTree(entries []localeEntry) *localeTree {
+	root := newLocaleTree()
+	for _, entry := range entries {
+		node := root
+		for i, key := range entry.Path {
+			last := i == len(entry.Path)-1
+			_, isValue := node.values[key]
+			child, isChild := node.children[key]
+			if !isValue && !isChild {
+				node.keys = append(node.keys, key)
+			}
+			if last {
+				node.values[key] = entry.Value
+				break
+			}
+			if !isChild {
+				child = newLocaleTree()
+				node.children[key] = child
+			}
+			node = child
+		}
+	}
+	return root
+}
+
+func newLocaleTree() *localeTree {
+	return &localeTree{values: map[string]string{}, children: map[string]*localeTree{}}
+}
+
+// writeJSON 以两个空格缩进输出 JSON，不转义 HTML 字符
+func (t *localeTree) writeJSON(sb *strings.Builder, indent string) error {
+	sb.WriteString("{")
+	for i, key := range t.keys {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		name, err := jsonString(key)
+		if err != nil {
+			return err
+		}
+		sb.WriteString("\n" + indent + "  " + name + ": ")
+		if child, ok := t.children[key]; ok {
+			if err := child.writeJSON(sb, indent+"  "); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := jsonString(t.values[key])
+		if err != nil {
+			return err
+		}
+		sb.WriteString(value)
+	}
+	if len(t.keys) > 0 {
+		sb.WriteString("\n" + indent)
+	}
+	sb.WriteString("}")
+	return nil
+}
+
+// jsonString 编码 JSON 字符串，保留 < > & 等字符
+func jsonString(s string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// yamlNode 转换为 YAML 映射节点
+func (t *localeTree) yamlNode() *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range t.keys {
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+		if child, ok := t.children[key]; ok {
+			node.Content = append(node.Content, keyNode, child.yamlNode())
+			continue
+		}
+		node.Content = append(node.Content, keyNode, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t.values[key]})
+	}
+	return node
+}
+
+// writeLocale 按文件扩展名写回 JSON/YAML 资源文件
+func writeLocale(path string, entries []localeEntry) error {
+	tree := buildLocaleTree(entries)
+
+	var data []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var sb strings.Builder
+		if err := tree.writeJSON(&sb, ""); err != nil {
+			return err
+		}
+		sb.WriteString("\n")
+		data = []byte(sb.String())
+	default:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(tree.yamlNode()); err != nil {
+			return fmt.Errorf("生成 YAML 失败: %v", err)
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入资源文件失败: %v", err)
+	}
+	return nil
+}
+
+// poEntry 表示 gettext 文件中的一条记录
+type poEntry struct {
+	Comments []string // 注释行（译者注释、引用位置、标记等），原样保留
+	Context  string   // msgctxt
+	ID       string   // msgid
+	Plural   string   // msgid_plural
+	Str      []string // msgstr，复数形式时为 msgstr[n]
+}
+
+// key 返回记录的唯一标识：上下文与 msgid
+func (e *poEntry) key() string {
+	if e.Context == "" {
+		return e.ID
+	}
+	return e.Context + "\x04" + e.ID
+}
+
+// fuzzy 判断记录是否被标记为需要复查
+func (e *poEntry) fuzzy() bool {
+	for _, c := range e.Comments {
+		if strings.HasPrefix(c, "#,") && strings.Contains(c, "fuzzy") {
+			return true
+		}
+	}
+	return false
+}
+
+// translated 判断记录是否已有完整译文
+func (e *poEntry) translated() bool {
+	if len(e.Str) == 0 {
+		return false
+	}
+	for _, s := range e.Str {
+		if s == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePO 解析 .po/.pot 文件；已废弃的 #~ 记录会被丢弃
+func parsePO(content string) ([]*poEntry, error) {
+	var entries []*poEntry
+	cur := &poEntry{}
+	started := false
+	var appendTo func(string)
+
+	flush := func() {
+		if started {
+			entries = append(entries, cur)
+		}
+		cur = &poEntry{}
+		started = false
+		appendTo = nil
+	}
+
+	for n, raw := range strings.Split(content, "\n") {
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#~"):
+			continue
+		case strings.HasPrefix(line, "#"):
+			if started && len(cur.Str) > 0 {
+				flush()
+			}
+			cur.Comments = append(cur.Comments, line)
+		case strings.HasPrefix(line, `"`):
+			s, err := strconv.Unquote(line)
+			if err != nil || appendTo == nil {
+				return nil, fmt.Errorf("第 %d 行格式错误: %s", n+1, line)
+			}
+			appendTo(s)
+		default:
+			keyword, rest, _ := strings.Cut(line, " ")
+			value, err := strconv.Unquote(strings.TrimSpace(rest))
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行格式错误: %s", n+1, line)
+			}
+			switch {
+			case keyword == "msgctxt":
+				if started {
+					flush()
+				}
+				cur.Context = value
+				appendTo = func(s string) { cur.Context += s }
+			case keyword == "msgid":
+				if started && len(cur.Str) > 0 {
+					flush()
+				}
+				started = true
+				cur.ID = value
+				appendTo = func(s string) { cur.ID += s }
+			case keyword == "msgid_plural":
+				cur.Plural = value
+				appendTo = func(s string) { cur.Plural += s }
+			case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+				cur.Str = append(cur.Str, value)
+				i := len(cur.Str) - 1
+				appendTo = func(s string) { cur.Str[i] += s }
+			default:
+				return nil, fmt.Errorf("第 %d 行无法识别: %s", n+1, line)
+			}
+		}
+	}
+	flush()
+	return entries, nil
+}
+
+// formatPO 生成 .po 文件内容
+func formatPO(entries []*poEntry) string {
+	var sb strings.Builder
+	for i, e := range entries {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		for _, c := range e.Comments {
+			sb.WriteString(c + "\n")
+		}
+		if e.Context != "" {
+			writePOString(&sb, "msgctxt", e.Context)
+		}
+		writePOString(&sb, "msgid", e.ID)
+		if e.Plural != "" {
+			writePOString(&sb, "msgid_plural", e.Plural)
+			for n, s := range e.Str {
+				writePOString(&sb, fmt.Sprintf("msgstr[%d]", n), s)
+			}
+			continue
+		}
+		str := ""
+		if len(e.Str) > 0 {
+			str = e.Str[0]
+		}
+		writePOString(&sb, "msgstr", str)
+	}
+	return sb.String()
+}
+
+// writePOString 写入一个字段；包含换行的字符串按 gettext 惯例拆成多行
+func writePOString(sb *strings.Builder, keyword, value string) {
+	if !strings.Contains(strings.TrimSuffix(value, "\n"), "\n") {
+		sb.WriteString(keyword + " " + strconv.Quote(value) + "\n")
+		return
+	}
+	sb.WriteString(keyword + " \"\"\n")
+	for _, line := range strings.SplitAfter(value, "\n") {
+		if line != "" {
+			sb.WriteString(strconv.Quote(line) + "\n")
+		}
+	}
+}
+
+// 头部中的复数形式数量
+var poPluralForms = regexp.MustCompile(`nplurals\s*=\s*(\d+)`)
+
+// 常用语言的 gettext 复数规则，按语言代码查找，找不到时按主语言（连字符之前的部分）查找
+var poPluralRules = map[string]string{
+	"ja": "nplurals=1; plural=0;", "ko": "nplurals=1; plural=0;", "zh": "nplurals=1; plural=0;",
+	"vi": "nplurals=1; plural=0;", "th": "nplurals=1; plural=0;", "id": "nplurals=1; plural=0;",
+	"ms": "nplurals=1; plural=0;",
+	"en": "nplurals=2; plural=(n != 1);", "de": "nplurals=2; plural=(n != 1);", "nl": "nplurals=2; plural=(n != 1);",
+	"sv": "nplurals=2; plural=(n != 1);", "da": "nplurals=2; plural=(n != 1);", "nb": "nplurals=2; plural=(n != 1);",
+	"fi": "nplurals=2; plural=(n != 1);", "et": "nplurals=2; plural=(n != 1);", "el": "nplurals=2; plural=(n != 1);",
+	"hu": "nplurals=2; plural=(n != 1);", "it": "nplurals=2; plural=(n != 1);", "es": "nplurals=2; plural=(n != 1);",
+	"ca": "nplurals=2; plural=(n != 1);", "bg": "nplurals=2; plural=(n != 1);", "he": "nplurals=2; plural=(n != 1);",
+	"tr": "nplurals=2; plural=(n != 1);", "hi": "nplurals=2; plural=(n != 1);", "pt-pt": "nplurals=2; plural=(n != 1);",
+	"fr": "nplurals=2; plural=(n > 1);", "pt": "nplurals=2; plural=(n > 1);",
+	"ru": "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"uk": "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"be": "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"sr": "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"hr": "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"bs": "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"pl": "nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"cs": "nplurals=3; plural=(n==1 ? 0 : n>=2 && n<=4 ? 1 : 2);",
+	"sk": "nplurals=3; plural=(n==1 ? 0 : n>=2 && n<=4 ? 1 : 2);",
+	"lt": "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"lv": "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n != 0 ? 1 : 2);",
+	"ro": "nplurals=3; plural=(n==1 ? 0 : (n==0 || (n%100 > 0 && n%100 < 20)) ? 1 : 2);",
+	"sl": "nplurals=4; plural=(n%100==1 ? 0 : n%100==2 ? 1 : n%100==3 || n%100==4 ? 2 : 3);",
+	"ga": "nplurals=5; plural=(n==1 ? 0 : n==2 ? 1 : n<7 ? 2 : n<11 ? 3 : 4);",
+	"ar": "nplurals=6; plural=(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5);",
+}
+
+// pluralFormsFor 返回目标语言的 Plural-Forms，如 zh-Hans、pt-PT、sr-Latn
+func pluralFormsFor(lang string) (string, bool) {
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	if rule, ok := poPluralRules[lang]; ok {
+		return rule, true
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	rule, ok := poPluralRules[base]
+	return rule, ok
+}
+
+// setPOHeader 设置头部字段，不存在时追加
+func setPOHeader(header, name, value string) string {
+	lines := strings.SplitAfter(header, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, name+":") {
+			lines[i] = name + ": " + value + "\n"
+			return strings.Join(lines, "")
+		}
+	}
+	if header != "" && !strings.HasSuffix(header, "\n") {
+		header += "\n"
+	}
+	return header + name + ": " + value + "\n"
+}
+
+// translatePOFile 处理 gettext 文件；缺失、为空或标记为 fuzzy 的记录会被翻译
+// msgid 本身就是源文本，源文本变化即表现为新的记录
+func translatePOFile(ctx context.Context, src, dst, to string, opts TranslateOptions, client *translatorClient) (*localeReport, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, fmt.Errorf("读取资源文件失败: %v", err)
+	}
+	source, err := parsePO(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %v", src, err)
+	}
+
+	existing := map[string]*poEntry{}
+	var target []*poEntry
+	if data, err := os.ReadFile(dst); err == nil {
+		if target, err = parsePO(string(data)); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %v", dst, err)
+		}
+		for _, e := range target {
+			existing[e.key()] = e
+		}
+	}
+
+	// 头部优先沿用目标文件，否则基于源文件生成
+	report := &localeReport{Target: dst}
+	header := &poEntry{Str: []string{""}}
+	h, fromTarget := existing[""]
+	if fromTarget {
+		header.Comments = h.Comments
+		if len(h.Str) > 0 {
+			header.Str[0] = h.Str[0]
+		}
+	} else {
+		if len(source) > 0 && source[0].ID == "" {
+			header.Comments = source[0].Comments
+			if len(source[0].Str) > 0 {
+				header.Str[0] = source[0].Str[0]
+			}
+		}
+		header.Str[0] = setPOHeader(header.Str[0], "Language", to)
+	}
+	// 源文件的复数规则不适用于目标语言；目标文件中已有的规则由译者维护，不覆盖
+	if !fromTarget || !poPluralForms.MatchString(header.Str[0]) {
+		if rule, ok := pluralFormsFor(to); ok {
+			header.Str[0] = setPOHeader(header.Str[0], "Plural-Forms", rule)
+		} else {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("没有 %s 的复数规则，请在头部设置 Plural-Forms 并检查复数译文的数量", to))
+		}
+	}
+	plurals := 2
+	if m := poPluralForms.FindStringSubmatch(header.Str[0]); m != nil {
+		plurals, _ = strconv.Atoi(m[1])
+	}
+
+	output := []*poEntry{header}
+	sourceKeys := map[string]bool{"": true}
+	var pending []*poEntry
+	var keys, texts []string
+	for _, e := range source {
+		if e.ID == "" {
+			continue
+		}
+		sourceKeys[e.key()] = true
+		entry := &poEntry{Context: e.Context, ID: e.ID, Plural: e.Plural}
+		for _, c := range e.Comments {
+			// 源文件中的 fuzzy 标记不带到目标文件
+			if !strings.HasPrefix(c, "#,") || !strings.Contains(c, "fuzzy") {
+				entry.Comments = append(entry.Comments, c)
+			}
+		}
+		output = append(output, entry)
+
+		old, ok := existing[e.key()]
+		switch {
+		case !ok || !old.translated():
+			report.Added = append(report.Added, e.ID)
+		case old.fuzzy():
+			report.Updated = append(report.Updated, e.ID)
+		default:
+			entry.Str = old.Str
+			report.Unchanged++
+			continue
+		}
+		pending = append(pending, entry)
+		keys = append(keys, e.ID)
+		texts = append(texts, e.ID)
+		report.Chars += len([]rune(e.ID))
+		if e.Plural != "" {
+			keys = append(keys, e.Plural)
+			texts = append(texts, e.Plural)
+			report.Chars += len([]rune(e.Plural))
+		}
+	}
+	for _, e := range target {
+		if !sourceKeys[e.key()] {
+			report.Removed = append(report.Removed, e.ID)
+		}
+	}
+
+	if len(texts) > 0 {
+		translated, err := translateLocaleTexts(ctx, keys, texts, to, opts, client, report)
+		if err != nil {
+			return nil, err
+		}
+		i := 0
+		for _, entry := range pending {
+			if entry.Plural == "" {
+				entry.Str = []string{translated[i]}
+				i++
+				continue
+			}
+			// 第一种形式使用单数译文，其余使用复数译文；只有一种形式的语言使用复数译文
+			entry.Str = make([]string, plurals)
+			for n := range entry.Str {
+				entry.Str[n] = translated[i+1]
+			}
+			if plurals > 1 {
+				entry.Str[0] = translated[i]
+			}
+			i += 2
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(dst, []byte(formatPO(output)), 0644); err != nil {
+		return nil, fmt.Errorf("写入资源文件失败: %v", err)
+	}
+	return report, nil
+}
+
+// printLocaleReport 输出更新报告
+func printLocaleReport(report *localeReport) {
+	fmt.Printf("%s: 新增 %d，更新 %d，删除 %d，未变化 %d，翻译字符数 %d\n",
+		report.Target, len(report.Added), len(report.Updated), len(report.Removed), report.Unchanged, report.Chars)
+	for _, key := range report.Added {
+		fmt.Printf("  + %s\n", key)
+	}
+	for _, key := range report.Updated {
+		fmt.Printf("  ~ %s\n", key)
+	}
+	for _, key := range report.Removed {
+		fmt.Printf("  - %s\n", key)
+	}
+	missingKeys := make([]string, 0, len(report.MissingTerms))
+	for key := range report.MissingTerms {
+		missingKeys = append(missingKeys, key)
+	}
+	sort.Strings(missingKeys)
+	for _, key := range missingKeys {
+		fmt.Printf("  ! %s 未按术语表翻译: %s\n", key, strings.Join(report.MissingTerms[key], ", "))
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("  ! %s\n", warning)
+	}
+}
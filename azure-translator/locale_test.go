@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile 在 dir 下写入测试文件并返回路径
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// localeValues 读取资源文件，返回键名到值的映射
+func localeValues(t *testing.T, path string) map[string]string {
+	t.Helper()
+	entries, err := readLocale(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, e := range entries {
+		values[e.key()] = e.Value
+	}
+	return values
+}
+
+func TestLocaleKeys(t *testing.T) {
+	cases := []struct {
+		path []string
+		want string
+	}{
+		{[]string{"menu", "open"}, "menu.open"},
+		{[]string{"menu.open"}, `menu\.open`},
+		{[]string{`a\`, "b"}, `a\\.b`},
+		{[]string{`a\.b`}, `a\\\.b`},
+	}
+	for _, c := range cases {
+		if got := (localeEntry{Path: c.path}).key(); got != c.want {
+			t.Errorf("key(%q) = %s，期望 %s", c.path, got, c.want)
+		}
+	}
+}
+
+func TestTranslateKeyValueFiles(t *testing.T) {
+	client := newStubClient(t)
+	cases := []struct {
+		name     string
+		src      string // 源文件名
+		content  string
+		existing string // 已有的目标文件内容，为空表示不存在
+		want     map[string]string
+		added    []string
+	}{
+		{
+			name:     "JSON 扁平键与嵌套键",
+			src:      "en.json",
+			content:  `{"a.b": "Flat", "a": {"b": "Nested", "c": "Hi {name}"}}`,
+			existing: `{"a.b": "평면"}`,
+			want:     map[string]string{`a\.b`: "평면", "a.b": "[ko]Nested", "a.c": "[ko]Hi {name}"},
+			added:    []string{"a.b", "a.c"},
+		},
+		{
+			name:    "YAML 以语言代码为根键",
+			src:     "config/en.yml",
+			content: "en:\n  greeting: Hello\n  menu:\n    open: Open\n    \"file.new\": New\n",
+			want:    map[string]string{"ko.greeting": "[ko]Hello", "ko.menu.open": "[ko]Open", `ko.menu.file\.new`: "[ko]New"},
+			added:   []string{"greeting", "menu.open", `menu.file\.new`},
+		},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		src := writeFile(t, dir, c.src, c.content)
+		dst := localeTargetPath(src, "en", "ko")
+		if c.existing != "" {
+			writeFile(t, filepath.Dir(dst), filepath.Base(dst), c.existing)
+		}
+		report, err := translateLocaleFile(context.Background(), src, "ko", TranslateOptions{From: "en"}, client)
+		if err != nil {
+			t.Fatalf("%s: 翻译失败: %v", c.name, err)
+		}
+		if got := localeValues(t, dst); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: 结果 = %v", c.name, got)
+		}
+		if !reflect.DeepEqual(report.Added, c.added) {
+			t.Errorf("%s: 新增的键 = %q", c.name, report.Added)
+		}
+	}
+}
+
+func TestTranslateKeyValueFileTracksChanges(t *testing.T) {
+	client := newStubClient(t)
+	dir := t.TempDir()
+	src := writeFile(t, dir, "en.json", `{"title": "Hello", "old": "Old"}`)
+	if _, err := translateLocaleFile(context.Background(), src, "ko", TranslateOptions{From: "en"}, client); err != nil {
+		t.Fatal(err)
+	}
+
+	// 修改一个键、删除一个键、新增一个键
+	writeFile(t, dir, "en.json", `{"title": "Hello!", "body": "Text"}`)
+	report, err := translateLocaleFile(context.Background(), src, "ko", TranslateOptions{From: "en"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Added, []string{"body"}) || !reflect.DeepEqual(report.Updated, []string{"title"}) ||
+		!reflect.DeepEqual(report.Removed, []string{"old"}) {
+		t.Errorf("报告 = %+v", report)
+	}
+	want := map[string]string{"title": "[ko]Hello!", "body": "[ko]Text"}
+	if got := localeValues(t, filepath.Join(dir, "ko.json")); !reflect.DeepEqual(got, want) {
+		t.Errorf("结果 = %v", got)
+	}
+}
+
+func TestTranslateKeyValueFileWithoutState(t *testing.T) {
+	client := newStubClient(t)
+	dir := t.TempDir()
+	src := writeFile(t, dir, "en.json", `{"title": "Hello!", "copied": "Copied", "done": "Done"}`)
+	// 已有的译文没有对应的源文本记录：copied 与源文本相同，看起来没有翻译过
+	writeFile(t, dir, "ko.json", `{"title": "[ko]Hello", "copied": "Copied", "done": "[ko]Done"}`)
+
+	report, err := translateLocaleFile(context.Background(), src, "ko", TranslateOptions{From: "en"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Updated, []string{"copied"}) || report.Unchanged != 2 || len(report.Warnings) != 1 ||
+		!strings.Contains(report.Warnings[0], ".ko.json.source.json") {
+		t.Errorf("报告 = %+v", report)
+	}
+	want := map[string]string{"title": "[ko]Hello", "copied": "[ko]Copied", "done": "[ko]Done"}
+	if got := localeValues(t, filepath.Join(dir, "ko.json")); !reflect.DeepEqual(got, want) {
+		t.Errorf("结果 = %v", got)
+	}
+
+	// 本次运行写入了记录，之后的变化可以正常识别，不再警告
+	writeFile(t, dir, "en.json", `{"title": "Hello!", "copied": "Copied", "done": "Finished"}`)
+	report, err = translateLocaleFile(context.Background(), src, "ko", TranslateOptions{From: "en"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Updated, []string{"done"}) || len(report.Warnings) != 0 {
+		t.Errorf("报告 = %+v", report)
+	}
+}
+
+const potSource = `# Template
+msgid ""
+msgstr ""
+"Language: en\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+#: main.go:10
+msgid "Hello"
+msgstr ""
+
+msgctxt "menu"
+msgid "Open"
+msgstr ""
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] ""
+msgstr[1] ""
+`
+
+func TestTranslatePOFile(t *testing.T) {
+	client := newStubClient(t)
+	cases := []struct {
+		name     string
+		to       string
+		content  string
+		existing string
+		header   string // 目标文件头部应包含的内容
+		forms    int    // 复数记录的 msgstr 数量
+		warnings int
+	}{
+		{name: "俄语使用三种复数形式", to: "ru", content: potSource,
+			header: "Plural-Forms: nplurals=3; plural=(n%10==1", forms: 3},
+		{name: "日语只有一种形式", to: "ja", content: potSource,
+			header: "Language: ja\n", forms: 1},
+		{name: "按主语言查找规则", to: "zh-Hans", content: potSource,
+			header: "Plural-Forms: nplurals=1; plural=0;", forms: 1},
+		{name: "未知语言给出警告", to: "tlh", content: potSource,
+			header: "Language: tlh\n", forms: 2, warnings: 1},
+		{name: "头部没有 msgstr", to: "pl", content: "msgid \"\"\n\nmsgid \"%d file\"\nmsgid_plural \"%d files\"\nmsgstr[0] \"\"\n",
+			header: "Plural-Forms: nplurals=3; plural=(n==1", forms: 3},
+		{name: "沿用目标文件的规则", to: "ru", content: potSource,
+			existing: "msgid \"\"\nmsgstr \"Language: ru\\nPlural-Forms: nplurals=4; plural=0;\\n\"\n",
+			header:   "nplurals=4; plural=0;", forms: 4},
+		{name: "目标文件头部缺少规则", to: "ru", content: potSource,
+			existing: "msgid \"\"\n",
+			header:   "Plural-Forms: nplurals=3;", forms: 3},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		src := writeFile(t, dir, "messages.pot", c.content)
+		dst := localeTargetPath(src, "en", c.to)
+		if c.existing != "" {
+			writeFile(t, dir, filepath.Base(dst), c.existing)
+		}
+		report, err := translateLocaleFile(context.Background(), src, c.to, TranslateOptions{From: "en"}, client)
+		if err != nil {
+			t.Fatalf("%s: 翻译失败: %v", c.name, err)
+		}
+		if len(report.Warnings) != c.warnings {
+			t.Errorf("%s: 警告 = %q", c.name, report.Warnings)
+		}
+		data, _ := os.ReadFile(dst)
+		entries, err := parsePO(string(data))
+		if err != nil {
+			t.Fatalf("%s: 输出无法解析: %v\n%s", c.name, err, data)
+		}
+		if entries[0].ID != "" || !strings.Contains(entries[0].Str[0], c.header) {
+			t.Errorf("%s: 头部 = %q", c.name, entries[0].Str)
+		}
+		plural := entries[len(entries)-1]
+		if len(plural.Str) != c.forms {
+			t.Errorf("%s: 复数记录 = %q", c.name, plural.Str)
+		}
+		if c.forms > 1 && (plural.Str[0] != "["+c.to+"]%d file" || plural.Str[1] != "["+c.to+"]%d files") {
+			t.Errorf("%s: 复数译文 = %q", c.name, plural.Str)
+		}
+		if c.forms == 1 && plural.Str[0] != "["+c.to+"]%d files" {
+			t.Errorf("%s: 复数译文 = %q", c.name, plural.Str)
+		}
+	}
+}
+
+func TestTranslatePOFileKeepsTranslations(t *testing.T) {
+	client := newStubClient(t)
+	dir := t.TempDir()
+	src := writeFile(t, dir, "messages.pot", potSource)
+	writeFile(t, dir, "de.po", `msgid ""
+msgstr "Language: de\n"
+
+msgid "Hello"
+msgstr "Hallo"
+
+#, fuzzy
+msgctxt "menu"
+msgid "Open"
+msgstr "Offen"
+
+msgid "Removed"
+msgstr "Entfernt"
+`)
+	report, err := translateLocaleFile(context.Background(), src, "de", TranslateOptions{From: "en"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Unchanged != 1 || !reflect.DeepEqual(report.Updated, []string{"Open"}) ||
+		!reflect.DeepEqual(report.Added, []string{"%d file"}) || !reflect.DeepEqual(report.Removed, []string{"Removed"}) {
+		t.Errorf("报告 = %+v", report)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "de.po"))
+	for _, want := range []string{"msgstr \"Hallo\"", "msgctxt \"menu\"\nmsgid \"Open\"\nmsgstr \"[de]Open\"", "#: main.go:10"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("输出缺少 %q:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// translationMemory 表示基于 SQLite 的翻译记忆库
+// 以原文、语言对和请求参数为键缓存译文，命中时不再调用翻译服务
+type translationMemory struct {
+	db         *sql.DB
+	hits       atomic.Int64 // 命中条数
+	misses     atomic.Int64 // 未命中条数
+	savedChars atomic.Int64 // 命中节省的字符数
+}
+
+// 数据库被其他连接锁定时等待的毫秒数；服务模式下并发请求会同时写入
+const memoryBusyTimeout = 5000
+
+// openTranslationMemory 打开（必要时创建）翻译记忆库
+func openTranslationMemory(path string) (*translationMemory, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d", path, memoryBusyTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("打开翻译记忆库失败: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS translations (
+		source     TEXT NOT NULL,
+		from_lang  TEXT NOT NULL,
+		to_lang    TEXT NOT NULL,
+		options    TEXT NOT NULL,
+		target     TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (source, from_lang, to_lang, options)
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化翻译记忆库失败: %v", err)
+	}
+	return &translationMemory{db: db}, nil
+}
+
+// Close 关闭翻译记忆库
+func (tm *translationMemory) Close() error {
+	return tm.db.Close()
+}
+
+// lookup 查询译文，ok 为 false 表示未命中
+func (tm *translationMemory) lookup(source, from, to, options string) (string, bool, error) {
+	var target string
+	err := tm.db.QueryRow(`SELECT target FROM translations WHERE source = ? AND from_lang = ? AND to_lang = ? AND options = ?`,
+		source, from, to, options).Scan(&target)
+	if err == sql.ErrNoRows {
+		tm.misses.Add(1)
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("查询翻译记忆失败: %v", err)
+	}
+	tm.hits.Add(1)
+	tm.savedChars.Add(int64(len([]rune(source))))
+	return target, true, nil
+}
+
+// contains 判断翻译记忆中是否已有译文，不计入命中统计
+func (tm *translationMemory) contains(source, from, to, options string) (bool, error) {
+	var n int
+	err := tm.db.QueryRow(`SELECT COUNT(*) FROM translations WHERE source = ? AND from_lang = ? AND to_lang = ? AND options = ?`,
+		source, from, to, options).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("查询翻译记忆失败: %v", err)
+	}
+	return n > 0, nil
+}
+
+// store 保存译文，已存在时覆盖
+func (tm *translationMemory) store(source, from, to, options, target string) error {
+	_, err := tm.db.Exec(`INSERT OR REPLACE INTO translations (source, from_lang, to_lang, options, target, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		source, from, to, options, target, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("保存翻译记忆失败: %v", err)
+	}
+	return nil
+}
+
+// stats 返回命中条数、未命中条数和节省的字符数
+func (tm *translationMemory) stats() (hits, misses, savedChars int64) {
+	return tm.hits.Load(), tm.misses.Load(), tm.savedChars.Load()
+}
+
+// translateWithMemory 先查询翻译记忆，只把未命中的文本发送给翻译服务，并保存新的译文
+func translateWithMemory(ctx context.Context, texts []string, targetLang string, opts TranslateOptions, client *translatorClient) ([]string, error) {
+	tm := opts.Memory
+	key := opts.cacheKey()
+	results := make([]string, len(texts))
+	var missing []int
+	var pending []string
+	for i, text := range texts {
+		target, ok, err := tm.lookup(text, opts.From, targetLang, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			results[i] = target
+			if opts.CachedChars != nil {
+				*opts.CachedChars += int64(utf8.RuneCountInString(text))
+			}
+			continue
+		}
+		missing = append(missing, i)
+		pending = append(pending, text)
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	inner := opts
+	inner.Memory = nil
+	translated, err := translateBatch(ctx, pending, targetLang, inner, client)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range missing {
+		results[i] = translated[j]
+		if err := tm.store(texts[i], opts.From, targetLang, key, translated[j]); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// TMX 1.4 文件结构
+type tmxDocument struct {
+	XMLName xml.Name  `xml:"tmx"`
+	Version string    `xml:"version,attr"`
+	Header  tmxHeader `xml:"header"`
+	Units   []tmxUnit `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTmf                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tmxUnit struct {
+	SrcLang      string       `xml:"srclang,attr,omitempty"`
+	DataType     string       `xml:"datatype,attr,omitempty"`
+	CreationDate string       `xml:"creationdate,attr,omitempty"`
+	Props        []tmxProp    `xml:"prop"`
+	Variants     []tmxVariant `xml:"tuv"`
+}
+
+type tmxProp struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type tmxVariant struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Seg  tmxSeg `xml:"seg"`
+}
+
+// tmxSeg 表示 <seg> 的文本；其他 CAT 工具导出的片段可能含有 <ph>、<bpt>、<ept>、<it> 等内联标记，
+// 这些标记表示占位符和格式，只读取文本会丢掉它们，因此导入时记录是否含有内联标记
+type tmxSeg struct {
+	Text   string
+	Inline bool // 含有内联标记
+}
+
+func (s tmxSeg) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(s.Text, start)
+}
+
+func (s *tmxSeg) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var text strings.Builder
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			s.Inline = true
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			s.Text = text.String()
+			return nil
+		}
+	}
+}
+
+// 源语言为自动检测时在 TMX 中使用的语言代码
+const tmxUndetermined = "und"
+
+// tmxDataType 返回翻译单元的数据类型：以 html 方式翻译的文本（文档、资源文件等）中含有标签和占位符
+func tmxDataType(options string) string {
+	if textType, _, _ := strings.Cut(options, "&"); textType == "textType=html" {
+		return "html"
+	}
+	return "plaintext"
+}
+
+// exportTMX 把翻译记忆导出为 TMX 文件，返回导出的条数
+func (tm *translationMemory) exportTMX(path string) (int, error) {
+	rows, err := tm.db.Query(`SELECT source, from_lang, to_lang, options, target, created_at FROM translations ORDER BY created_at`)
+	if err != nil {
+		return 0, fmt.Errorf("读取翻译记忆失败: %v", err)
+	}
+	defer rows.Close()
+
+	doc := tmxDocument{
+		Version: "1.4",
+		Header: tmxHeader{
+			CreationTool:        "azure-translator",
+			CreationToolVersion: "1.0",
+			SegType:             "sentence",
+			OTmf:                "sqlite",
+			AdminLang:           "en",
+			SrcLang:             "*all*",
+			DataType:            "unknown", // 每个翻译单元单独声明
+		},
+	}
+	for rows.Next() {
+		var source, from, to, options, target string
+		var created time.Time
+		if err := rows.Scan(&source, &from, &to, &options, &target, &created); err != nil {
+			return 0, fmt.Errorf("读取翻译记忆失败: %v", err)
+		}
+		if from == "" {
+			from = tmxUndetermined
+		}
+		doc.Units = append(doc.Units, tmxUnit{
+			SrcLang:      from,
+			DataType:     tmxDataType(options),
+			CreationDate: created.UTC().Format("20060102T150405Z"),
+			Props:        []tmxProp{{Type: "x-options", Value: options}},
+			Variants:     []tmxVariant{{Lang: from, Seg: tmxSeg{Text: source}}, {Lang: to, Seg: tmxSeg{Text: target}}},
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("生成 TMX 失败: %v", err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, fmt.Errorf("写入 TMX 失败: %v", err)
+	}
+	return len(doc.Units), nil
+}
+
+// importTMX 从 TMX 文件导入翻译记忆，返回导入的条数和跳过的翻译单元数
+// 每个翻译单元中与 srclang 一致的 tuv 作为原文，其余 tuv 各作为一条译文；
+// 没有 x-options 属性时按 datatype 视为 HTML 或纯文本。
+// 含有内联标记的翻译单元会被跳过：去掉占位符后的译文不能当作有效的缓存
+func (tm *translationMemory) importTMX(path string) (count, skipped int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("读取 TMX 失败: %v", err)
+	}
+	var doc tmxDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return 0, 0, fmt.Errorf("解析 TMX 失败: %v", err)
+	}
+
+	plainOptions := TranslateOptions{}.cacheKey()
+	htmlOptions := TranslateOptions{TextType: "html"}.cacheKey()
+	for _, unit := range doc.Units {
+		if len(unit.Variants) < 2 {
+			continue
+		}
+		if slices.ContainsFunc(unit.Variants, func(v tmxVariant) bool { return v.Seg.Inline }) {
+			skipped++
+			continue
+		}
+		srcLang := unit.SrcLang
+		if srcLang == "" || srcLang == "*all*" {
+			srcLang = doc.Header.SrcLang
+		}
+		// 找到原文
+		source := unit.Variants[0]
+		for _, v := range unit.Variants {
+			if v.Lang == srcLang {
+				source = v
+				break
+			}
+		}
+		dataType := unit.DataType
+		if dataType == "" {
+			dataType = doc.Header.DataType
+		}
+		options := plainOptions
+		if dataType == "html" || dataType == "xhtml" {
+			options = htmlOptions
+		}
+		for _, prop := range unit.Props {
+			if prop.Type == "x-options" {
+				options = prop.Value
+			}
+		}
+		from := source.Lang
+		if from == tmxUndetermined {
+			from = ""
+		}
+		for _, v := range unit.Variants {
+			if v.Lang == source.Lang {
+				continue
+			}
+			if err := tm.store(source.Seg.Text, from, v.Lang, options, v.Seg.Text); err != nil {
+				return count, skipped, err
+			}
+			count++
+		}
+	}
+	return count, skipped, nil
+}
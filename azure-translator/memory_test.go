@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// openTestMemory 在临时目录中创建翻译记忆库
+func openTestMemory(t *testing.T, path string) *translationMemory {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "tm.db")
+	}
+	tm, err := openTranslationMemory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tm.Close() })
+	return tm
+}
+
+func TestTranslateWithMemory(t *testing.T) {
+	// 记录每次发送给翻译服务的文本
+	var mu sync.Mutex
+	var sent []string
+	server := newStubTranslator(t, func(r *http.Request) {
+		var body []TranslationRequest
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		mu.Lock()
+		for _, item := range body {
+			sent = append(sent, item.Text)
+		}
+		mu.Unlock()
+	})
+	client, _ := newTranslatorClient(TranslatorConfig{Endpoint: server.URL, SubscriptionKey: "k", MaxRetries: -1})
+
+	tm := openTestMemory(t, "")
+	opts := TranslateOptions{From: "en", Memory: tm}
+	steps := []struct {
+		name  string
+		texts []string
+		opts  TranslateOptions
+		sent  []string // 应发送给翻译服务的文本，nil 表示全部命中
+	}{
+		{"全部未命中", []string{"Hello", "World"}, opts, []string{"Hello", "World"}},
+		{"部分命中", []string{"World", "New"}, opts, []string{"New"}},
+		{"全部命中", []string{"Hello", "New"}, opts, nil},
+		{"参数不同不命中", []string{"Hello"}, TranslateOptions{From: "en", Memory: tm, TextType: "html"}, []string{"Hello"}},
+	}
+	for _, step := range steps {
+		sent = nil
+		got, err := translateBatch(context.Background(), step.texts, "de", step.opts, client)
+		if err != nil {
+			t.Fatalf("%s: 翻译失败: %v", step.name, err)
+		}
+		for i, text := range step.texts {
+			if got[i] != "[de]"+text {
+				t.Errorf("%s: 译文 = %q", step.name, got)
+			}
+		}
+		if !reflect.DeepEqual(sent, step.sent) {
+			t.Errorf("%s: 发送的文本 = %q，期望 %q", step.name, sent, step.sent)
+		}
+	}
+	if hits, misses, saved := tm.stats(); hits != 3 || misses != 4 || saved != int64(len("World")+len("Hello")+len("New")) {
+		t.Errorf("统计 = 命中 %d，未命中 %d，节省 %d", hits, misses, saved)
+	}
+}
+
+func TestTMXExportImport(t *testing.T) {
+	tm := openTestMemory(t, "")
+	plain := TranslateOptions{}.cacheKey()
+	html := TranslateOptions{TextType: "html"}.cacheKey()
+	entries := []struct{ source, from, to, options, target string }{
+		{"Hello & bye", "en", "de", plain, "Hallo & tschüss"},
+		{`Run <span class="notranslate">⟦0⟧</span>`, "en", "ko", html, `실행 <span class="notranslate">⟦0⟧</span>`},
+		{"Bonjour", "", "en", plain + "&category=general", "Hello"},
+	}
+	for _, e := range entries {
+		if err := tm.store(e.source, e.from, e.to, e.options, e.target); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "export.tmx")
+	count, err := tm.exportTMX(path)
+	if err != nil || count != len(entries) {
+		t.Fatalf("导出 %d 条: %v", count, err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{`datatype="unknown"`, `srclang="en" datatype="plaintext"`, `srclang="en" datatype="html"`, `srclang="und"`, `xml:lang="ko"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("TMX 中缺少 %s:\n%s", want, data)
+		}
+	}
+
+	// 导入到新的记忆库，参数和源语言保持不变
+	imported := openTestMemory(t, "")
+	if count, skipped, err := imported.importTMX(path); err != nil || count != len(entries) || skipped != 0 {
+		t.Fatalf("导入 %d 条: %v", count, err)
+	}
+	for _, e := range entries {
+		if target, ok, err := imported.lookup(e.source, e.from, e.to, e.options); !ok || err != nil || target != e.target {
+			t.Errorf("查询 %q = %q, %v, %v", e.source, target, ok, err)
+		}
+	}
+}
+
+func TestTMXImportDataType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "other.tmx")
+	writeFile(t, filepath.Dir(path), filepath.Base(path), `<?xml version="1.0"?>
+<tmx version="1.4">
+  <header srclang="en" datatype="plaintext" segtype="sentence" o-tmf="x" adminlang="en" creationtool="x" creationtoolversion="1"/>
+  <body>
+    <tu><tuv xml:lang="en"><seg>Plain</seg></tuv><tuv xml:lang="fr"><seg>Simple</seg></tuv></tu>
+    <tu datatype="html"><tuv xml:lang="en"><seg>&lt;b&gt;Bold&lt;/b&gt;</seg></tuv><tuv xml:lang="fr"><seg>&lt;b&gt;Gras&lt;/b&gt;</seg></tuv><tuv xml:lang="de"><seg>&lt;b&gt;Fett&lt;/b&gt;</seg></tuv></tu>
+  </body>
+</tmx>`)
+	tm := openTestMemory(t, "")
+	if count, skipped, err := tm.importTMX(path); err != nil || count != 3 || skipped != 0 {
+		t.Fatalf("导入 %d 条，跳过 %d 个: %v", count, skipped, err)
+	}
+	html := TranslateOptions{TextType: "html"}.cacheKey()
+	for _, c := range []struct{ source, to, options, want string }{
+		{"Plain", "fr", TranslateOptions{}.cacheKey(), "Simple"},
+		{"<b>Bold</b>", "fr", html, "<b>Gras</b>"},
+		{"<b>Bold</b>", "de", html, "<b>Fett</b>"},
+	} {
+		if got, ok, _ := tm.lookup(c.source, "en", c.to, c.options); !ok || got != c.want {
+			t.Errorf("查询 %s→%s = %q, %v", c.source, c.to, got, ok)
+		}
+	}
+}
+
+func TestTMXImportSkipsInlineMarkup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cat.tmx")
+	writeFile(t, filepath.Dir(path), filepath.Base(path), `<?xml version="1.0"?>
+<tmx version="1.4">
+  <header srclang="en" datatype="plaintext" segtype="sentence" o-tmf="x" adminlang="en" creationtool="x" creationtoolversion="1"/>
+  <body>
+    <tu><tuv xml:lang="en"><seg>Click <ph x="1">{0}</ph> to continue</seg></tuv><tuv xml:lang="de"><seg>Klicken Sie auf <ph x="1">{0}</ph>, um fortzufahren</seg></tuv></tu>
+    <tu><tuv xml:lang="en"><seg><bpt i="1">&lt;b&gt;</bpt>Save<ept i="1">&lt;/b&gt;</ept></seg></tuv><tuv xml:lang="de"><seg><bpt i="1">&lt;b&gt;</bpt>Speichern<ept i="1">&lt;/b&gt;</ept></seg></tuv></tu>
+    <tu><tuv xml:lang="en"><seg>Open</seg></tuv><tuv xml:lang="de"><seg>Öffnen<it pos="end">&lt;/i&gt;</it></seg></tuv></tu>
+    <tu><tuv xml:lang="en"><seg>Close &amp; exit</seg></tuv><tuv xml:lang="de"><seg>Schließen &amp; beenden</seg></tuv></tu>
+  </body>
+</tmx>`)
+	tm := openTestMemory(t, "")
+	count, skipped, err := tm.importTMX(path)
+	if err != nil || count != 1 || skipped != 3 {
+		t.Fatalf("导入 %d 条，跳过 %d 个: %v", count, skipped, err)
+	}
+	plain := TranslateOptions{}.cacheKey()
+	for _, source := range []string{"Click  to continue", "Save", "Open"} {
+		if got, ok, _ := tm.lookup(source, "en", "de", plain); ok {
+			t.Errorf("含有内联标记的 %q 不应导入，译文 %q", source, got)
+		}
+	}
+	if got, ok, _ := tm.lookup("Close & exit", "en", "de", plain); !ok || got != "Schließen & beenden" {
+		t.Errorf("查询 = %q, %v", got, ok)
+	}
+}
+
+func TestMemoryConcurrentWrites(t *testing.T) {
+	// 两个连接同时写入同一个库，等待锁而不是返回 database is locked
+	path := filepath.Join(t.TempDir(), "tm.db")
+	a, b := openTestMemory(t, path), openTestMemory(t, path)
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for n, tm := range []*translationMemory{a, b} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := tm.store(fmt.Sprintf("text %d-%d", n, i), "en", "de", "textType=plain", "x"); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// 单个请求体的大小上限
+const maxServerRequestBytes = 1 << 20
+
+// apiCaller 表示一个调用方及其配额
+type apiCaller struct {
+	Name       string `json:"name"`       // 调用方名称，用于日志和指标
+	Key        string `json:"key"`        // 调用方的 API 密钥
+	DailyChars int64  `json:"dailyChars"` // 每天（UTC）可翻译的字符数，0 表示不限制
+
+	mu   sync.Mutex
+	day  string // 当前计数所属的日期
+	used int64  // 当天已使用的字符数
+}
+
+// reserve 预占配额，超出时返回 false
+func (c *apiCaller) reserve(chars int64, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if today := now.UTC().Format("2006-01-02"); c.day != today {
+		c.day, c.used = today, 0
+	}
+	if c.DailyChars > 0 && c.used+chars > c.DailyChars {
+		return false
+	}
+	c.used += chars
+	return true
+}
+
+// release 退回预占但没有用到的配额；预占后跨过了零点时当天计数可能已清零，不会退成负数
+func (c *apiCaller) release(chars int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used = max(c.used-chars, 0)
+}
+
+// usage 返回当天已使用的字符数
+func (c *apiCaller) usage() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.used
+}
+
+// loadCallers 从 JSON 文件加载调用方列表：[{"name":"svc-a","key":"...","dailyChars":100000}]
+func loadCallers(path string) (map[string]*apiCaller, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取调用方配置失败: %v", err)
+	}
+	var list []*apiCaller
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("解析调用方配置失败: %v", err)
+	}
+	callers := make(map[string]*apiCaller, len(list))
+	for _, c := range list {
+		if c.Key == "" || c.Name == "" {
+			return nil, fmt.Errorf("调用方配置缺少 name 或 key")
+		}
+		if _, ok := callers[c.Key]; ok {
+			return nil, fmt.Errorf("调用方 %s 的密钥重复", c.Name)
+		}
+		callers[c.Key] = c
+	}
+	return callers, nil
+}
+
+// serverMetrics 记录服务运行指标
+type serverMetrics struct {
+	mu              sync.Mutex
+	requests        map[string]int64 // 按 "路由 状态码" 统计的请求数
+	translatedChars atomic.Int64     // 发送给翻译服务的字符数（按目标语言累计，不含翻译记忆命中的部分）
+	upstreamErrors  atomic.Int64     // 调用翻译服务失败的次数
+}
+
+// observe 按路由记录一次请求；未匹配任何路由的请求统一记为 other，避免指标标签无限增长
+func (m *serverMetrics) observe(pattern string, status int) {
+	path := "other"
+	if _, route, ok := strings.Cut(pattern, " "); ok {
+		path = route
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[fmt.Sprintf("%s %d", path, status)]++
+}
+
+// translationServer 把翻译功能包装为 HTTP/JSON 服务
+type translationServer struct {
+	client  *translatorClient
+	memory  *translationMemory
+	callers map[string]*apiCaller
+	metrics *serverMetrics
+	now     func() time.Time
+}
+
+// newTranslationServer 创建翻译服务；memory 为空时不使用缓存
+func newTranslationServer(client *translatorClient, memory *translationMemory, callers map[string]*apiCaller) *translationServer {
+	return &translationServer{
+		client:  client,
+		memory:  memory,
+		callers: callers,
+		metrics: &serverMetrics{requests: map[string]int64{}},
+		now:     time.Now,
+	}
+}
+
+// handler 返回服务的路由
+//
+//	POST /translate  批量翻译
+//	POST /detect     语言检测
+//	GET  /healthz    健康检查
+//	GET  /metrics    Prometheus 文本格式的指标
+func (s *translationServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /translate", s.authenticated(s.handleTranslate))
+	mux.HandleFunc("POST /detect", s.authenticated(s.handleDetect))
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+		s.metrics.observe(r.Pattern, rec.status)
+	})
+}
+
+// statusRecorder 记录响应状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// callerHandler 是需要认证的处理函数
+type callerHandler func(w http.ResponseWriter, r *http.Request, caller *apiCaller)
+
+// authenticated 校验 X-API-Key 或 Authorization: Bearer 中的调用方密钥
+func (s *translationServer) authenticated(next callerHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		caller, ok := s.callers[key]
+		if key == "" || !ok {
+			writeJSONError(w, http.StatusUnauthorized, "无效的 API 密钥")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxServerRequestBytes)
+		next(w, r, caller)
+	}
+}
+
+// serviceTranslateRequest 表示 /translate 的请求体
+type serviceTranslateRequest struct {
+	Texts    []string     `json:"texts"`
+	To       languageList `json:"to"`
+	From     string       `json:"from,omitempty"`
+	TextType string       `json:"textType,omitempty"`
+
+	ProfanityAction       string `json:"profanityAction,omitempty"`
+	ProfanityMarker       string `json:"profanityMarker,omitempty"`
+	IncludeAlignment      bool   `json:"includeAlignment,omitempty"`
+	IncludeSentenceLength bool   `json:"includeSentenceLength,omitempty"`
+	Category              string `json:"category,omitempty"`
+	FromScript            string `json:"fromScript,omitempty"`
+	ToScript              string `json:"toScript,omitempty"`
+	Details               bool   `json:"details,omitempty"` // 返回完整的翻译结果（检测到的语言、音译、对齐等），不使用缓存
+}
+
+// languageList 表示目标语言，可以写成 "de" 或 ["de","fr"]
+type languageList []string
+
+func (l *languageList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = languageList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("to 必须是字符串或字符串数组")
+	}
+	*l = list
+	return nil
+}
+
+// serviceTranslation 表示一个目标语言的翻译结果
+type serviceTranslation struct {
+	To      string              `json:"to"`
+	Texts   []string            `json:"texts"`
+	Results []TranslationResult `json:"results,omitempty"` // 请求 details 时返回
+}
+
+func (s *translationServer) handleTranslate(w http.ResponseWriter, r *http.Request, caller *apiCaller) {
+	var req serviceTranslateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "请求体格式错误: "+err.Error())
+		return
+	}
+	if len(req.Texts) == 0 || len(req.To) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "texts 和 to 不能为空")
+		return
+	}
+	if req.TextType != "" && req.TextType != "plain" && req.TextType != "html" {
+		writeJSONError(w, http.StatusBadRequest, "textType 只能是 plain 或 html")
+		return
+	}
+
+	opts := TranslateOptions{
+		From:                  req.From,
+		TextType:              req.TextType,
+		Memory:                s.memory,
+		ProfanityAction:       req.ProfanityAction,
+		ProfanityMarker:       req.ProfanityMarker,
+		IncludeAlignment:      req.IncludeAlignment,
+		IncludeSentenceLength: req.IncludeSentenceLength,
+		Category:              req.Category,
+		FromScript:            req.FromScript,
+		ToScript:              req.ToScript,
+	}
+	details := req.Details || req.IncludeAlignment || req.IncludeSentenceLength || req.ToScript != ""
+
+	// 每个目标语言单独计费，只按需要发送给翻译服务的字符预占配额
+	var perLang int64
+	for _, text := range req.Texts {
+		perLang += int64(utf8.RuneCountInString(text))
+	}
+	reserved := perLang * int64(len(req.To))
+	if s.memory != nil && !details {
+		var err error
+		if reserved, err = s.uncachedChars(req.Texts, req.To, opts); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if !caller.reserve(reserved, s.now()) {
+		writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("调用方 %s 当天的字符配额已用完", caller.Name))
+		return
+	}
+
+	// sent 只统计已经翻译完成的目标语言发送给服务的字符，其余预占的配额在返回前退回：
+	// 预占之后其他请求可能已经缓存了部分文本，某个语言失败时它和之后的语言也没有计费
+	var cached, sent int64
+	opts.CachedChars = &cached
+	settle := func() {
+		caller.release(max(reserved-sent, 0))
+		s.metrics.translatedChars.Add(sent)
+	}
+	results := make([]serviceTranslation, 0, len(req.To))
+	for _, lang := range req.To {
+		result := serviceTranslation{To: lang}
+		before := cached
+		var err error
+		if details {
+			result.Results, err = translateDetailed(r.Context(), req.Texts, lang, opts, s.client)
+			for _, r := range result.Results {
+				result.Texts = append(result.Texts, r.Text)
+			}
+		} else {
+			result.Texts, err = translateBatch(r.Context(), req.Texts, lang, opts, s.client)
+		}
+		if err != nil {
+			settle()
+			s.writeUpstreamError(w, caller, err)
+			return
+		}
+		sent += perLang - (cached - before)
+		results = append(results, result)
+	}
+	settle()
+	writeJSON(w, http.StatusOK, map[string]any{"translations": results})
+}
+
+// uncachedChars 返回翻译记忆中没有译文、需要发送给翻译服务的字符数
+func (s *translationServer) uncachedChars(texts []string, langs []string, opts TranslateOptions) (int64, error) {
+	key := opts.cacheKey()
+	var chars int64
+	for _, lang := range langs {
+		for _, text := range texts {
+			ok, err := s.memory.contains(text, opts.From, lang, key)
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				chars += int64(utf8.RuneCountInString(text))
+			}
+		}
+	}
+	return chars, nil
+}
+
+// serviceDetectRequest 表示 /detect 的请求体
+type serviceDetectRequest struct {
+	Texts []string `json:"texts"`
+}
+
+func (s *translationServer) handleDetect(w http.ResponseWriter, r *http.Request, caller *apiCaller) {
+	var req serviceDetectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "请求体格式错误: "+err.Error())
+		return
+	}
+	if len(req.Texts) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "texts 不能为空")
+		return
+	}
+
+	// 语言检测同样按字符计费
+	var chars int64
+	for _, text := range req.Texts {
+		chars += int64(utf8.RuneCountInString(text))
+	}
+	if !caller.reserve(chars, s.now()) {
+		writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("调用方 %s 当天的字符配额已用完", caller.Name))
+		return
+	}
+
+	results, err := detectLanguages(r.Context(), req.Texts, s.client)
+	if err != nil {
+		caller.release(chars)
+		s.writeUpstreamError(w, caller, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+// writeUpstreamError 把翻译服务的错误转换为合适的状态码
+func (s *translationServer) writeUpstreamError(w http.ResponseWriter, caller *apiCaller, err error) {
+	s.metrics.upstreamErrors.Add(1)
+	log.Printf("调用方 %s 的请求失败: %v", caller.Name, err)
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrRateLimited):
+		writeJSONError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, ErrServiceUnavailable):
+		writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+	}
+}
+
+func (s *translationServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *translationServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var sb strings.Builder
+	sb.WriteString("# HELP translator_requests_total HTTP requests by path and status code.\n")
+	sb.WriteString("# TYPE translator_requests_total counter\n")
+	s.metrics.mu.Lock()
+	keys := make([]string, 0, len(s.metrics.requests))
+	for key := range s.metrics.requests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path, code, _ := strings.Cut(key, " ")
+		fmt.Fprintf(&sb, "translator_requests_total{path=%q,code=%q} %d\n", path, code, s.metrics.requests[key])
+	}
+	s.metrics.mu.Unlock()
+
+	sb.WriteString("# HELP translator_chars_total Characters sent to the Translator service, counted once per target language.\n")
+	sb.WriteString("# TYPE translator_chars_total counter\n")
+	fmt.Fprintf(&sb, "translator_chars_total %d\n", s.metrics.translatedChars.Load())
+	sb.WriteString("# HELP translator_upstream_errors_total Failed calls to the Translator service.\n")
+	sb.WriteString("# TYPE translator_upstream_errors_total counter\n")
+	fmt.Fprintf(&sb, "translator_upstream_errors_total %d\n", s.metrics.upstreamErrors.Load())
+
+	if s.memory != nil {
+		hits, misses, saved := s.memory.stats()
+		sb.WriteString("# TYPE translator_cache_hits_total counter\n")
+		fmt.Fprintf(&sb, "translator_cache_hits_total %d\n", hits)
+		sb.WriteString("# TYPE translator_cache_misses_total counter\n")
+		fmt.Fprintf(&sb, "translator_cache_misses_total %d\n", misses)
+		sb.WriteString("# TYPE translator_cache_saved_chars_total counter\n")
+		fmt.Fprintf(&sb, "translator_cache_saved_chars_total %d\n", saved)
+	}
+
+	sb.WriteString("# HELP translator_caller_chars_used Characters used today by each caller.\n")
+	sb.WriteString("# TYPE translator_caller_chars_used gauge\n")
+	names := make([]*apiCaller, 0, len(s.callers))
+	for _, c := range s.callers {
+		names = append(names, c)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].Name < names[j].Name })
+	for _, c := range names {
+		fmt.Fprintf(&sb, "translator_caller_chars_used{caller=%q} %d\n", c.Name, c.usage())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(sb.String()))
+}
+
+// writeJSON 输出 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError 输出 {"error": "..."} 格式的错误
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
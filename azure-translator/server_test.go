@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer 启动翻译服务，上游使用本地翻译服务桩；目标语言为 xx 时上游返回 400
+func newTestServer(t *testing.T, dailyChars int64) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var upstreamCalls atomic.Int32
+	stub := newStubTranslator(t, nil)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		if r.URL.Path == "/detect" {
+			w.Write([]byte(`[{"language":"en","score":1.0,"isTranslationSupported":true,"isTransliterationSupported":false}]`))
+			return
+		}
+		if r.URL.Query().Get("to") == "xx" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":400036,"message":"The target language is not valid."}}`))
+			return
+		}
+		stub.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(upstream.Close)
+
+	client, err := newTranslatorClient(TranslatorConfig{Endpoint: upstream.URL, SubscriptionKey: "k", MaxRetries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory, err := openTranslationMemory(filepath.Join(t.TempDir(), "tm.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { memory.Close() })
+
+	callers := map[string]*apiCaller{"secret": {Name: "svc-a", Key: "secret", DailyChars: dailyChars}}
+	server := httptest.NewServer(newTranslationServer(client, memory, callers).handler())
+	t.Cleanup(server.Close)
+	return server, &upstreamCalls
+}
+
+// call 发送带密钥的 JSON 请求，返回状态码和响应体
+func call(t *testing.T, url, key, body string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestServerTranslateBatchWithCache(t *testing.T) {
+	server, upstreamCalls := newTestServer(t, 0)
+
+	status, body := call(t, server.URL+"/translate", "secret", `{"texts":["Hello","World"],"to":["de","fr"],"from":"en"}`)
+	if status != http.StatusOK {
+		t.Fatalf("状态码 %d: %s", status, body)
+	}
+	var resp struct {
+		Translations []serviceTranslation `json:"translations"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Translations) != 2 || resp.Translations[1].To != "fr" || resp.Translations[1].Texts[1] != "[fr]World" {
+		t.Errorf("翻译结果错误: %s", body)
+	}
+
+	// 相同的请求由缓存返回，不再调用上游
+	before := upstreamCalls.Load()
+	if status, _ := call(t, server.URL+"/translate", "secret", `{"texts":["Hello"],"to":"de","from":"en"}`); status != http.StatusOK {
+		t.Fatalf("状态码 %d", status)
+	}
+	if upstreamCalls.Load() != before {
+		t.Error("缓存命中时不应调用翻译服务")
+	}
+}
+
+func TestServerRejectsUnknownKey(t *testing.T) {
+	server, _ := newTestServer(t, 0)
+	if status, _ := call(t, server.URL+"/translate", "wrong", `{"texts":["Hello"],"to":"de"}`); status != http.StatusUnauthorized {
+		t.Errorf("期望 401，实际为 %d", status)
+	}
+}
+
+func TestServerEnforcesQuota(t *testing.T) {
+	server, _ := newTestServer(t, 10)
+
+	// 5 个字符 × 2 个目标语言，刚好用完配额
+	if status, body := call(t, server.URL+"/translate", "secret", `{"texts":["Hello"],"to":["de","fr"]}`); status != http.StatusOK {
+		t.Fatalf("状态码 %d: %s", status, body)
+	}
+	if status, _ := call(t, server.URL+"/detect", "secret", `{"texts":["Hi"]}`); status != http.StatusTooManyRequests {
+		t.Errorf("超出配额时期望 429，实际为 %d", status)
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	metrics, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		`translator_caller_chars_used{caller="svc-a"} 10`,
+		`translator_requests_total{path="/detect",code="429"} 1`,
+	} {
+		if !strings.Contains(string(metrics), want) {
+			t.Errorf("指标中缺少 %s:\n%s", want, metrics)
+		}
+	}
+}
+
+func TestServerDetect(t *testing.T) {
+	server, _ := newTestServer(t, 0)
+	status, body := call(t, server.URL+"/detect", "secret", `{"texts":["Hello"]}`)
+	if status != http.StatusOK || !strings.Contains(body, `"language":"en"`) {
+		t.Errorf("状态码 %d: %s", status, body)
+	}
+}
+
+func TestServerChargesOnlyUncachedChars(t *testing.T) {
+	server, _ := newTestServer(t, 20)
+
+	// 第一次全部发送给翻译服务：5 + 5 个字符
+	if status, body := call(t, server.URL+"/translate", "secret", `{"texts":["Hello","World"],"to":"de","from":"en"}`); status != http.StatusOK {
+		t.Fatalf("状态码 %d: %s", status, body)
+	}
+	// 只有 "New" 需要翻译，缓存命中的 10 个字符不计费
+	if status, body := call(t, server.URL+"/translate", "secret", `{"texts":["Hello","World","New"],"to":"de","from":"en"}`); status != http.StatusOK {
+		t.Fatalf("状态码 %d: %s", status, body)
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	metrics, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		`translator_caller_chars_used{caller="svc-a"} 13`,
+		"translator_chars_total 13\n",
+	} {
+		if !strings.Contains(string(metrics), want) {
+			t.Errorf("指标中缺少 %s:\n%s", want, metrics)
+		}
+	}
+}
+
+func TestServerChargesOnlyTranslatedLanguages(t *testing.T) {
+	server, _ := newTestServer(t, 100)
+
+	// de 翻译完成并计费，xx 失败，之后的 fr 没有发送，两者预占的配额都退回
+	if status, body := call(t, server.URL+"/translate", "secret", `{"texts":["Hello"],"to":["de","xx","fr"],"from":"en"}`); status != http.StatusBadRequest {
+		t.Fatalf("状态码 %d: %s", status, body)
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	metrics, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		`translator_caller_chars_used{caller="svc-a"} 5`,
+		"translator_chars_total 5\n",
+	} {
+		if !strings.Contains(string(metrics), want) {
+			t.Errorf("指标中缺少 %s:\n%s", want, metrics)
+		}
+	}
+}
+
+func TestServerMetricsUseRoutes(t *testing.T) {
+	server, _ := newTestServer(t, 0)
+	for _, path := range []string{"/healthz", "/a", "/b/c", "/translate/x"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	metrics, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(metrics), `translator_requests_total{path="/healthz",code="200"} 1`) ||
+		!strings.Contains(string(metrics), `translator_requests_total{path="other",code="404"} 3`) {
+		t.Errorf("路由指标错误:\n%s", metrics)
+	}
+	for _, path := range []string{"/a", "/b/c", "/translate/x"} {
+		if strings.Contains(string(metrics), `path="`+path+`"`) {
+			t.Errorf("未知路径 %s 不应成为指标标签", path)
+		}
+	}
+}
+
+func TestCallerReleaseAcrossDays(t *testing.T) {
+	caller := &apiCaller{Name: "svc-a", DailyChars: 100}
+	day := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	if !caller.reserve(30, day) {
+		t.Fatal("预占失败")
+	}
+	// 跨过零点后计数清零，退回前一天的预占不会变成负数
+	if !caller.reserve(10, day.Add(2*time.Minute)) {
+		t.Fatal("预占失败")
+	}
+	caller.release(30)
+	if got := caller.usage(); got != 0 {
+		t.Errorf("已使用 %d 个字符，期望 0", got)
+	}
+	if caller.reserve(101, day.Add(2*time.Minute)) {
+		t.Error("超出配额时应拒绝")
+	}
+}
@@ -1,13 +1,24 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// 单次翻译请求的服务限制
+const (
+	maxBatchElements = 1000  // 每次请求最多包含的文本段数
+	maxBatchChars    = 50000 // 每次请求的字符总数上限
+
+	maxDetectElements = 100 // 语言检测每次请求最多包含的文本段数
 )
 
 // TranslationRequest 表示翻译请求的结构
@@ -15,112 +26,475 @@ type TranslationRequest struct {
 	Text string `json:"Text"`
 }
 
+// TranslateOptions 表示翻译请求的可选参数
+type TranslateOptions struct {
+	From         string             // 源语言代码，为空时由服务自动检测
+	TextType     string             // 文本类型，"plain"（默认）或 "html"；html 模式下标签会原样保留
+	Glossary     *glossary          // 术语表，为空时不做术语处理
+	MissingTerms *[]missingTerm     // 不为空时收集未按术语表翻译的术语，否则只输出警告
+	Memory       *translationMemory // 翻译记忆库，为空时不使用缓存
+	CachedChars  *int64             // 不为空时累计由翻译记忆返回、没有发送给翻译服务的字符数
+
+	ProfanityAction       string // 不雅内容处理方式：NoAction（默认）/Marked/Deleted
+	ProfanityMarker       string // ProfanityAction 为 Marked 时的标记方式：Asterisk（默认）/Tag
+	IncludeAlignment      bool   // 返回原文与译文的对齐信息
+	IncludeSentenceLength bool   // 返回原文和译文的句子长度
+	Category              string // 自定义翻译模型的类别ID，为空时使用通用模型
+	FromScript            string // 原文的书写系统，如 Latn
+	ToScript              string // 译文的音译书写系统，设置后结果中包含音译
+}
+
+// cacheKey 返回影响译文结果的请求参数，作为翻译记忆的键之一
+// 对齐、句子长度和音译不影响译文本身，不计入键中
+func (o TranslateOptions) cacheKey() string {
+	textType := o.TextType
+	if textType == "" {
+		textType = "plain"
+	}
+	key := "textType=" + textType
+	for _, param := range []struct{ name, value string }{
+		{"profanityAction", o.ProfanityAction},
+		{"profanityMarker", o.ProfanityMarker},
+		{"category", o.Category},
+		{"fromScript", o.FromScript},
+	} {
+		if param.value != "" {
+			key += "&" + param.name + "=" + param.value
+		}
+	}
+	return key
+}
+
+// DetectedLanguage 表示服务自动检测到的源语言
+type DetectedLanguage struct {
+	Language string  `json:"language"`
+	Score    float64 `json:"score"`
+}
+
+// TranslationResult 表示一段文本的翻译结果
+type TranslationResult struct {
+	Text             string            `json:"text"`
+	To               string            `json:"to"`
+	DetectedLanguage *DetectedLanguage `json:"detectedLanguage,omitempty"` // 未指定源语言时由服务返回
+	Transliteration  *struct {
+		Text   string `json:"text"`
+		Script string `json:"script"`
+	} `json:"transliteration,omitempty"` // 指定 ToScript 时返回
+	Alignment *struct {
+		Proj string `json:"proj"` // 形如 "0:4-0:2 6:10-4:7"，表示原文与译文字符区间的对应关系
+	} `json:"alignment,omitempty"` // 指定 IncludeAlignment 时返回
+	SentLen *struct {
+		SrcSentLen   []int `json:"srcSentLen"`
+		TransSentLen []int `json:"transSentLen"`
+	} `json:"sentLen,omitempty"` // 指定 IncludeSentenceLength 时返回
+}
+
 // TranslationResponse 表示翻译响应的结构
 type TranslationResponse []struct {
-	Translations []struct {
-		Text string `json:"text"`
-		To   string `json:"to"`
-	} `json:"translations"`
+	DetectedLanguage *DetectedLanguage   `json:"detectedLanguage"`
+	Translations     []TranslationResult `json:"translations"`
 }
 
 func main() {
-	// 从环境变量获取Azure翻译服务的密钥和区域
+	// 命令行参数；默认行为与原来一致，翻译一段文本
+	action := flag.String("action", "translate", "操作类型：translate/document/locale/tm-import/tm-export/serve")
+	text := flag.String("text", "Hello, world!", "要翻译的文本（action=translate）")
+	to := flag.String("to", "ko", "目标语言代码；action=locale 时可用逗号分隔多个语言")
+	from := flag.String("from", "", "源语言代码，为空时自动检测；action=locale 和使用术语表时必须指定")
+	in := flag.String("in", "", "要翻译的文件路径：文档支持 .md/.html/.txt/.srt/.vtt，资源文件支持 .json/.yaml/.po/.pot")
+	out := flag.String("out", "", "翻译结果的输出路径，默认在原文件名后追加语言代码")
+	reportPath := flag.String("report", "", "资源文件更新报告的输出路径（JSON），为空时只打印到终端")
+	glossaryDir := flag.String("glossary", "", "术语表目录，按语言对读取 <from>-<to>.csv")
+	glossaryMode := flag.String("glossary-mode", glossaryPlaceholder, "术语保护方式：placeholder/dictionary")
+	memoryPath := flag.String("memory", "", "翻译记忆库（SQLite）路径，指定后优先使用缓存的译文")
+	addr := flag.String("addr", ":8080", "HTTP服务监听地址（action=serve）")
+	keysPath := flag.String("keys", "", "调用方密钥和配额文件（JSON，action=serve）")
+	profanity := flag.String("profanity", "", "不雅内容处理方式：NoAction/Marked/Deleted")
+	profanityMarker := flag.String("profanity-marker", "", "不雅内容的标记方式：Asterisk/Tag（-profanity Marked 时有效）")
+	alignment := flag.Bool("alignment", false, "输出原文与译文的对齐信息（action=translate）")
+	sentenceLength := flag.Bool("sentence-length", false, "输出原文和译文的句子长度（action=translate）")
+	category := flag.String("category", "", "自定义翻译模型的类别ID")
+	fromScript := flag.String("from-script", "", "原文的书写系统，如 Latn")
+	toScript := flag.String("to-script", "", "译文的音译书写系统，如 Latn")
+	flag.Parse()
+	ctx := context.Background()
+
+	// 从环境变量获取Azure翻译服务的终结点、密钥和区域
 	// 注意：在实际使用前，需要在Azure门户中创建翻译服务资源并获取这些值;这里我使用的环境变量进行获取，按需修改即可。
-	subscriptionKey := os.Getenv("AZURE_TRANSLATOR_KEY")
-	location := os.Getenv("AZURE_TRANSLATOR_REGION")
+	// 导入导出翻译记忆不需要调用翻译服务
+	offline := *action == "tm-import" || *action == "tm-export"
+	var client *translatorClient
+	if !offline {
+		cfg, err := translatorConfigFromEnv()
+		if err != nil {
+			log.Fatal(err)
+		}
+		// 检查必要的环境变量是否设置
+		if cfg.Credential == nil && cfg.SubscriptionKey == "" {
+			log.Fatal("请设置AZURE_TRANSLATOR_KEY和AZURE_TRANSLATOR_REGION环境变量，或设置AZURE_TRANSLATOR_AUTH=entra使用Entra ID认证")
+		}
+		if client, err = newTranslatorClient(cfg); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// 打开翻译记忆库
+	var memory *translationMemory
+	if *memoryPath != "" {
+		var err error
+		if memory, err = openTranslationMemory(*memoryPath); err != nil {
+			log.Fatal(err)
+		}
+		defer memory.Close()
+	} else if offline {
+		log.Fatal("请使用 -memory 参数指定翻译记忆库")
+	}
 
-	// 检查必要的环境变量是否设置
-	if subscriptionKey == "" || location == "" {
-		log.Fatal("请设置AZURE_TRANSLATOR_KEY和AZURE_TRANSLATOR_REGION环境变量")
+	// optionsFor 生成某个目标语言的翻译参数，指定术语表目录时加载对应语言对的术语表
+	optionsFor := func(lang string) TranslateOptions {
+		opts := TranslateOptions{
+			From:                  *from,
+			Memory:                memory,
+			ProfanityAction:       *profanity,
+			ProfanityMarker:       *profanityMarker,
+			IncludeAlignment:      *alignment,
+			IncludeSentenceLength: *sentenceLength,
+			Category:              *category,
+			FromScript:            *fromScript,
+			ToScript:              *toScript,
+		}
+		if *glossaryDir == "" {
+			return opts
+		}
+		g, err := loadGlossary(*glossaryDir, *from, lang, *glossaryMode)
+		if err != nil {
+			log.Fatalf("加载术语表失败: %v", err)
+		}
+		if g == nil {
+			fmt.Printf("未找到 %s-%s 的术语表，按普通方式翻译\n", *from, lang)
+		}
+		opts.Glossary = g
+		return opts
 	}
 
-	// 要翻译的文本
-	textToTranslate := "Hello, world!"
+	switch *action {
+	case "translate":
+		opts := optionsFor(*to)
+		// 不使用术语表时获取完整的翻译结果，输出检测到的语言、音译、对齐和句子长度；
+		// 使用翻译记忆且不需要附加信息时仍走缓存
+		details := opts.IncludeAlignment || opts.IncludeSentenceLength || opts.ToScript != ""
+		if details && opts.Glossary != nil {
+			// 术语表会改写发送的文本，对齐和句子长度无法对应原文
+			log.Fatal("-alignment、-sentence-length 和 -to-script 不能与术语表同时使用")
+		}
+		if opts.Glossary == nil && (opts.Memory == nil || details) {
+			results, err := translateDetailed(ctx, []string{*text}, *to, opts, client)
+			if err != nil {
+				log.Fatalf("翻译失败: %v", err)
+			}
+			fmt.Printf("原文: %s\n", *text)
+			printTranslationResult(results[0])
+			break
+		}
 
-	// 目标语言；目标语言需要设置。
-	targetLanguage := "ko"
+		// 调用翻译函数
+		var missing []missingTerm
+		opts.MissingTerms = &missing
+		translatedText, err := translateText(ctx, *text, *to, opts, client)
+		if err != nil {
+			log.Fatalf("翻译失败: %v", err)
+		}
 
-	// 调用翻译函数
-	translatedText, err := translateText(textToTranslate, targetLanguage, subscriptionKey, location)
-	if err != nil {
-		log.Fatalf("翻译失败: %v", err)
+		fmt.Printf("原文: %s\n", *text)
+		fmt.Printf("译文: %s\n", translatedText)
+		for _, m := range missing {
+			fmt.Printf("警告: 译文未按术语表翻译: %s\n", m.Term)
+		}
+	case "document":
+		if *in == "" {
+			log.Fatal("请使用 -in 参数指定要翻译的文档")
+		}
+		outPath := *out
+		if outPath == "" {
+			outPath = defaultOutputPath(*in, *to)
+		}
+		opts := optionsFor(*to)
+		var missing []missingTerm
+		opts.MissingTerms = &missing
+		count, err := translateDocument(ctx, *in, outPath, *to, opts, client)
+		if err != nil {
+			log.Fatalf("文档翻译失败: %v", err)
+		}
+		fmt.Printf("已翻译 %d 个片段，结果写入 %s\n", count, outPath)
+		for _, m := range missing {
+			fmt.Printf("警告: 第 %d 个片段未按术语表翻译: %s\n", m.Index+1, m.Term)
+		}
+	case "locale":
+		if *in == "" || *from == "" {
+			log.Fatal("请使用 -in 参数指定源语言资源文件，并使用 -from 参数指定源语言")
+		}
+		var reports []*localeReport
+		for _, lang := range strings.Split(*to, ",") {
+			lang = strings.TrimSpace(lang)
+			report, err := translateLocaleFile(ctx, *in, lang, optionsFor(lang), client)
+			if err != nil {
+				log.Fatalf("资源文件翻译失败: %v", err)
+			}
+			printLocaleReport(report)
+			reports = append(reports, report)
+		}
+		if *reportPath != "" {
+			data, err := json.MarshalIndent(reports, "", "  ")
+			if err != nil {
+				log.Fatalf("生成报告失败: %v", err)
+			}
+			if err := os.WriteFile(*reportPath, data, 0644); err != nil {
+				log.Fatalf("写入报告失败: %v", err)
+			}
+		}
+	case "tm-import":
+		if *in == "" {
+			log.Fatal("请使用 -in 参数指定要导入的 TMX 文件")
+		}
+		count, skipped, err := memory.importTMX(*in)
+		if err != nil {
+			log.Fatalf("导入翻译记忆失败: %v", err)
+		}
+		fmt.Printf("已从 %s 导入 %d 条翻译记忆\n", *in, count)
+		if skipped > 0 {
+			fmt.Printf("跳过 %d 个含有内联标记（<ph>、<bpt> 等）的翻译单元\n", skipped)
+		}
+	case "tm-export":
+		if *out == "" {
+			log.Fatal("请使用 -out 参数指定导出的 TMX 文件")
+		}
+		count, err := memory.exportTMX(*out)
+		if err != nil {
+			log.Fatalf("导出翻译记忆失败: %v", err)
+		}
+		fmt.Printf("已导出 %d 条翻译记忆到 %s\n", count, *out)
+	case "serve":
+		if *keysPath == "" {
+			log.Fatal("请使用 -keys 参数指定调用方密钥文件")
+		}
+		callers, err := loadCallers(*keysPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server := newTranslationServer(client, memory, callers)
+		fmt.Printf("翻译服务已启动，监听 %s（%d 个调用方）\n", *addr, len(callers))
+		if err := http.ListenAndServe(*addr, server.handler()); err != nil {
+			log.Fatalf("HTTP服务退出: %v", err)
+		}
+	default:
+		fmt.Println("无效的操作类型。请使用 -action 参数指定操作类型：translate/document/locale/tm-import/tm-export/serve")
+		flag.PrintDefaults()
 	}
 
-	fmt.Printf("原文: %s\n", textToTranslate)
-	fmt.Printf("译文: %s\n", translatedText)
+	if memory != nil && !offline {
+		hits, misses, saved := memory.stats()
+		fmt.Printf("翻译记忆: 命中 %d 条，未命中 %d 条，节省 %d 个字符\n", hits, misses, saved)
+	}
 }
 
 // translateText 使用Azure翻译服务将文本从一种语言翻译为另一种语言
 // 参数:
+//   - ctx: 请求的上下文，取消时中止请求和重试等待
 //   - text: 要翻译的文本
 //   - targetLang: 目标语言代码（如"zh-CN"表示简体中文）
-//   - subscriptionKey: Azure翻译服务的订阅密钥
-//   - location: Azure翻译服务的区域（如"eastasia"）
+//   - opts: 源语言、术语表等可选参数
+//   - client: 翻译服务客户端
 //
 // 返回:
 //   - 翻译后的文本
 //   - 可能的错误
-func translateText(text, targetLang, subscriptionKey, location string) (string, error) {
-	// Azure翻译服务的API端点；这里的调用接口地址需要记录
-	endpoint := "https://api.cognitive.microsofttranslator.com"
+func translateText(ctx context.Context, text, targetLang string, opts TranslateOptions, client *translatorClient) (string, error) {
+	results, err := translateBatch(ctx, []string{text}, targetLang, opts, client)
+	if err != nil {
+		return "", err
+	}
 
-	// 构建完整的API URL;目标语言在路径中拼接
-	uri := endpoint + "/translate?api-version=3.0&to=" + targetLang
+	// 返回翻译后的文本
+	return results[0], nil
+}
 
-	// 准备请求体
-	body := []TranslationRequest{
-		{
-			Text: text,
-		},
+// translateBatch 批量翻译多段文本，只返回译文，结果顺序与输入一致
+// 参数:
+//   - ctx: 请求的上下文，取消时中止请求和重试等待
+//   - texts: 要翻译的文本列表
+//   - targetLang: 目标语言代码
+//   - opts: 源语言、文本类型等可选参数
+//   - client: 翻译服务客户端
+//
+// 单次请求最多 1000 段、共 50000 个字符，超出时会自动拆分为多次请求
+func translateBatch(ctx context.Context, texts []string, targetLang string, opts TranslateOptions, client *translatorClient) ([]string, error) {
+	if opts.Glossary != nil {
+		return translateWithGlossary(ctx, texts, targetLang, opts, client)
 	}
-
-	// 将请求体转换为JSON
-	bodyBytes, err := json.Marshal(body)
-	if err != nil {
-		return "", fmt.Errorf("JSON编码失败: %v", err)
+	if opts.Memory != nil {
+		return translateWithMemory(ctx, texts, targetLang, opts, client)
 	}
 
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", uri, bytes.NewBuffer(bodyBytes))
+	results, err := translateDetailed(ctx, texts, targetLang, opts, client)
 	if err != nil {
-		return "", fmt.Errorf("创建HTTP请求失败: %v", err)
+		return nil, err
+	}
+	translated := make([]string, len(results))
+	for i, result := range results {
+		translated[i] = result.Text
 	}
+	return translated, nil
+}
 
-	// 添加必要的请求头；这些header头是基础必须的。
-	req.Header.Add("Ocp-Apim-Subscription-Key", subscriptionKey)
-	req.Header.Add("Ocp-Apim-Subscription-Region", location)
-	req.Header.Add("Content-Type", "application/json")
+// translateDetailed 批量翻译并返回完整的翻译结果，包括检测到的源语言、音译、对齐和句子长度
+// 术语表和翻译记忆只作用于译文文本，这里不会使用
+//
+// 单次请求最多 1000 段、共 50000 个字符，超出时会自动拆分为多次请求
+func translateDetailed(ctx context.Context, texts []string, targetLang string, opts TranslateOptions, client *translatorClient) ([]TranslationResult, error) {
+	results := make([]TranslationResult, 0, len(texts))
+	for start := 0; start < len(texts); {
+		// 按服务限制确定本次请求包含的片段
+		end, chars := start, 0
+		for end < len(texts) && end-start < maxBatchElements {
+			n := utf8.RuneCountInString(texts[end])
+			if n > maxBatchChars {
+				return nil, fmt.Errorf("第 %d 段文本长度 %d 超过单次请求上限 %d", end, n, maxBatchChars)
+			}
+			if chars+n > maxBatchChars {
+				break
+			}
+			chars += n
+			end++
+		}
 
-	// 发送HTTP请求；后续是简单的创建一个http请求。不一定要使用net/http库，按需使用即可。
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("发送HTTP请求失败: %v", err)
+		translated, err := translateRequest(ctx, texts[start:end], targetLang, opts, client)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, translated...)
+		start = end
 	}
-	defer resp.Body.Close()
+	return results, nil
+}
 
-	// 检查响应状态码
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API返回非成功状态码: %d", resp.StatusCode)
+// translateRequest 发送一次翻译请求
+func translateRequest(ctx context.Context, texts []string, targetLang string, opts TranslateOptions, client *translatorClient) ([]TranslationResult, error) {
+	// 目标语言等参数在查询字符串中拼接；终结点和认证方式由客户端配置决定
+	query := url.Values{}
+	query.Set("api-version", "3.0")
+	query.Set("to", targetLang)
+	if opts.From != "" {
+		query.Set("from", opts.From)
+	}
+	if opts.TextType != "" {
+		query.Set("textType", opts.TextType)
+	}
+	if opts.ProfanityAction != "" {
+		query.Set("profanityAction", opts.ProfanityAction)
+	}
+	if opts.ProfanityMarker != "" {
+		query.Set("profanityMarker", opts.ProfanityMarker)
+	}
+	if opts.IncludeAlignment {
+		query.Set("includeAlignment", "true")
+	}
+	if opts.IncludeSentenceLength {
+		query.Set("includeSentenceLength", "true")
+	}
+	if opts.Category != "" {
+		query.Set("category", opts.Category)
+	}
+	if opts.FromScript != "" {
+		query.Set("fromScript", opts.FromScript)
+	}
+	if opts.ToScript != "" {
+		query.Set("toScript", opts.ToScript)
+	}
+
+	// 准备请求体
+	body := make([]TranslationRequest, len(texts))
+	for i, text := range texts {
+		body[i] = TranslationRequest{Text: text}
 	}
 
-	// 读取响应体
-	respBytes, err := io.ReadAll(resp.Body)
+	// 发送请求；失败时客户端会按配置重试
+	respBytes, err := client.post(ctx, "/translate", query, body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %v", err)
+		return nil, err
 	}
 
 	// 解析JSON响应
 	var translationResp TranslationResponse
 	if err := json.Unmarshal(respBytes, &translationResp); err != nil {
-		return "", fmt.Errorf("解析JSON响应失败: %v", err)
+		return nil, fmt.Errorf("解析JSON响应失败: %v", err)
 	}
 
-	// 检查响应是否包含翻译结果
-	if len(translationResp) == 0 || len(translationResp[0].Translations) == 0 {
-		return "", fmt.Errorf("翻译响应为空")
+	// 检查响应是否包含翻译结果；每段文本对应一条结果
+	if len(translationResp) != len(texts) {
+		return nil, fmt.Errorf("翻译响应为空")
 	}
+	results := make([]TranslationResult, len(texts))
+	for i, item := range translationResp {
+		if len(item.Translations) == 0 {
+			return nil, fmt.Errorf("翻译响应为空")
+		}
+		results[i] = item.Translations[0]
+		results[i].DetectedLanguage = item.DetectedLanguage
+	}
+	return results, nil
+}
 
-	// 返回翻译后的文本
-	return translationResp[0].Translations[0].Text, nil
+// printTranslationResult 输出翻译结果及服务返回的附加信息
+func printTranslationResult(result TranslationResult) {
+	fmt.Printf("译文: %s\n", result.Text)
+	if result.DetectedLanguage != nil {
+		fmt.Printf("检测到的语言: %s（置信度 %.2f）\n", result.DetectedLanguage.Language, result.DetectedLanguage.Score)
+	}
+	if result.Transliteration != nil {
+		fmt.Printf("音译（%s）: %s\n", result.Transliteration.Script, result.Transliteration.Text)
+	}
+	if result.Alignment != nil {
+		fmt.Printf("对齐: %s\n", result.Alignment.Proj)
+	}
+	if result.SentLen != nil {
+		fmt.Printf("句子长度: 原文 %v，译文 %v\n", result.SentLen.SrcSentLen, result.SentLen.TransSentLen)
+	}
+}
+
+// DetectionResult 表示语言检测的结果
+type DetectionResult struct {
+	Language                   string  `json:"language"`                   // 检测到的语言代码
+	Score                      float64 `json:"score"`                      // 置信度，0~1
+	IsTranslationSupported     bool    `json:"isTranslationSupported"`     // 是否支持翻译
+	IsTransliterationSupported bool    `json:"isTransliterationSupported"` // 是否支持音译
+}
+
+// detectLanguages 检测每段文本的语言，结果顺序与输入一致
+// 单次请求最多 100 段，超出时自动拆分
+func detectLanguages(ctx context.Context, texts []string, client *translatorClient) ([]DetectionResult, error) {
+	results := make([]DetectionResult, 0, len(texts))
+	for start := 0; start < len(texts); start += maxDetectElements {
+		end := min(start+maxDetectElements, len(texts))
+		body := make([]TranslationRequest, end-start)
+		for i, text := range texts[start:end] {
+			body[i] = TranslationRequest{Text: text}
+		}
+
+		query := url.Values{}
+		query.Set("api-version", "3.0")
+		respBytes, err := client.post(ctx, "/detect", query, body)
+		if err != nil {
+			return nil, err
+		}
+
+		var detected []DetectionResult
+		if err := json.Unmarshal(respBytes, &detected); err != nil {
+			return nil, fmt.Errorf("解析JSON响应失败: %v", err)
+		}
+		if len(detected) != end-start {
+			return nil, fmt.Errorf("语言检测响应条数不匹配")
+		}
+		results = append(results, detected...)
+	}
+	return results, nil
 }
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// newStubTranslator 启动一个本地翻译服务桩，把每段文本翻译为 "[目标语言]原文"
+func newStubTranslator(t *testing.T, check func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		var body []TranslationRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to := r.URL.Query().Get("to")
+		resp := make([]map[string]any, len(body))
+		for i, item := range body {
+			resp[i] = map[string]any{
+				"translations": []map[string]string{{"text": fmt.Sprintf("[%s]%s", to, item.Text), "to": to}},
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newStubClient 返回连接到翻译服务桩的客户端，不重试
+func newStubClient(t *testing.T) *translatorClient {
+	t.Helper()
+	server := newStubTranslator(t, nil)
+	client, err := newTranslatorClient(TranslatorConfig{Endpoint: server.URL, SubscriptionKey: "k", MaxRetries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestTranslateTextWithCustomEndpoint(t *testing.T) {
+	server := newStubTranslator(t, func(r *http.Request) {
+		if r.URL.Path != "/translate" {
+			t.Errorf("请求路径错误: %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Ocp-Apim-Subscription-Key"); got != "test-key" {
+			t.Errorf("订阅密钥错误: %q", got)
+		}
+		if got := r.Header.Get("Ocp-Apim-Subscription-Region"); got != "eastasia" {
+			t.Errorf("区域错误: %q", got)
+		}
+		if got := r.URL.Query().Get("from"); got != "en" {
+			t.Errorf("源语言错误: %q", got)
+		}
+	})
+
+	client, err := newTranslatorClient(TranslatorConfig{Endpoint: server.URL, SubscriptionKey: "test-key", Region: "eastasia"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := translateText(context.Background(), "Hello", "ko", TranslateOptions{From: "en"}, client)
+	if err != nil {
+		t.Fatalf("翻译失败: %v", err)
+	}
+	if got != "[ko]Hello" {
+		t.Errorf("译文错误: %q", got)
+	}
+}
+
+// staticToken 是测试用的固定令牌凭据
+type staticToken string
+
+func (s staticToken) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if len(opts.Scopes) != 1 || opts.Scopes[0] != cognitiveServicesScope {
+		return azcore.AccessToken{}, fmt.Errorf("作用域错误: %v", opts.Scopes)
+	}
+	return azcore.AccessToken{Token: string(s), ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestTranslateWithEntraToken(t *testing.T) {
+	server := newStubTranslator(t, func(r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token-123" {
+			t.Errorf("Authorization 错误: %q", got)
+		}
+		if got := r.Header.Get("Ocp-Apim-ResourceId"); got != "/subscriptions/x/resource" {
+			t.Errorf("资源ID错误: %q", got)
+		}
+		if r.Header.Get("Ocp-Apim-Subscription-Key") != "" {
+			t.Error("使用令牌认证时不应发送订阅密钥")
+		}
+	})
+
+	client, err := newTranslatorClient(TranslatorConfig{
+		Endpoint:   server.URL,
+		Credential: staticToken("token-123"),
+		ResourceID: "/subscriptions/x/resource",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := translateText(context.Background(), "Hello", "ja", TranslateOptions{}, client); err != nil {
+		t.Fatalf("翻译失败: %v", err)
+	}
+}
+
+func TestTranslatorErrorIsTyped(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RequestId", "req-1")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400036,"message":"The target language is not valid."}}`))
+	}))
+	defer server.Close()
+
+	client, _ := newTranslatorClient(TranslatorConfig{Endpoint: server.URL, SubscriptionKey: "k"})
+	_, err := translateText(context.Background(), "Hello", "xx", TranslateOptions{}, client)
+
+	var apiErr *TranslatorError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("期望 TranslatorError，实际为 %v", err)
+	}
+	if apiErr.Code != 400036 || apiErr.RequestID != "req-1" {
+		t.Errorf("错误内容解析不正确: %+v", apiErr)
+	}
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("期望归类为 ErrInvalidRequest")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("400 错误不应重试，实际请求 %d 次", calls.Load())
+	}
+}
+
+func TestTranslatorRetriesOn429(t *testing.T) {
+	var calls atomic.Int32
+	stub := newStubTranslator(t, nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"code":429001,"message":"rate limited"}}`))
+			return
+		}
+		stub.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	client, _ := newTranslatorClient(TranslatorConfig{Endpoint: server.URL, SubscriptionKey: "k"})
+	got, err := translateText(context.Background(), "Hello", "de", TranslateOptions{}, client)
+	if err != nil {
+		t.Fatalf("重试后仍失败: %v", err)
+	}
+	if got != "[de]Hello" || calls.Load() != 2 {
+		t.Errorf("译文 %q，请求 %d 次", got, calls.Load())
+	}
+
+	// 关闭重试时直接返回限流错误
+	calls.Store(0)
+	noRetry, _ := newTranslatorClient(TranslatorConfig{Endpoint: server.URL, SubscriptionKey: "k", MaxRetries: -1})
+	if _, err := translateText(context.Background(), "Hello", "de", TranslateOptions{}, noRetry); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("期望 ErrRateLimited，实际为 %v", err)
+	}
+}
+
+func TestTranslateDetailedOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		for key, want := range map[string]string{
+			"profanityAction":       "Marked",
+			"profanityMarker":       "Tag",
+			"includeAlignment":      "true",
+			"includeSentenceLength": "true",
+			"category":              "custom-model",
+			"toScript":              "Latn",
+		} {
+			if got := q.Get(key); got != want {
+				t.Errorf("参数 %s 错误: %q", key, got)
+			}
+		}
+		w.Write([]byte(`[{"detectedLanguage":{"language":"en","score":0.98},"translations":[{"text":"こんにちは","to":"ja",` +
+			`"transliteration":{"text":"konnichiwa","script":"Latn"},"alignment":{"proj":"0:4-0:4"},` +
+			`"sentLen":{"srcSentLen":[5],"transSentLen":[5]}}]}]`))
+	}))
+	defer server.Close()
+
+	client, _ := newTranslatorClient(TranslatorConfig{Endpoint: server.URL, SubscriptionKey: "k"})
+	opts := TranslateOptions{
+		ProfanityAction:       "Marked",
+		ProfanityMarker:       "Tag",
+		IncludeAlignment:      true,
+		IncludeSentenceLength: true,
+		Category:              "custom-model",
+		ToScript:              "Latn",
+	}
+	results, err := translateDetailed(context.Background(), []string{"Hello"}, "ja", opts, client)
+	if err != nil {
+		t.Fatalf("翻译失败: %v", err)
+	}
+	r := results[0]
+	if r.DetectedLanguage == nil || r.DetectedLanguage.Language != "en" {
+		t.Errorf("缺少检测到的语言: %+v", r)
+	}
+	if r.Transliteration == nil || r.Transliteration.Text != "konnichiwa" || r.Alignment == nil || r.SentLen == nil {
+		t.Errorf("附加信息解析不正确: %+v", r)
+	}
+	if key := opts.cacheKey(); key != "textType=plain&profanityAction=Marked&profanityMarker=Tag&category=custom-model" {
+		t.Errorf("缓存键错误: %s", key)
+	}
+}
+
+func TestTranslatorErrorCodes(t *testing.T) {
+	cases := []struct {
+		status, code int
+		want         error
+	}{
+		{403, 403001, ErrQuotaExceeded},
+		{403, 403000, ErrForbidden},
+		{403, 0, ErrForbidden},
+		{401, 401000, ErrUnauthorized},
+		{401, 401015, ErrUnauthorized},
+		{400, 400036, ErrInvalidRequest},
+		{415, 415000, ErrInvalidRequest},
+		{429, 429001, ErrRateLimited},
+		{408, 408001, ErrServiceUnavailable},
+		{500, 500000, ErrServiceUnavailable},
+		{503, 0, ErrServiceUnavailable},
+		{400, 0, ErrInvalidRequest},
+	}
+	for _, c := range cases {
+		err := &TranslatorError{StatusCode: c.status, Code: c.code}
+		if !errors.Is(err, c.want) {
+			t.Errorf("HTTP %d 错误码 %d 归类为 %v，期望 %v", c.status, c.code, err.Unwrap(), c.want)
+		}
+	}
+}
+
+func TestRetryAfterIsCapped(t *testing.T) {
+	if got := retryDelay(1, &TranslatorError{StatusCode: 429, RetryAfter: time.Hour}); got != maxRetryDelay {
+		t.Errorf("Retry-After 1 小时时等待 %v", got)
+	}
+	if got := retryDelay(1, &TranslatorError{StatusCode: 429, RetryAfter: 2 * time.Second}); got != 2*time.Second {
+		t.Errorf("Retry-After 2 秒时等待 %v", got)
+	}
+	if got := retryDelay(40, errors.New("network")); got < maxRetryDelay || got > maxRetryDelay*5/4 {
+		t.Errorf("多次重试后等待 %v", got)
+	}
+}
+
+func TestTranslateStopsWhenContextCanceled(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, _ := newTranslatorClient(TranslatorConfig{Endpoint: server.URL, SubscriptionKey: "k"})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := translateText(ctx, "Hello", "de", TranslateOptions{}, client); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("期望 context.DeadlineExceeded，实际为 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second || calls.Load() != 1 {
+		t.Errorf("取消后仍在等待重试：耗时 %v，请求 %d 次", elapsed, calls.Load())
+	}
+}
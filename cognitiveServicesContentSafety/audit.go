@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 复核状态
+const (
+	ReviewPending  = "pending"  // 等待人工复核
+	ReviewApproved = "approved" // 复核通过，内容放行
+	ReviewRejected = "rejected" // 复核拒绝，内容拦截
+)
+
+// auditLog 表示基于 SQLite 的审核日志和人工复核队列
+// 每次审核决定都会记录内容哈希、各类别严重程度、策略版本和时间；决定为 review 的内容进入复核队列
+type auditLog struct {
+	db *sql.DB
+}
+
+// 数据库被其他连接锁定时等待的毫秒数；服务模式下并发的审核请求和复核命令会同时写入
+const auditBusyTimeout = 5000
+
+// openAuditLog 打开（必要时创建）审核日志
+func openAuditLog(path string) (*auditLog, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d", path, auditBusyTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("打开审核日志失败: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS decisions (
+			id             INTEGER PRIMARY KEY AUTOINCREMENT,
+			content_hash   TEXT NOT NULL,
+			content        TEXT,
+			channel        TEXT NOT NULL,
+			decision       TEXT NOT NULL,
+			policy_version TEXT NOT NULL,
+			scores         TEXT NOT NULL,
+			triggered      TEXT NOT NULL,
+			blocklist_hits TEXT NOT NULL,
+			review_status  TEXT NOT NULL DEFAULT '',
+			created_at     TIMESTAMP NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_decisions_review ON decisions (review_status);
+		CREATE INDEX IF NOT EXISTS idx_decisions_hash ON decisions (content_hash);
+		CREATE TABLE IF NOT EXISTS reviews (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			decision_id INTEGER NOT NULL REFERENCES decisions (id),
+			status      TEXT NOT NULL,
+			reviewer    TEXT NOT NULL,
+			notes       TEXT NOT NULL,
+			created_at  TIMESTAMP NOT NULL
+		);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化审核日志失败: %v", err)
+	}
+	return &auditLog{db: db}, nil
+}
+
+// Close 关闭审核日志
+func (a *auditLog) Close() error {
+	return a.db.Close()
+}
+
+// contentHash 返回内容的 SHA-256 哈希
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// record 记录一次审核决定，返回记录ID
+// 日志中只保存内容哈希；进入复核队列的内容同时保存原文，供复核人员查看
+func (a *auditLog) record(decision *ModerationDecision, text string) (int64, error) {
+	scores := map[string]Severity{}
+	if decision.Result != nil {
+		for _, analysis := range decision.Result.CategoriesAnalysis {
+			scores[analysis.Category] = analysis.Severity
+		}
+	}
+	scoresJSON, _ := json.Marshal(scores)
+	triggeredJSON, _ := json.Marshal(decision.Categories)
+	hitsJSON, _ := json.Marshal(decision.BlocklistHits)
+
+	var content sql.NullString
+	status := ""
+	if decision.Decision == DecisionReview {
+		content = sql.NullString{String: text, Valid: true}
+		status = ReviewPending
+	}
+	res, err := a.db.Exec(`INSERT INTO decisions (content_hash, content, channel, decision, policy_version, scores, triggered, blocklist_hits, review_status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		contentHash(text), content, decision.Channel, string(decision.Decision), decision.PolicyVersion,
+		string(scoresJSON), string(triggeredJSON), string(hitsJSON), status, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("写入审核日志失败: %v", err)
+	}
+	return res.LastInsertId()
+}
+
+// AuditEntry 表示审核日志中的一条记录
+type AuditEntry struct {
+	ID            int64               `json:"id"`
+	ContentHash   string              `json:"contentHash"`
+	Content       string              `json:"content,omitempty"`
+	Channel       string              `json:"channel,omitempty"`
+	Decision      Decision            `json:"decision"`
+	PolicyVersion string              `json:"policyVersion"`
+	Scores        map[string]Severity `json:"scores"`
+	ReviewStatus  string              `json:"reviewStatus,omitempty"`
+	CreatedAt     time.Time           `json:"createdAt"`
+	Reviews       []ReviewEntry       `json:"reviews,omitempty"`
+}
+
+// ReviewEntry 表示一次人工复核
+type ReviewEntry struct {
+	Status    string    `json:"status"`
+	Reviewer  string    `json:"reviewer"`
+	Notes     string    `json:"notes,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// listReviews 按复核状态列出记录，status 为空时列出所有进入过复核队列的记录
+func (a *auditLog) listReviews(status string, limit int) ([]AuditEntry, error) {
+	query := `SELECT id, content_hash, COALESCE(content, ''), channel, decision, policy_version, scores, review_status, created_at
+		FROM decisions WHERE review_status != '' AND (? = '' OR review_status = ?) ORDER BY id LIMIT ?`
+	rows, err := a.db.Query(query, status, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询复核队列失败: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var decision, scores string
+		if err := rows.Scan(&entry.ID, &entry.ContentHash, &entry.Content, &entry.Channel, &decision,
+			&entry.PolicyVersion, &scores, &entry.ReviewStatus, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取复核队列失败: %v", err)
+		}
+		entry.Decision = Decision(decision)
+		if err := json.Unmarshal([]byte(scores), &entry.Scores); err != nil {
+			return nil, fmt.Errorf("解析记录 %d 的严重程度失败: %v", entry.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Reviews, err = a.reviewHistory(entries[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// reviewHistory 返回一条记录的所有复核操作
+func (a *auditLog) reviewHistory(id int64) ([]ReviewEntry, error) {
+	rows, err := a.db.Query(`SELECT status, reviewer, notes, created_at FROM reviews WHERE decision_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("查询复核记录失败: %v", err)
+	}
+	defer rows.Close()
+	var reviews []ReviewEntry
+	for rows.Next() {
+		var review ReviewEntry
+		if err := rows.Scan(&review.Status, &review.Reviewer, &review.Notes, &review.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取复核记录失败: %v", err)
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+// review 记录复核结果；每次操作都会保留，已复核的记录可以再次复核以更正结论
+func (a *auditLog) review(id int64, status, reviewer, notes string) error {
+	if status != ReviewApproved && status != ReviewRejected {
+		return fmt.Errorf("无效的复核结果: %s", status)
+	}
+	if reviewer == "" {
+		return fmt.Errorf("必须指定复核人")
+	}
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE decisions SET review_status = ? WHERE id = ? AND review_status != ''`, status, id)
+	if err != nil {
+		return fmt.Errorf("更新复核状态失败: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("记录 %d 不存在或不在复核队列中", id)
+	}
+	if _, err := tx.Exec(`INSERT INTO reviews (decision_id, status, reviewer, notes, created_at) VALUES (?, ?, ?, ?, ?)`,
+		id, status, reviewer, notes, time.Now().UTC()); err != nil {
+		return fmt.Errorf("写入复核记录失败: %v", err)
+	}
+	return tx.Commit()
+}
+
+// printAuditEntries 打印复核队列
+func printAuditEntries(entries []AuditEntry) {
+	if len(entries) == 0 {
+		fmt.Println("复核队列为空")
+		return
+	}
+	for _, entry := range entries {
+		fmt.Printf("\n#%d [%s] %s 渠道: %s 策略版本: %s\n", entry.ID, entry.ReviewStatus,
+			entry.CreatedAt.Local().Format("2006-01-02 15:04:05"), entry.Channel, entry.PolicyVersion)
+		fmt.Printf("内容: %s\n", entry.Content)
+		fmt.Printf("严重程度: %v\n", entry.Scores)
+		for _, review := range entry.Reviews {
+			fmt.Printf("- %s %s 由 %s: %s\n", review.CreatedAt.Local().Format("2006-01-02 15:04:05"), review.Status, review.Reviewer, review.Notes)
+		}
+	}
+}
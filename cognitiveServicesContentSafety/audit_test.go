@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// openTestAuditLog 在临时目录中创建审核日志
+func openTestAuditLog(t *testing.T, path string) *auditLog {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "audit.db")
+	}
+	audit, err := openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { audit.Close() })
+	return audit
+}
+
+// testDecision 构造一个审核决定
+func testDecision(decision Decision, hate Severity) *ModerationDecision {
+	return &ModerationDecision{
+		Decision:      decision,
+		Channel:       "forum",
+		PolicyVersion: "v1",
+		Result:        &ContentSafetyResponse{CategoriesAnalysis: []CategoryAnalysis{{Category: CategoryHate, Severity: hate}}},
+	}
+}
+
+func TestAuditRecordAndReview(t *testing.T) {
+	audit := openTestAuditLog(t, "")
+	allowID, err := audit.record(testDecision(DecisionAllow, 0), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reviewID, err := audit.record(testDecision(DecisionReview, 2), "borderline text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有 review 的决定进入复核队列，且只有它保存原文
+	pending, err := audit.listReviews(ReviewPending, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != reviewID || pending[0].Content != "borderline text" ||
+		pending[0].ContentHash != contentHash("borderline text") || pending[0].Channel != "forum" ||
+		!reflect.DeepEqual(pending[0].Scores, map[string]Severity{CategoryHate: 2}) {
+		t.Fatalf("复核队列 = %+v", pending)
+	}
+	var content string
+	audit.db.QueryRow(`SELECT COALESCE(content, '') FROM decisions WHERE id = ?`, allowID).Scan(&content)
+	if content != "" {
+		t.Errorf("放行的内容不应保存原文: %q", content)
+	}
+
+	// 复核并更正结论，每次操作都保留
+	if err := audit.review(reviewID, ReviewApproved, "alice", "ok"); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit.review(reviewID, ReviewRejected, "bob", "actually not ok"); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := audit.listReviews(ReviewPending, 10); len(pending) != 0 {
+		t.Errorf("复核后仍在待复核队列中: %+v", pending)
+	}
+	all, err := audit.listReviews("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].ReviewStatus != ReviewRejected || len(all[0].Reviews) != 2 || all[0].Reviews[0].Reviewer != "alice" {
+		t.Errorf("复核记录 = %+v", all)
+	}
+
+	for _, c := range []struct {
+		name             string
+		id               int64
+		status, reviewer string
+		want             string
+	}{
+		{"不在复核队列中", allowID, ReviewApproved, "alice", "不在复核队列中"},
+		{"记录不存在", 999, ReviewApproved, "alice", "不存在"},
+		{"无效的复核结果", reviewID, ReviewPending, "alice", "无效的复核结果"},
+		{"缺少复核人", reviewID, ReviewApproved, "", "复核人"},
+	} {
+		if err := audit.review(c.id, c.status, c.reviewer, ""); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: 错误 = %v", c.name, err)
+		}
+	}
+}
+
+func TestAuditListReportsCorruptScores(t *testing.T) {
+	audit := openTestAuditLog(t, "")
+	id, err := audit.record(testDecision(DecisionReview, 4), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit.db.Exec(`UPDATE decisions SET scores = 'not json' WHERE id = ?`, id)
+	if _, err := audit.listReviews("", 10); err == nil || !strings.Contains(err.Error(), fmt.Sprint(id)) {
+		t.Errorf("严重程度无法解析时应返回错误: %v", err)
+	}
+}
+
+func TestAuditConcurrentWrites(t *testing.T) {
+	// 两个连接同时写入同一个审核日志，等待锁而不是返回 database is locked
+	path := filepath.Join(t.TempDir(), "audit.db")
+	a, b := openTestAuditLog(t, path), openTestAuditLog(t, path)
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, audit := range []*auditLog{a, b} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if _, err := audit.record(testDecision(DecisionReview, 2), fmt.Sprintf("text %d", i)); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if entries, err := a.listReviews(ReviewPending, 1000); err != nil || len(entries) != 100 {
+		t.Errorf("记录 %d 条: %v", len(entries), err)
+	}
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 批量审核输出目录中的文件
+const (
+	batchResultsFile     = "results.jsonl" // 逐条追加的结果，同时用于断点续跑
+	batchOptionsFile     = "options.json"  // 产生这些结果的参数，续跑时必须一致
+	batchResultsCSVFile  = "results.csv"
+	batchSummaryFile     = "summary.json"
+	batchSummaryCSVFile  = "summary.csv"
+	defaultBatchRetries  = 3
+	batchDirectoryMaxLen = 1 << 20 // 目录模式下单个文件的大小上限
+)
+
+// BatchOptions 表示批量审核的参数
+type BatchOptions struct {
+	Input     string             // JSONL 或 CSV 文件，或包含文本文件的目录
+	OutputDir string             // 结果输出目录
+	IDField   string             // JSONL/CSV 中记录ID的字段名，默认 id
+	TextField string             // JSONL/CSV 中文本的字段名，默认 text
+	Workers   int                // 并发数
+	Retries   int                // 每条记录遇到限流、服务端错误或网络错误时的重试次数，负数表示不重试
+	Analyze   AnalyzeTextOptions // 文本分析参数
+}
+
+// batchRunOptions 是影响审核结果的参数，保存在输出目录中；并发数和重试次数不影响结果，不保存
+type batchRunOptions struct {
+	Input     string             `json:"input"`
+	IDField   string             `json:"idField"`
+	TextField string             `json:"textField"`
+	Analyze   AnalyzeTextOptions `json:"analyze"`
+}
+
+// batchRecord 表示一条待审核的记录
+type batchRecord struct {
+	ID   string
+	Text string
+}
+
+// BatchResult 表示一条记录的审核结果
+type BatchResult struct {
+	ID            string              `json:"id"`
+	Categories    map[string]Severity `json:"categories,omitempty"`
+	BlocklistHits []BlocklistMatch    `json:"blocklistHits,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	Attempts      int                 `json:"attempts"`
+}
+
+// BatchSummary 表示批量审核的汇总报告
+type BatchSummary struct {
+	Total        int                       `json:"total"`
+	Succeeded    int                       `json:"succeeded"`
+	Failed       int                       `json:"failed"`
+	BlocklistHit int                       `json:"blocklistHit"` // 命中黑名单的记录数
+	Categories   map[string]map[string]int `json:"categories"`   // 类别 -> 严重程度 -> 记录数
+}
+
+// readBatchRecords 按输入类型读取记录
+func readBatchRecords(opts BatchOptions) ([]batchRecord, error) {
+	info, err := os.Stat(opts.Input)
+	if err != nil {
+		return nil, fmt.Errorf("读取输入失败: %v", err)
+	}
+	if info.IsDir() {
+		return readDirectoryRecords(opts.Input, opts.OutputDir)
+	}
+	var records []batchRecord
+	switch strings.ToLower(filepath.Ext(opts.Input)) {
+	case ".jsonl", ".ndjson":
+		records, err = readJSONLRecords(opts.Input, opts.IDField, opts.TextField)
+	case ".csv":
+		records, err = readCSVRecords(opts.Input, opts.IDField, opts.TextField)
+	default:
+		return nil, fmt.Errorf("不支持的输入格式: %s，请使用 .jsonl、.csv 或目录", opts.Input)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 结果按ID记录，续跑时依赖ID唯一
+	seen := make(map[string]bool, len(records))
+	for _, record := range records {
+		if seen[record.ID] {
+			return nil, fmt.Errorf("记录ID重复: %s", record.ID)
+		}
+		seen[record.ID] = true
+	}
+	return records, nil
+}
+
+// readJSONLRecords 读取 JSONL 文件，每行一个 JSON 对象；没有ID字段时使用行号
+func readJSONLRecords(path, idField, textField string) ([]batchRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开输入文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var records []batchRecord
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(data))) > 0 {
+			var obj map[string]any
+			decoder := json.NewDecoder(strings.NewReader(string(data)))
+			decoder.UseNumber()
+			if jsonErr := decoder.Decode(&obj); jsonErr != nil {
+				return nil, fmt.Errorf("第 %d 行不是有效的JSON: %v", line, jsonErr)
+			}
+			text, _ := obj[textField].(string)
+			id := fmt.Sprint(obj[idField])
+			if obj[idField] == nil {
+				id = strconv.Itoa(line)
+			}
+			records = append(records, batchRecord{ID: id, Text: text})
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取输入文件失败: %v", err)
+		}
+	}
+	return records, nil
+}
+
+// readCSVRecords 读取带表头的 CSV 文件；没有ID列时使用行号
+func readCSVRecords(path, idField, textField string) ([]batchRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开输入文件失败: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取CSV表头失败: %v", err)
+	}
+	idCol, textCol := -1, -1
+	for i, name := range header {
+		switch strings.TrimSpace(name) {
+		case idField:
+			idCol = i
+		case textField:
+			textCol = i
+		}
+	}
+	if textCol < 0 {
+		return nil, fmt.Errorf("CSV中没有文本列 %s", textField)
+	}
+
+	var records []batchRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析CSV失败: %v", err)
+		}
+		record := batchRecord{ID: strconv.Itoa(line)}
+		if idCol >= 0 && idCol < len(row) {
+			record.ID = row[idCol]
+		}
+		if textCol < len(row) {
+			record.Text = row[textCol]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// readDirectoryRecords 把目录（含子目录）下的每个文件作为一条记录，ID 为相对路径；
+// 输出目录位于输入目录中时跳过，避免把上一次的结果当作待审核的文本
+func readDirectoryRecords(dir, outputDir string) ([]batchRecord, error) {
+	skip := ""
+	if outputDir != "" {
+		skip, _ = filepath.Abs(outputDir)
+	}
+	var records []batchRecord
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if abs, _ := filepath.Abs(path); abs == skip && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > batchDirectoryMaxLen {
+			return fmt.Errorf("文件 %s 超过 %d 字节", path, batchDirectoryMaxLen)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		records = append(records, batchRecord{ID: filepath.ToSlash(rel), Text: string(data)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %v", err)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
+
+// loadBatchResults 读取已有的结果，用于断点续跑；文件不存在时返回空结果
+func loadBatchResults(path string) (map[string]BatchResult, error) {
+	results := map[string]BatchResult{}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return results, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开已有结果失败: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var result BatchResult
+		// 中断时最后一行可能不完整，忽略无法解析的行
+		if json.Unmarshal(scanner.Bytes(), &result) == nil {
+			results[result.ID] = result
+		}
+	}
+	return results, scanner.Err()
+}
+
+// saveBatchOptions 把影响结果的参数写入输出目录；续跑时参数与已有结果不一致则返回错误，避免混入按不同参数审核的结果
+func saveBatchOptions(opts BatchOptions, resuming bool) error {
+	input, err := filepath.Abs(opts.Input)
+	if err != nil {
+		return fmt.Errorf("解析输入路径失败: %v", err)
+	}
+	current, err := json.MarshalIndent(batchRunOptions{input, opts.IDField, opts.TextField, opts.Analyze}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化批量审核参数失败: %v", err)
+	}
+	path := filepath.Join(opts.OutputDir, batchOptionsFile)
+	if data, err := os.ReadFile(path); err == nil && resuming {
+		// 重新序列化已保存的参数，忽略格式差异
+		var saved batchRunOptions
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("解析 %s 失败: %v", path, err)
+		}
+		previous, _ := json.MarshalIndent(saved, "", "  ")
+		if !bytes.Equal(previous, current) {
+			return fmt.Errorf("%s 中已有按不同参数审核的结果:\n%s\n请使用新的输出目录，或删除已有结果后重新审核", opts.OutputDir, previous)
+		}
+		return nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("读取 %s 失败: %v", path, err)
+	}
+	if err := os.WriteFile(path, current, 0644); err != nil {
+		return fmt.Errorf("写入 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// runBatch 批量审核记录并生成报告；已成功的记录在续跑时会被跳过，失败的记录会重新审核
+func runBatch(endpoint, apiKey string, opts BatchOptions) (*BatchSummary, error) {
+	if opts.IDField == "" {
+		opts.IDField = "id"
+	}
+	if opts.TextField == "" {
+		opts.TextField = "text"
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaultChunkWorkers
+	}
+	if opts.Retries == 0 {
+		opts.Retries = defaultBatchRetries
+	} else if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+
+	records, err := readBatchRecords(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	resultsPath := filepath.Join(opts.OutputDir, batchResultsFile)
+	done, err := loadBatchResults(resultsPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveBatchOptions(opts, len(done) > 0); err != nil {
+		return nil, err
+	}
+
+	var pending []batchRecord
+	for _, record := range records {
+		if result, ok := done[record.ID]; !ok || result.Error != "" {
+			pending = append(pending, record)
+		}
+	}
+	if skipped := len(records) - len(pending); skipped > 0 {
+		fmt.Printf("跳过已完成的 %d 条记录\n", skipped)
+	}
+
+	out, err := os.OpenFile(resultsPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开结果文件失败: %v", err)
+	}
+	defer out.Close()
+	// 中断时最后一行可能没有写完，先换行再继续追加
+	if data, err := os.ReadFile(resultsPath); err == nil && len(data) > 0 && data[len(data)-1] != '\n' {
+		out.Write([]byte{'\n'})
+	}
+
+	// 每完成一条立即追加到结果文件，中断后可以续跑
+	var mu sync.Mutex
+	var writeErr error
+	completed := 0
+	jobs := make(chan batchRecord)
+	var wg sync.WaitGroup
+	for w := 0; w < min(opts.Workers, len(pending)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for record := range jobs {
+				result := moderateRecord(endpoint, apiKey, record, opts)
+				line, _ := json.Marshal(result)
+
+				mu.Lock()
+				if _, err := out.Write(append(line, '\n')); err != nil && writeErr == nil {
+					writeErr = err
+				}
+				done[result.ID] = result
+				completed++
+				if completed%100 == 0 || completed == len(pending) {
+					fmt.Printf("进度: %d/%d\n", completed, len(pending))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, record := range pending {
+		jobs <- record
+	}
+	close(jobs)
+	wg.Wait()
+	if writeErr != nil {
+		return nil, fmt.Errorf("写入结果失败: %v", writeErr)
+	}
+
+	// 按输入顺序输出最终结果和汇总报告
+	ordered := make([]BatchResult, 0, len(records))
+	for _, record := range records {
+		ordered = append(ordered, done[record.ID])
+	}
+	summary := summarizeBatch(ordered)
+	if err := writeBatchReports(opts.OutputDir, ordered, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// moderateRecord 审核一条记录，遇到可重试的错误时按指数退避重试
+func moderateRecord(endpoint, apiKey string, record batchRecord, opts BatchOptions) BatchResult {
+	result := BatchResult{ID: record.ID}
+	if strings.TrimSpace(record.Text) == "" {
+		result.Error = "文本为空"
+		return result
+	}
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			delay := time.Duration(1<<(attempt-1)) * time.Second
+			time.Sleep(delay + time.Duration(rand.Int63n(int64(delay/2))))
+		}
+		result.Attempts = attempt + 1
+		resp, err := analyzeText(endpoint, apiKey, record.Text, opts.Analyze)
+		if err == nil {
+			result.Error = ""
+			result.Categories = make(map[string]Severity, len(resp.CategoriesAnalysis))
+			for _, analysis := range resp.CategoriesAnalysis {
+				result.Categories[analysis.Category] = analysis.Severity
+			}
+			result.BlocklistHits = resp.BlocklistsMatch
+			return result
+		}
+		result.Error = err.Error()
+		if !retryable(err) {
+			break
+		}
+	}
+	return result
+}
+
+// retryable 判断错误是否值得重试：限流、服务端错误和网络错误（连接失败、超时、读取响应时连接中断）
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// summarizeBatch 按类别和严重程度统计记录数
+func summarizeBatch(results []BatchResult) *BatchSummary {
+	summary := &BatchSummary{Total: len(results), Categories: map[string]map[string]int{}}
+	for _, result := range results {
+		if result.Error != "" {
+			summary.Failed++
+			continue
+		}
+		summary.Succeeded++
+		if len(result.BlocklistHits) > 0 {
+			summary.BlocklistHit++
+		}
+		for category, severity := range result.Categories {
+			if summary.Categories[category] == nil {
+				summary.Categories[category] = map[string]int{}
+			}
+			summary.Categories[category][strconv.Itoa(int(severity))]++
+		}
+	}
+	return summary
+}
+
+// writeBatchReports 输出逐条结果（CSV）和汇总报告（JSON 和 CSV）
+func writeBatchReports(dir string, results []BatchResult, summary *BatchSummary) error {
+	var categories []string
+	for category := range summary.Categories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	// 逐条结果：id、各类别严重程度、命中的黑名单项、错误
+	rows := [][]string{append(append([]string{"id"}, categories...), "blocklistHits", "error")}
+	for _, result := range results {
+		row := []string{result.ID}
+		for _, category := range categories {
+			if severity, ok := result.Categories[category]; ok {
+				row = append(row, strconv.Itoa(int(severity)))
+			} else {
+				row = append(row, "")
+			}
+		}
+		var hits []string
+		for _, hit := range result.BlocklistHits {
+			hits = append(hits, hit.BlocklistName+":"+hit.BlocklistItemText)
+		}
+		rows = append(rows, append(row, strings.Join(hits, ";"), result.Error))
+	}
+	if err := writeCSVFile(filepath.Join(dir, batchResultsCSVFile), rows); err != nil {
+		return err
+	}
+
+	// 汇总报告：每个类别、严重程度一行
+	rows = [][]string{{"category", "severity", "count"}}
+	for _, category := range categories {
+		var severities []string
+		for severity := range summary.Categories[category] {
+			severities = append(severities, severity)
+		}
+		sort.Slice(severities, func(i, j int) bool {
+			a, _ := strconv.Atoi(severities[i])
+			b, _ := strconv.Atoi(severities[j])
+			return a < b
+		})
+		for _, severity := range severities {
+			rows = append(rows, []string{category, severity, strconv.Itoa(summary.Categories[category][severity])})
+		}
+	}
+	if err := writeCSVFile(filepath.Join(dir, batchSummaryCSVFile), rows); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("生成汇总报告失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, batchSummaryFile), data, 0644); err != nil {
+		return fmt.Errorf("写入汇总报告失败: %v", err)
+	}
+	return nil
+}
+
+// writeCSVFile 把多行数据写入 CSV 文件
+func writeCSVFile(path string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建 %s 失败: %v", path, err)
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("写入 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// printBatchSummary 打印汇总报告
+func printBatchSummary(summary *BatchSummary, dir string) {
+	fmt.Printf("\n共 %d 条记录，成功 %d 条，失败 %d 条，命中黑名单 %d 条\n",
+		summary.Total, summary.Succeeded, summary.Failed, summary.BlocklistHit)
+	var categories []string
+	for category := range summary.Categories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		fmt.Printf("- %s: %v\n", category, summary.Categories[category])
+	}
+	fmt.Printf("结果已写入 %s\n", dir)
+}
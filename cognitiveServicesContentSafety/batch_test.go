@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRetryable(t *testing.T) {
+	_, connErr := http.Get("http://127.0.0.1:0")
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"限流", &APIError{StatusCode: 429}, true},
+		{"服务端错误", &APIError{StatusCode: 503}, true},
+		{"请求参数错误", &APIError{StatusCode: 400}, false},
+		{"认证失败", &APIError{StatusCode: 401}, false},
+		{"连接失败", fmt.Errorf("发送HTTP请求失败: %w", connErr), true},
+		{"连接中断", fmt.Errorf("读取响应失败: %w", io.ErrUnexpectedEOF), true},
+		{"分块中的限流", fmt.Errorf("分析第 1 个分块失败: %w", &APIError{StatusCode: 429}), true},
+		{"响应无法解析", errors.New("解析响应失败: invalid character"), false},
+	}
+	for _, c := range cases {
+		if got := retryable(c.err); got != c.want {
+			t.Errorf("%s: retryable = %v", c.name, got)
+		}
+	}
+}
+
+// readResults 读取 results.jsonl 中每条记录的最后一次结果
+func readResults(t *testing.T, dir string) map[string]BatchResult {
+	t.Helper()
+	results, err := loadBatchResults(filepath.Join(dir, batchResultsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestRunBatchResumes(t *testing.T) {
+	server, calls := newStubContentSafety(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "comments.jsonl")
+	os.WriteFile(input, []byte(`{"id":"a","text":"I hate it"}
+{"id":"b","text":"invalid request"}
+{"id":"c","text":"fine"}
+{"text":"no id"}
+`), 0644)
+	out := filepath.Join(dir, "out")
+	opts := BatchOptions{Input: input, OutputDir: out, Workers: 2}
+
+	summary, err := runBatch(server.URL, "key", opts)
+	if err != nil {
+		t.Fatalf("批量审核失败: %v", err)
+	}
+	if summary.Total != 4 || summary.Succeeded != 3 || summary.Failed != 1 || summary.Categories[CategoryHate]["4"] != 1 {
+		t.Errorf("汇总 = %+v", summary)
+	}
+	// 400 不重试
+	if results := readResults(t, out); results["b"].Attempts != 1 || results["b"].Error == "" || results["4"].Categories == nil {
+		t.Errorf("结果 = %+v", results)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("请求 %d 次，期望 4 次", calls.Load())
+	}
+
+	// 续跑时只重新审核失败的记录
+	calls.Store(0)
+	if _, err := runBatch(server.URL, "key", opts); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("续跑时请求 %d 次，期望 1 次", calls.Load())
+	}
+	for _, name := range []string{batchResultsCSVFile, batchSummaryFile, batchSummaryCSVFile, batchOptionsFile} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("缺少 %s", name)
+		}
+	}
+
+	// 参数不同时拒绝续跑
+	changed := opts
+	changed.Analyze.OutputType = OutputEightSeverityLevels
+	if _, err := runBatch(server.URL, "key", changed); err == nil || !strings.Contains(err.Error(), "不同参数") {
+		t.Errorf("参数不同时的错误 = %v", err)
+	}
+	changed = opts
+	changed.TextField = "body"
+	if _, err := runBatch(server.URL, "key", changed); err == nil {
+		t.Error("字段名不同时应拒绝续跑")
+	}
+	// 并发数不影响结果
+	opts.Workers = 8
+	if _, err := runBatch(server.URL, "key", opts); err != nil {
+		t.Errorf("修改并发数后续跑失败: %v", err)
+	}
+}
+
+func TestRunBatchDirectorySkipsOutput(t *testing.T) {
+	server, _ := newStubContentSafety(t)
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "posts", "2024"), 0755)
+	os.WriteFile(filepath.Join(dir, "posts", "a.txt"), []byte("hello"), 0644)
+	os.WriteFile(filepath.Join(dir, "posts", "2024", "b.txt"), []byte("kill"), 0644)
+
+	// 输出目录位于输入目录中，两次运行都不应把结果文件当作记录
+	opts := BatchOptions{Input: filepath.Join(dir, "posts"), OutputDir: filepath.Join(dir, "posts", "moderation-results")}
+	for run := 1; run <= 2; run++ {
+		summary, err := runBatch(server.URL, "key", opts)
+		if err != nil {
+			t.Fatalf("第 %d 次运行失败: %v", run, err)
+		}
+		if summary.Total != 2 || summary.Categories[CategoryViolence]["6"] != 1 {
+			data, _ := json.Marshal(summary)
+			t.Errorf("第 %d 次运行的汇总 = %s", run, data)
+		}
+	}
+	if results := readResults(t, opts.OutputDir); len(results) != 2 || results["2024/b.txt"].Error != "" {
+		t.Errorf("结果 = %+v", results)
+	}
+}
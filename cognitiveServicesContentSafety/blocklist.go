@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// 每次添加或删除黑名单项的数量上限
+const maxBlocklistItemsPerRequest = 100
+
+// TextBlocklist 表示一个文本黑名单
+type TextBlocklist struct {
+	BlocklistName string `json:"blocklistName"`
+	Description   string `json:"description"`
+}
+
+// TextBlocklistItem 表示黑名单中的一项
+type TextBlocklistItem struct {
+	BlocklistItemID string `json:"blocklistItemId,omitempty"`
+	Description     string `json:"description"`
+	Text            string `json:"text"`
+}
+
+// blocklistURL 构建黑名单相关接口的地址
+func blocklistURL(endpoint, name, suffix string) string {
+	path := endpoint + "/contentsafety/text/blocklists"
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path + suffix + "?api-version=2023-10-01"
+}
+
+// nextPageURL 把分页响应中的 nextLink 转换为完整地址
+func nextPageURL(endpoint, nextLink string) string {
+	if nextLink == "" || strings.HasPrefix(nextLink, "http") {
+		return nextLink
+	}
+	return strings.TrimRight(endpoint, "/") + "/" + strings.TrimLeft(nextLink, "/")
+}
+
+// createOrUpdateBlocklist 创建黑名单，已存在时更新描述
+func createOrUpdateBlocklist(endpoint, apiKey, name, description string) (*TextBlocklist, error) {
+	var result TextBlocklist
+	payload := map[string]string{"description": description}
+	if err := callContentSafety(http.MethodPatch, blocklistURL(endpoint, name, ""), apiKey, payload, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// listBlocklists 列出所有黑名单
+func listBlocklists(endpoint, apiKey string) ([]TextBlocklist, error) {
+	var lists []TextBlocklist
+	next := blocklistURL(endpoint, "", "")
+	for next != "" {
+		var page struct {
+			Value    []TextBlocklist `json:"value"`
+			NextLink string          `json:"nextLink"`
+		}
+		if err := callContentSafety(http.MethodGet, next, apiKey, nil, &page); err != nil {
+			return nil, err
+		}
+		lists = append(lists, page.Value...)
+		next = nextPageURL(endpoint, page.NextLink)
+	}
+	return lists, nil
+}
+
+// deleteBlocklist 删除黑名单及其中的所有项
+func deleteBlocklist(endpoint, apiKey, name string) error {
+	return callContentSafety(http.MethodDelete, blocklistURL(endpoint, name, ""), apiKey, nil, nil)
+}
+
+// addBlocklistItems 批量添加黑名单项，文本相同的项会被更新；超过 100 项时分多次提交
+func addBlocklistItems(endpoint, apiKey, name string, items []TextBlocklistItem) ([]TextBlocklistItem, error) {
+	var added []TextBlocklistItem
+	apiURL := blocklistURL(endpoint, name, ":addOrUpdateBlocklistItems")
+	for start := 0; start < len(items); start += maxBlocklistItemsPerRequest {
+		end := min(start+maxBlocklistItemsPerRequest, len(items))
+		var result struct {
+			BlocklistItems []TextBlocklistItem `json:"blocklistItems"`
+		}
+		payload := map[string]any{"blocklistItems": items[start:end]}
+		if err := callContentSafety(http.MethodPost, apiURL, apiKey, payload, &result); err != nil {
+			return added, err
+		}
+		added = append(added, result.BlocklistItems...)
+	}
+	return added, nil
+}
+
+// removeBlocklistItems 按项目ID批量删除黑名单项；超过 100 项时分多次提交
+func removeBlocklistItems(endpoint, apiKey, name string, ids []string) error {
+	apiURL := blocklistURL(endpoint, name, ":removeBlocklistItems")
+	for start := 0; start < len(ids); start += maxBlocklistItemsPerRequest {
+		end := min(start+maxBlocklistItemsPerRequest, len(ids))
+		payload := map[string]any{"blocklistItemIds": ids[start:end]}
+		if err := callContentSafety(http.MethodPost, apiURL, apiKey, payload, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listBlocklistItems 列出黑名单中的所有项
+func listBlocklistItems(endpoint, apiKey, name string) ([]TextBlocklistItem, error) {
+	var items []TextBlocklistItem
+	next := blocklistURL(endpoint, name, "/blocklistItems")
+	for next != "" {
+		var page struct {
+			Value    []TextBlocklistItem `json:"value"`
+			NextLink string              `json:"nextLink"`
+		}
+		if err := callContentSafety(http.MethodGet, next, apiKey, nil, &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Value...)
+		next = nextPageURL(endpoint, page.NextLink)
+	}
+	return items, nil
+}
+
+// readBlocklistFile 读取黑名单项文件：每行一项，可用制表符分隔文本和描述；空行和以 # 开头的行会被忽略
+func readBlocklistFile(path string) ([]TextBlocklistItem, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开黑名单文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var items []TextBlocklistItem
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		text, description, _ := strings.Cut(line, "\t")
+		items = append(items, TextBlocklistItem{Text: strings.TrimSpace(text), Description: strings.TrimSpace(description)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取黑名单文件失败: %v", err)
+	}
+	return items, nil
+}
+
+// removeBlocklistItemsFromFile 删除文件中列出的黑名单项，文件格式与添加时相同
+// 每行可以是项目ID，也可以是项目文本；按文本删除时先查询对应的项目ID
+func removeBlocklistItemsFromFile(endpoint, apiKey, name, path string) (int, error) {
+	entries, err := readBlocklistFile(path)
+	if err != nil {
+		return 0, err
+	}
+	existing, err := listBlocklistItems(endpoint, apiKey, name)
+	if err != nil {
+		return 0, err
+	}
+	byText := make(map[string]string, len(existing))
+	byID := make(map[string]bool, len(existing))
+	for _, item := range existing {
+		byText[item.Text] = item.BlocklistItemID
+		byID[item.BlocklistItemID] = true
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		switch {
+		case byID[entry.Text]:
+			ids = append(ids, entry.Text)
+		case byText[entry.Text] != "":
+			ids = append(ids, byText[entry.Text])
+		default:
+			fmt.Printf("黑名单 %s 中没有找到: %s\n", name, entry.Text)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := removeBlocklistItems(endpoint, apiKey, name, ids); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// manageBlocklist 执行针对单个黑名单的命令；黑名单的修改大约需要 5 分钟才会在分析中生效
+func manageBlocklist(endpoint, apiKey, action, name, description, itemsFile string) {
+	switch action {
+	case "blocklist-create":
+		list, err := createOrUpdateBlocklist(endpoint, apiKey, name, description)
+		if err != nil {
+			fmt.Printf("创建黑名单时出错: %v\n", err)
+			return
+		}
+		fmt.Printf("已创建或更新黑名单 %s: %s\n", list.BlocklistName, list.Description)
+	case "blocklist-delete":
+		if err := deleteBlocklist(endpoint, apiKey, name); err != nil {
+			fmt.Printf("删除黑名单时出错: %v\n", err)
+			return
+		}
+		fmt.Printf("已删除黑名单 %s\n", name)
+	case "blocklist-items":
+		items, err := listBlocklistItems(endpoint, apiKey, name)
+		if err != nil {
+			fmt.Printf("查询黑名单项时出错: %v\n", err)
+			return
+		}
+		fmt.Printf("黑名单 %s 共 %d 项:\n", name, len(items))
+		for _, item := range items {
+			fmt.Printf("- 项目ID: %s, 文本: %s, 描述: %s\n", item.BlocklistItemID, item.Text, item.Description)
+		}
+	case "blocklist-add":
+		if itemsFile == "" {
+			fmt.Println("请使用 -file 参数指定黑名单项文件")
+			return
+		}
+		items, err := readBlocklistFile(itemsFile)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		added, err := addBlocklistItems(endpoint, apiKey, name, items)
+		if err != nil {
+			fmt.Printf("添加黑名单项时出错（已添加 %d 项）: %v\n", len(added), err)
+			return
+		}
+		fmt.Printf("已向黑名单 %s 添加或更新 %d 项\n", name, len(added))
+	case "blocklist-remove":
+		if itemsFile == "" {
+			fmt.Println("请使用 -file 参数指定要删除的黑名单项文件")
+			return
+		}
+		removed, err := removeBlocklistItemsFromFile(endpoint, apiKey, name, itemsFile)
+		if err != nil {
+			fmt.Printf("删除黑名单项时出错: %v\n", err)
+			return
+		}
+		fmt.Printf("已从黑名单 %s 删除 %d 项\n", name, removed)
+	}
+}
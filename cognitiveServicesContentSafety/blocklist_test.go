@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubBlocklists 是模拟的黑名单服务，只保存一个名为 words 的黑名单；分页时每页 pageSize 项
+type stubBlocklists struct {
+	mu        sync.Mutex
+	items     []TextBlocklistItem
+	pageSize  int
+	batches   []int // 每次添加或删除请求中的项数
+	removed   []string
+	nextID    int
+	pageLoads int
+}
+
+func newStubBlocklists(t *testing.T, pageSize int) (*httptest.Server, *stubBlocklists) {
+	t.Helper()
+	stub := &stubBlocklists{pageSize: pageSize}
+	server := httptest.NewServer(http.HandlerFunc(stub.handle))
+	t.Cleanup(server.Close)
+	return server, stub
+}
+
+func (s *stubBlocklists) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	const prefix = "/contentsafety/text/blocklists"
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodGet && path == prefix:
+		// 第二页使用相对地址的 nextLink
+		if r.URL.Query().Get("page") == "" {
+			json.NewEncoder(w).Encode(map[string]any{
+				"value":    []TextBlocklist{{BlocklistName: "words"}},
+				"nextLink": strings.TrimPrefix(prefix, "/") + "?api-version=2023-10-01&page=2",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"value": []TextBlocklist{{BlocklistName: "names"}}})
+	case r.Method == http.MethodGet && path == prefix+"/words/blocklistItems":
+		s.pageLoads++
+		skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+		end := min(skip+s.pageSize, len(s.items))
+		page := map[string]any{"value": s.items[skip:end]}
+		if end < len(s.items) {
+			// 完整地址的 nextLink
+			page["nextLink"] = fmt.Sprintf("http://%s%s?api-version=2023-10-01&skip=%d", r.Host, path, end)
+		}
+		json.NewEncoder(w).Encode(page)
+	case r.Method == http.MethodPost && path == prefix+"/words:addOrUpdateBlocklistItems":
+		var req struct {
+			BlocklistItems []TextBlocklistItem `json:"blocklistItems"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		s.batches = append(s.batches, len(req.BlocklistItems))
+		for i := range req.BlocklistItems {
+			s.nextID++
+			req.BlocklistItems[i].BlocklistItemID = "id-" + strconv.Itoa(s.nextID)
+		}
+		s.items = append(s.items, req.BlocklistItems...)
+		json.NewEncoder(w).Encode(req)
+	case r.Method == http.MethodPost && path == prefix+"/words:removeBlocklistItems":
+		var req struct {
+			BlocklistItemIDs []string `json:"blocklistItemIds"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		s.batches = append(s.batches, len(req.BlocklistItemIDs))
+		s.removed = append(s.removed, req.BlocklistItemIDs...)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"error":{"code":"NotFound"}}`, http.StatusNotFound)
+	}
+}
+
+func TestBlocklistItemBatches(t *testing.T) {
+	server, stub := newStubBlocklists(t, 1000)
+	items := make([]TextBlocklistItem, 2*maxBlocklistItemsPerRequest+1)
+	for i := range items {
+		items[i].Text = fmt.Sprintf("word%d", i)
+	}
+	added, err := addBlocklistItems(server.URL, "key", "words", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != len(items) || !reflect.DeepEqual(stub.batches, []int{100, 100, 1}) {
+		t.Errorf("添加 %d 项，批次 %v", len(added), stub.batches)
+	}
+
+	stub.batches = nil
+	ids := make([]string, maxBlocklistItemsPerRequest)
+	for i := range ids {
+		ids[i] = added[i].BlocklistItemID
+	}
+	if err := removeBlocklistItems(server.URL, "key", "words", ids); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stub.batches, []int{100}) {
+		t.Errorf("恰好 100 项应一次提交，批次 %v", stub.batches)
+	}
+
+	// 第一批就失败时返回错误，没有已添加的项
+	added, err = addBlocklistItems(server.URL, "key", "missing", items[:1])
+	if err == nil || len(added) != 0 {
+		t.Errorf("添加到不存在的黑名单: %d 项, %v", len(added), err)
+	}
+}
+
+func TestListBlocklistPagination(t *testing.T) {
+	server, stub := newStubBlocklists(t, 2)
+	for i := range 5 {
+		stub.items = append(stub.items, TextBlocklistItem{BlocklistItemID: fmt.Sprintf("id-%d", i), Text: fmt.Sprintf("word%d", i)})
+	}
+	items, err := listBlocklistItems(server.URL, "key", "words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, stub.items) || stub.pageLoads != 3 {
+		t.Errorf("列出 %d 项，请求 %d 页", len(items), stub.pageLoads)
+	}
+
+	lists, err := listBlocklists(server.URL, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 2 || lists[0].BlocklistName != "words" || lists[1].BlocklistName != "names" {
+		t.Errorf("黑名单 = %+v", lists)
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	cases := []struct{ endpoint, nextLink, want string }{
+		{"https://x.example.com", "", ""},
+		{"https://x.example.com", "https://y.example.com/a?b=1", "https://y.example.com/a?b=1"},
+		{"https://x.example.com/", "/contentsafety/text/blocklists?skip=2", "https://x.example.com/contentsafety/text/blocklists?skip=2"},
+		{"https://x.example.com", "contentsafety/text/blocklists?skip=2", "https://x.example.com/contentsafety/text/blocklists?skip=2"},
+	}
+	for _, c := range cases {
+		if got := nextPageURL(c.endpoint, c.nextLink); got != c.want {
+			t.Errorf("nextPageURL(%q, %q) = %q", c.endpoint, c.nextLink, got)
+		}
+	}
+}
+
+func TestReadBlocklistFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.txt")
+	content := "# 注释\n\nbadword\n  spaced \t 描述 \nphrase with spaces\t说明\ta\tb\n\t只有描述\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	items, err := readBlocklistFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TextBlocklistItem{
+		{Text: "badword"},
+		{Text: "spaced", Description: "描述"},
+		{Text: "phrase with spaces", Description: "说明\ta\tb"},
+		{Text: "只有描述"},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("黑名单项 = %+v", items)
+	}
+
+	if _, err := readBlocklistFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("不存在的文件应返回错误")
+	}
+}
+
+func TestRemoveBlocklistItemsFromFile(t *testing.T) {
+	server, stub := newStubBlocklists(t, 2)
+	stub.items = []TextBlocklistItem{
+		{BlocklistItemID: "id-1", Text: "alpha"},
+		{BlocklistItemID: "id-2", Text: "beta"},
+		{BlocklistItemID: "id-3", Text: "gamma"},
+		{BlocklistItemID: "id-4", Text: "id-1"}, // 文本与其他项的 ID 相同时按 ID 删除
+	}
+	path := filepath.Join(t.TempDir(), "remove.txt")
+	if err := os.WriteFile(path, []byte("beta\nid-3\nid-1\nmissing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := removeBlocklistItemsFromFile(server.URL, "key", "words", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 || !reflect.DeepEqual(stub.removed, []string{"id-2", "id-3", "id-1"}) {
+		t.Errorf("删除 %d 项: %v", removed, stub.removed)
+	}
+
+	// 没有匹配的项时不发送删除请求
+	stub.batches = nil
+	if err := os.WriteFile(path, []byte("missing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := removeBlocklistItemsFromFile(server.URL, "key", "words", path); err != nil || removed != 0 || len(stub.batches) != 0 {
+		t.Errorf("删除 %d 项, %v, 批次 %v", removed, err, stub.batches)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 长文本分析的参数
+const (
+	maxTextChars        = 10000 // 单次文本分析的字符数上限
+	defaultChunkWorkers = 4     // 默认并发请求数
+)
+
+// TextChunk 表示长文本中的一个分块，Offset 和 Length 以字符（Unicode 码点）计
+type TextChunk struct {
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Text   string `json:"-"`
+}
+
+// CategoryFinding 表示一个类别在长文本中的汇总结果
+type CategoryFinding struct {
+	Category string      `json:"category"`
+	Severity Severity    `json:"severity"` // 所有分块中的最高严重程度
+	Chunks   []TextChunk `json:"chunks"`   // 严重程度大于 0 的分块
+}
+
+// LongTextResult 表示长文本的分析结果
+type LongTextResult struct {
+	ContentSafetyResponse                   // 按类别取最高严重程度后的结果，与单次分析的结构一致
+	Chunks                []TextChunk       `json:"chunks"`
+	Findings              []CategoryFinding `json:"findings"`
+}
+
+// splitText 按句子边界把文本切分为不超过 maxChars 个字符的分块；单个句子过长时按字符强制切分
+func splitText(text string, maxChars int) []TextChunk {
+	var chunks []TextChunk
+	var current strings.Builder
+	start, length := 0, 0
+	flush := func() {
+		if length > 0 {
+			chunks = append(chunks, TextChunk{Offset: start, Length: length, Text: current.String()})
+			start += length
+		}
+		current.Reset()
+		length = 0
+	}
+
+	for _, sentence := range splitSentences(text) {
+		n := utf8.RuneCountInString(sentence)
+		if length+n > maxChars {
+			flush()
+		}
+		for n > maxChars {
+			// 句子本身超过上限，按字符切分
+			runes := []rune(sentence)
+			current.WriteString(string(runes[:maxChars]))
+			length = maxChars
+			flush()
+			sentence = string(runes[maxChars:])
+			n -= maxChars
+		}
+		current.WriteString(sentence)
+		length += n
+	}
+	flush()
+	return chunks
+}
+
+// splitSentences 在句末标点（含其后的引号、括号和空白）及换行处切分文本，切分结果拼接后与原文一致
+func splitSentences(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+	last := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if !isSentenceEnd(runes, i) {
+			continue
+		}
+		end := i + 1
+		// 句末的引号、括号跟随前一句
+		for end < len(runes) && strings.ContainsRune(`"'”’」』)）]`, runes[end]) {
+			end++
+		}
+		for end < len(runes) && unicode.IsSpace(runes[end]) {
+			end++
+		}
+		if r == '.' && end < len(runes) && end == i+1 {
+			// 英文句点后没有空白，可能是小数或缩写
+			continue
+		}
+		sentences = append(sentences, string(runes[last:end]))
+		last = end
+		i = end - 1
+	}
+	if last < len(runes) {
+		sentences = append(sentences, string(runes[last:]))
+	}
+	return sentences
+}
+
+// isSentenceEnd 判断第 i 个字符是否为句末标点或换行
+func isSentenceEnd(runes []rune, i int) bool {
+	switch runes[i] {
+	case '。', '！', '？', '；', '!', '?', ';', '.', '\n':
+		return true
+	}
+	return false
+}
+
+// analyzeLongText 把长文本切分后并发分析，并按类别汇总结果
+// workers 为并发请求数，小于等于 0 时使用默认值
+func analyzeLongText(endpoint, apiKey, text string, opts AnalyzeTextOptions, workers int) (*LongTextResult, error) {
+	if workers <= 0 {
+		workers = defaultChunkWorkers
+	}
+	chunks := splitText(text, maxTextChars)
+	responses := make([]*ContentSafetyResponse, len(chunks))
+	errs := make([]error, len(chunks))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(chunks)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				responses[i], errs[i] = analyzeTextChunk(endpoint, apiKey, chunks[i].Text, opts)
+			}
+		}()
+	}
+	for i := range chunks {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("分析第 %d 个分块（偏移 %d）失败: %w", i+1, chunks[i].Offset, err)
+		}
+	}
+	return aggregateChunks(chunks, responses), nil
+}
+
+// aggregateChunks 汇总各分块的结果：每个类别取最高严重程度，黑名单匹配去重
+func aggregateChunks(chunks []TextChunk, responses []*ContentSafetyResponse) *LongTextResult {
+	result := &LongTextResult{Chunks: chunks}
+	findings := map[string]*CategoryFinding{}
+	var order []string
+	seen := map[string]bool{}
+	for i, resp := range responses {
+		for _, analysis := range resp.CategoriesAnalysis {
+			finding, ok := findings[analysis.Category]
+			if !ok {
+				finding = &CategoryFinding{Category: analysis.Category}
+				findings[analysis.Category] = finding
+				order = append(order, analysis.Category)
+			}
+			if analysis.Severity > finding.Severity {
+				finding.Severity = analysis.Severity
+			}
+			if analysis.Severity > 0 {
+				finding.Chunks = append(finding.Chunks, chunks[i])
+			}
+		}
+		for _, match := range resp.BlocklistsMatch {
+			key := match.BlocklistName + "\x00" + match.BlocklistItemId
+			if !seen[key] {
+				seen[key] = true
+				result.BlocklistsMatch = append(result.BlocklistsMatch, match)
+			}
+		}
+	}
+	sort.Strings(order)
+	for _, category := range order {
+		finding := findings[category]
+		result.CategoriesAnalysis = append(result.CategoriesAnalysis, CategoryAnalysis{Category: category, Severity: finding.Severity})
+		result.Findings = append(result.Findings, *finding)
+	}
+	return result
+}
+
+// printFindings 打印长文本中各类别的最高严重程度及触发的分块位置
+func printFindings(result *LongTextResult) {
+	fmt.Printf("共 %d 个分块\n", len(result.Chunks))
+	for _, finding := range result.Findings {
+		fmt.Printf("- %-8s %s 最高 %d\n", finding.Category, finding.Severity.bar(), finding.Severity)
+		for _, chunk := range finding.Chunks {
+			fmt.Printf("  分块: 偏移 %d, 长度 %d\n", chunk.Offset, chunk.Length)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"unicode/utf8"
+)
+
+// newStubContentSafety 启动模拟的文本分析服务：文本中出现 "hate" 时 Hate 为 4，出现 "kill" 时 Violence 为 6，
+// 出现 "banned" 时命中黑名单 words，出现 "invalid" 时返回 400；calls 记录收到的请求数
+func newStubContentSafety(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/contentsafety/text:analyze" || r.Header.Get("Ocp-Apim-Subscription-Key") == "" {
+			http.Error(w, `{"error":{"code":"NotFound"}}`, http.StatusNotFound)
+			return
+		}
+		var req ContentSafetyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		text := strings.ToLower(req.Text)
+		if strings.Contains(text, "invalid") {
+			http.Error(w, `{"error":{"code":"InvalidRequestBody"}}`, http.StatusBadRequest)
+			return
+		}
+		severity := func(word string, s Severity) Severity {
+			if strings.Contains(text, word) {
+				return s
+			}
+			return 0
+		}
+		resp := ContentSafetyResponse{CategoriesAnalysis: []CategoryAnalysis{
+			{Category: CategoryHate, Severity: severity("hate", 4)},
+			{Category: CategorySelfHarm},
+			{Category: CategorySexual},
+			{Category: CategoryViolence, Severity: severity("kill", 6)},
+		}}
+		if strings.Contains(text, "banned") {
+			resp.BlocklistsMatch = []BlocklistMatch{{BlocklistName: "words", BlocklistItemId: "1", BlocklistItemText: "banned"}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestSplitSentences(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"Hello. World!", []string{"Hello. ", "World!"}},
+		{"Pi is 3.14 ok.", []string{"Pi is 3.14 ok."}},
+		{"第一句。第二句！“引号。”后面", []string{"第一句。", "第二句！", "“引号。”", "后面"}},
+		{"line one\nline two", []string{"line one\n", "line two"}},
+		{"He said \"Stop.\" Then left", []string{"He said \"Stop.\" ", "Then left"}},
+	}
+	for _, c := range cases {
+		got := splitSentences(c.text)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitSentences(%q) = %q", c.text, got)
+		}
+		if strings.Join(got, "") != c.text {
+			t.Errorf("splitSentences(%q) 拼接后与原文不一致", c.text)
+		}
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		maxChars int
+		want     []TextChunk
+	}{
+		{"句子合并到同一分块", "Aa. Bb. Cc.", 8, []TextChunk{{0, 8, "Aa. Bb. "}, {8, 3, "Cc."}}},
+		{"超长句子强制切分", "abcdefghij. x", 4, []TextChunk{{0, 4, "abcd"}, {4, 4, "efgh"}, {8, 4, "ij. "}, {12, 1, "x"}}},
+		{"偏移按字符计", "你好。世界。再见。", 6, []TextChunk{{0, 6, "你好。世界。"}, {6, 3, "再见。"}}},
+		{"空文本", "", 10, nil},
+	}
+	for _, c := range cases {
+		got := splitText(c.text, c.maxChars)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: 分块 = %+v", c.name, got)
+		}
+		var joined strings.Builder
+		for _, chunk := range got {
+			if n := utf8.RuneCountInString(chunk.Text); n > c.maxChars || n != chunk.Length {
+				t.Errorf("%s: 分块长度 %d 与 Length %d 不符或超过上限", c.name, n, chunk.Length)
+			}
+			joined.WriteString(chunk.Text)
+		}
+		if joined.String() != c.text {
+			t.Errorf("%s: 分块拼接后与原文不一致", c.name)
+		}
+	}
+}
+
+func TestAnalyzeLongTextAggregates(t *testing.T) {
+	server, calls := newStubContentSafety(t)
+	// 三个分块：第一块和第三块有问题，黑名单在两个分块中都命中
+	sentence := strings.Repeat("x", maxTextChars-20) + ". "
+	text := "I hate banned things. " + sentence + "fine. " + sentence + "kill banned."
+	result, err := analyzeLongText(server.URL, "key", text, AnalyzeTextOptions{}, 2)
+	if err != nil {
+		t.Fatalf("分析失败: %v", err)
+	}
+	if len(result.Chunks) != 3 || int(calls.Load()) != len(result.Chunks) {
+		t.Fatalf("分块 %d 个，请求 %d 次", len(result.Chunks), calls.Load())
+	}
+
+	severities := map[string]Severity{}
+	for _, analysis := range result.CategoriesAnalysis {
+		severities[analysis.Category] = analysis.Severity
+	}
+	if want := map[string]Severity{CategoryHate: 4, CategorySelfHarm: 0, CategorySexual: 0, CategoryViolence: 6}; !reflect.DeepEqual(severities, want) {
+		t.Errorf("汇总的严重程度 = %v", severities)
+	}
+	for _, finding := range result.Findings {
+		switch finding.Category {
+		case CategoryHate:
+			if len(finding.Chunks) != 1 || finding.Chunks[0].Offset != 0 {
+				t.Errorf("Hate 的分块 = %+v", finding.Chunks)
+			}
+		case CategoryViolence:
+			if len(finding.Chunks) != 1 || finding.Chunks[0].Offset != result.Chunks[2].Offset {
+				t.Errorf("Violence 的分块 = %+v", finding.Chunks)
+			}
+		default:
+			if len(finding.Chunks) != 0 {
+				t.Errorf("%s 不应记录分块", finding.Category)
+			}
+		}
+	}
+	if len(result.BlocklistsMatch) != 1 {
+		t.Errorf("黑名单匹配没有去重: %+v", result.BlocklistsMatch)
+	}
+
+	// 超过上限的文本通过 analyzeText 自动分块
+	resp, err := analyzeText(server.URL, "key", text, AnalyzeTextOptions{})
+	if err != nil || len(resp.CategoriesAnalysis) != len(allCategories) {
+		t.Errorf("analyzeText 结果 = %+v, %v", resp, err)
+	}
+}
+
+func TestAnalyzeLongTextReportsChunkError(t *testing.T) {
+	if _, err := analyzeLongText("http://127.0.0.1:0", "key", "a. b.", AnalyzeTextOptions{}, 0); err == nil || !strings.Contains(err.Error(), "第 1 个分块") {
+		t.Errorf("错误 = %v", err)
+	}
+}
@@ -3,31 +3,86 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
+	"unicode/utf8"
 )
 
 // ContentSafetyRequest 表示发送到Azure内容安全API的请求
 type ContentSafetyRequest struct {
-	Text string `json:"text"`
+	Text               string   `json:"text"`
+	Categories         []string `json:"categories,omitempty"`
+	BlocklistNames     []string `json:"blocklistNames,omitempty"`
+	HaltOnBlocklistHit bool     `json:"haltOnBlocklistHit,omitempty"`
+	OutputType         string   `json:"outputType,omitempty"`
+}
+
+// AnalyzeTextOptions 表示文本分析的可选参数
+type AnalyzeTextOptions struct {
+	Categories         []string // 要分析的类别，为空时分析所有类别
+	BlocklistNames     []string // 同时匹配的黑名单名称
+	HaltOnBlocklistHit bool     // 命中黑名单时不再进行类别分析
+	OutputType         string   // 严重程度的输出级别，为空时使用四级输出
 }
 
 // ContentSafetyResponse 表示从Azure内容安全API返回的响应
 type ContentSafetyResponse struct {
-	CategoriesAnalysis []struct {
-		Category string  `json:"category"`
-		Severity float64 `json:"severity"`
-	} `json:"categoriesAnalysis"`
-	BlocklistsMatch []struct {
-		BlocklistName     string `json:"blocklistName"`
-		BlocklistItemId   string `json:"blocklistItemId"`
-		BlocklistItemText string `json:"blocklistItemText"`
-	} `json:"blocklistsMatch"`
+	CategoriesAnalysis []CategoryAnalysis `json:"categoriesAnalysis"`
+	BlocklistsMatch    []BlocklistMatch   `json:"blocklistsMatch"`
+}
+
+// CategoryAnalysis 表示单个类别的分析结果
+type CategoryAnalysis struct {
+	Category string   `json:"category"`
+	Severity Severity `json:"severity"`
+}
+
+// BlocklistMatch 表示一条黑名单匹配结果
+type BlocklistMatch struct {
+	BlocklistName     string `json:"blocklistName"`
+	BlocklistItemId   string `json:"blocklistItemId"`
+	BlocklistItemText string `json:"blocklistItemText"`
 }
 
 func main() {
+	// 命令行参数；默认行为与原来一致，分析示例文本
+	action := flag.String("action", "text", "操作类型：text/image/batch/decide/serve/review-list/review-approve/review-reject/shield/groundedness/protected-text/protected-code/blocklist-create/blocklist-delete/blocklist-list/blocklist-items/blocklist-add/blocklist-remove")
+	text := flag.String("text", "这是一个测试文本，用于检测内容安全。", "要检查的文本（action=text）")
+	imagePath := flag.String("image", "", "要分析的图片，本地文件路径或 Blob URL（action=image）")
+	dir := flag.String("dir", "", "要扫描的图片目录，包括子目录（action=image）")
+	blocklists := flag.String("blocklists", "", "分析文本时匹配的黑名单，多个名称用逗号分隔")
+	haltOnHit := flag.Bool("halt-on-hit", false, "命中黑名单时不再进行类别分析")
+	categories := flag.String("categories", "", "要分析的类别，多个类别用逗号分隔：Hate/SelfHarm/Sexual/Violence，为空时分析所有类别（action=text/batch）")
+	outputType := flag.String("output-type", "", "严重程度的输出级别：FourSeverityLevels/EightSeverityLevels，默认四级（action=text/batch）")
+	blocklist := flag.String("blocklist", "", "要管理的黑名单名称（action=blocklist-*）")
+	description := flag.String("description", "", "黑名单描述（action=blocklist-create）")
+	policyPath := flag.String("policy", "", "审核策略文件（YAML，action=decide/serve）")
+	channel := flag.String("channel", "", "审核渠道，为空时使用策略的默认规则（action=decide）")
+	addr := flag.String("addr", ":8080", "审核服务的监听地址（action=serve）")
+	paths := flag.String("paths", "text", "webhook 要审核的 JSON 字段路径，多个路径用逗号分隔，* 匹配数组元素或对象字段（action=serve）")
+	documents := flag.String("documents", "", "随提示一起检测的文档文件，多个路径用逗号分隔（action=shield）")
+	auditPath := flag.String("audit", "", "审核日志（SQLite）路径，指定后记录每次审核决定（action=decide/review-*）")
+	reviewID := flag.Int64("id", 0, "复核记录ID（action=review-approve/review-reject）")
+	reviewStatus := flag.String("status", ReviewPending, "列出的复核状态：pending/approved/rejected，为空时列出全部（action=review-list）")
+	reviewer := flag.String("reviewer", os.Getenv("USER"), "复核人（action=review-approve/review-reject）")
+	notes := flag.String("notes", "", "复核备注（action=review-approve/review-reject）")
+	query := flag.String("query", "", "用户问题，指定后按 QnA 任务检测有根据性（action=groundedness）")
+	sources := flag.String("sources", "", "回答所依据的资料文件，多个路径用逗号分隔（action=groundedness）")
+	domain := flag.String("domain", GroundednessDomainGeneric, "有根据性检测的领域：Generic/Medical")
+	reasoning := flag.Bool("reasoning", false, "返回没有依据的原因（action=groundedness，使用 AZURE_OPENAI_ENDPOINT 和 AZURE_OPENAI_DEPLOYMENT 指定的部署）")
+	in := flag.String("in", "", "从文件读取要检查的文本，长文本会分块并发分析（action=text）；action=batch 时为 JSONL、CSV 文件或目录")
+	out := flag.String("out", "moderation-results", "批量审核结果的输出目录，已有结果时从中断处继续（action=batch）")
+	retries := flag.Int("retries", defaultBatchRetries, "批量审核时每条记录的最大重试次数（action=batch）")
+	idField := flag.String("id-field", "id", "JSONL/CSV 中记录ID的字段名（action=batch）")
+	textField := flag.String("text-field", "text", "JSONL/CSV 中文本的字段名（action=batch）")
+	workers := flag.Int("workers", defaultChunkWorkers, "分析长文本或批量审核时的并发请求数")
+	itemsFile := flag.String("file", "", "黑名单项文件，每行一项，可用制表符分隔文本和描述；删除时每行为项目ID或文本；action=protected-code 时为代码文件")
+	flag.Parse()
+
 	// 设置Azure Content Safety API的端点和密钥;设置的环境变量在这里读取
 	endpoint := os.Getenv("AZURE_CONTENT_SAFETY_ENDPOINT")
 	apiKey := os.Getenv("AZURE_CONTENT_SAFETY_KEY")
@@ -40,95 +95,383 @@ func main() {
 		return
 	}
 
-	// 要检查的文本内容
-	textToAnalyze := "这是一个测试文本，用于检测内容安全。"
+	switch *action {
+	case "text":
+		// 要检查的文本内容
+		textToAnalyze := *text
 
-	// 调用内容安全API
-	result, err := analyzeText(endpoint, apiKey, textToAnalyze)
-	if err != nil {
-		fmt.Printf("分析文本时出错: %v\n", err)
-		return
-	}
+		// 调用内容安全API
+		opts, err := textAnalyzeOptions(*categories, *outputType, *blocklists, *haltOnHit)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		// 分析文件中的文本，输出每个类别的最高严重程度和触发的分块
+		if *in != "" {
+			data, err := os.ReadFile(*in)
+			if err != nil {
+				fmt.Printf("读取文件失败: %v\n", err)
+				return
+			}
+			result, err := analyzeLongText(endpoint, apiKey, string(data), opts, *workers)
+			if err != nil {
+				fmt.Printf("分析文本时出错: %v\n", err)
+				return
+			}
+			fmt.Println("文件:", *in)
+			printFindings(result)
+			return
+		}
+		result, err := analyzeText(endpoint, apiKey, textToAnalyze, opts)
+		if err != nil {
+			fmt.Printf("分析文本时出错: %v\n", err)
+			return
+		}
+
+		// 打印结果
+		fmt.Println("内容安全分析结果:")
+		fmt.Println("文本:", textToAnalyze)
 
-	// 打印结果
-	fmt.Println("内容安全分析结果:")
-	fmt.Println("文本:", textToAnalyze)
+		fmt.Println()
+		printCategories(result)
 
-	if len(result.CategoriesAnalysis) > 0 {
-		fmt.Println("\n类别分析:")
-		for _, category := range result.CategoriesAnalysis {
-			fmt.Printf("- 类别: %s, 严重程度: %.2f\n", category.Category, category.Severity)
+		if len(result.BlocklistsMatch) > 0 {
+			fmt.Println("\n黑名单匹配:")
+			for _, match := range result.BlocklistsMatch {
+				fmt.Printf("- 黑名单名称: %s, 项目ID: %s, 文本: %s\n",
+					match.BlocklistName, match.BlocklistItemId, match.BlocklistItemText)
+			}
+		} else {
+			fmt.Println("\n没有匹配到黑名单内容")
 		}
-	} else {
-		fmt.Println("\n没有检测到任何类别问题")
-	}
 
-	if len(result.BlocklistsMatch) > 0 {
-		fmt.Println("\n黑名单匹配:")
-		for _, match := range result.BlocklistsMatch {
-			fmt.Printf("- 黑名单名称: %s, 项目ID: %s, 文本: %s\n",
-				match.BlocklistName, match.BlocklistItemId, match.BlocklistItemText)
+		// 测试一些可能违规的内容
+		fmt.Println("\n\n测试可能违规的内容:")
+		testViolatingContent(endpoint, apiKey)
+	case "image":
+		switch {
+		case *dir != "":
+			if _, err := scanImageDirectory(endpoint, apiKey, *dir); err != nil {
+				fmt.Printf("扫描图片目录时出错: %v\n", err)
+				return
+			}
+		case *imagePath != "":
+			result, err := analyzeImage(endpoint, apiKey, *imagePath)
+			if err != nil {
+				fmt.Printf("分析图片时出错: %v\n", err)
+				return
+			}
+			fmt.Println("图片:", *imagePath)
+			printCategories(result)
+		default:
+			fmt.Println("请使用 -image 参数指定图片，或使用 -dir 参数指定图片目录")
 		}
-	} else {
-		fmt.Println("\n没有匹配到黑名单内容")
+	case "batch":
+		if *in == "" {
+			fmt.Println("请使用 -in 参数指定 JSONL、CSV 文件或目录")
+			return
+		}
+		opts := BatchOptions{
+			Input:     *in,
+			OutputDir: *out,
+			IDField:   *idField,
+			TextField: *textField,
+			Workers:   *workers,
+			Retries:   *retries,
+		}
+		if *retries == 0 {
+			opts.Retries = -1
+		}
+		analyze, err := textAnalyzeOptions(*categories, *outputType, *blocklists, *haltOnHit)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		opts.Analyze = analyze
+		summary, err := runBatch(endpoint, apiKey, opts)
+		if err != nil {
+			fmt.Printf("批量审核时出错: %v\n", err)
+			return
+		}
+		printBatchSummary(summary, *out)
+	case "decide", "serve":
+		if *policyPath == "" {
+			fmt.Println("请使用 -policy 参数指定审核策略文件")
+			return
+		}
+		policy, err := loadPolicy(*policyPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		moderator := newModerator(endpoint, apiKey, policy)
+		if *auditPath != "" {
+			audit, err := openAuditLog(*auditPath)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			defer audit.Close()
+			moderator.audit = audit
+		}
+		if *action == "serve" {
+			fields := strings.Split(*paths, ",")
+			fmt.Printf("审核服务监听 %s，webhook 字段: %s\n", *addr, strings.Join(fields, ", "))
+			if err := http.ListenAndServe(*addr, moderationService(moderator, fields, logBlockedDecision)); err != nil {
+				fmt.Printf("审核服务退出: %v\n", err)
+			}
+			return
+		}
+		decision, err := moderator.DecideChannel(*channel, *text)
+		if err != nil {
+			fmt.Printf("审核文本时出错: %v\n", err)
+			return
+		}
+		fmt.Println("文本:", *text)
+		printDecision(decision)
+	case "review-list", "review-approve", "review-reject":
+		if *auditPath == "" {
+			fmt.Println("请使用 -audit 参数指定审核日志")
+			return
+		}
+		audit, err := openAuditLog(*auditPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer audit.Close()
+		switch *action {
+		case "review-list":
+			entries, err := audit.listReviews(*reviewStatus, 100)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			printAuditEntries(entries)
+		case "review-approve", "review-reject":
+			status := ReviewApproved
+			if *action == "review-reject" {
+				status = ReviewRejected
+			}
+			if err := audit.review(*reviewID, status, *reviewer, *notes); err != nil {
+				fmt.Printf("复核失败: %v\n", err)
+				return
+			}
+			fmt.Printf("记录 #%d 已标记为 %s\n", *reviewID, status)
+		}
+	case "shield":
+		var docs []string
+		if *documents != "" {
+			for _, path := range strings.Split(*documents, ",") {
+				data, err := os.ReadFile(strings.TrimSpace(path))
+				if err != nil {
+					fmt.Printf("读取文档失败: %v\n", err)
+					return
+				}
+				docs = append(docs, string(data))
+			}
+		}
+		result, err := shieldPrompt(endpoint, apiKey, *text, docs)
+		if err != nil {
+			fmt.Printf("检测提示攻击时出错: %v\n", err)
+			return
+		}
+		printShieldResult(result)
+	case "groundedness":
+		request := GroundednessRequest{
+			Domain:    *domain,
+			Task:      GroundednessTaskSummarization,
+			Text:      *text,
+			Reasoning: *reasoning,
+		}
+		if *reasoning {
+			request.LLMResource = &LLMResource{
+				ResourceType:              "AzureOpenAI",
+				AzureOpenAIEndpoint:       os.Getenv("AZURE_OPENAI_ENDPOINT"),
+				AzureOpenAIDeploymentName: os.Getenv("AZURE_OPENAI_DEPLOYMENT"),
+			}
+		}
+		if *query != "" {
+			request.Task = GroundednessTaskQnA
+			request.QnA = &GroundednessQnA{Query: *query}
+		}
+		if *sources != "" {
+			for _, path := range strings.Split(*sources, ",") {
+				data, err := os.ReadFile(strings.TrimSpace(path))
+				if err != nil {
+					fmt.Printf("读取依据资料失败: %v\n", err)
+					return
+				}
+				request.GroundingSources = append(request.GroundingSources, string(data))
+			}
+		}
+		result, err := detectGroundedness(endpoint, apiKey, request)
+		if err != nil {
+			fmt.Printf("检测有根据性时出错: %v\n", err)
+			return
+		}
+		printGroundedness(result)
+	case "protected-text":
+		result, err := detectProtectedMaterial(endpoint, apiKey, *text)
+		if err != nil {
+			fmt.Printf("检测受保护文本时出错: %v\n", err)
+			return
+		}
+		fmt.Printf("检测到受保护的文本: %v\n", result.ProtectedMaterialAnalysis.Detected)
+	case "protected-code":
+		if *itemsFile == "" {
+			fmt.Println("请使用 -file 参数指定要检测的代码文件")
+			return
+		}
+		code, err := os.ReadFile(*itemsFile)
+		if err != nil {
+			fmt.Printf("读取代码文件失败: %v\n", err)
+			return
+		}
+		result, err := detectProtectedCode(endpoint, apiKey, string(code))
+		if err != nil {
+			fmt.Printf("检测受保护代码时出错: %v\n", err)
+			return
+		}
+		printProtectedCode(result)
+	case "blocklist-list":
+		lists, err := listBlocklists(endpoint, apiKey)
+		if err != nil {
+			fmt.Printf("查询黑名单时出错: %v\n", err)
+			return
+		}
+		fmt.Printf("共 %d 个黑名单:\n", len(lists))
+		for _, list := range lists {
+			fmt.Printf("- %s: %s\n", list.BlocklistName, list.Description)
+		}
+	case "blocklist-create", "blocklist-delete", "blocklist-items", "blocklist-add", "blocklist-remove":
+		if *blocklist == "" {
+			fmt.Println("请使用 -blocklist 参数指定黑名单名称")
+			return
+		}
+		manageBlocklist(endpoint, apiKey, *action, *blocklist, *description, *itemsFile)
+	default:
+		fmt.Println("无效的操作类型。请使用 -action 参数指定操作类型：text/image/batch/decide/serve/review-list/review-approve/review-reject/shield/groundedness/protected-text/protected-code/blocklist-create/blocklist-delete/blocklist-list/blocklist-items/blocklist-add/blocklist-remove")
+		flag.PrintDefaults()
 	}
+}
 
-	// 测试一些可能违规的内容
-	fmt.Println("\n\n测试可能违规的内容:")
-	testViolatingContent(endpoint, apiKey)
+// textAnalyzeOptions 根据命令行参数生成文本分析参数
+func textAnalyzeOptions(categories, outputType, blocklists string, haltOnHit bool) (AnalyzeTextOptions, error) {
+	opts := AnalyzeTextOptions{HaltOnBlocklistHit: haltOnHit}
+	var err error
+	if opts.Categories, err = parseCategories(categories); err != nil {
+		return opts, err
+	}
+	if opts.OutputType, err = parseOutputType(outputType); err != nil {
+		return opts, err
+	}
+	if blocklists != "" {
+		for _, name := range strings.Split(blocklists, ",") {
+			opts.BlocklistNames = append(opts.BlocklistNames, strings.TrimSpace(name))
+		}
+	}
+	return opts, nil
 }
 
 // analyzeText 使用Azure Content Safety API分析文本内容
-func analyzeText(endpoint, apiKey, text string) (*ContentSafetyResponse, error) {
+// 超过单次请求字符上限的文本会按句子切分后并发分析，每个类别取最高严重程度
+func analyzeText(endpoint, apiKey, text string, opts AnalyzeTextOptions) (*ContentSafetyResponse, error) {
+	if utf8.RuneCountInString(text) > maxTextChars {
+		result, err := analyzeLongText(endpoint, apiKey, text, opts, defaultChunkWorkers)
+		if err != nil {
+			return nil, err
+		}
+		return &result.ContentSafetyResponse, nil
+	}
+	return analyzeTextChunk(endpoint, apiKey, text, opts)
+}
+
+// analyzeTextChunk 发送一次文本分析请求，文本不能超过字符上限
+func analyzeTextChunk(endpoint, apiKey, text string, opts AnalyzeTextOptions) (*ContentSafetyResponse, error) {
 	// 构建API URL；这里依赖设置的路径：https://your-resource-name.cognitiveservices.azure.com
 	apiURL := endpoint + "/contentsafety/text:analyze?api-version=2023-10-01"
 
 	// 创建请求体
 	requestBody := ContentSafetyRequest{
-		Text: text,
+		Text:               text,
+		Categories:         opts.Categories,
+		BlocklistNames:     opts.BlocklistNames,
+		HaltOnBlocklistHit: opts.HaltOnBlocklistHit,
+		OutputType:         opts.OutputType,
 	}
 
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	var result ContentSafetyResponse
+	if err := callContentSafety(http.MethodPost, apiURL, apiKey, requestBody, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// APIError 表示内容安全API返回的非成功状态码
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API返回错误: %s, 状态码: %d", e.Body, e.StatusCode)
+}
+
+// callContentSafety 以JSON格式调用内容安全API，并把响应解析到 result 中
+// payload 为空时不发送请求体，result 为空时忽略响应内容
+func callContentSafety(method, apiURL, apiKey string, payload, result any) error {
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("序列化请求失败: %v", err)
+		}
+		body = bytes.NewBuffer(jsonData)
 	}
 
 	// 创建HTTP请求
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(method, apiURL, body)
 	if err != nil {
-		return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
+		return fmt.Errorf("创建HTTP请求失败: %v", err)
 	}
 
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
+	// 设置请求头；PATCH 请求使用 JSON merge patch 格式
+	if method == http.MethodPatch {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	req.Header.Set("Ocp-Apim-Subscription-Key", apiKey)
 
 	// 发送请求
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("发送HTTP请求失败: %v", err)
+		return fmt.Errorf("发送HTTP请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %v", err)
+		return fmt.Errorf("读取响应失败: %w", err)
 	}
 
-	// 检查HTTP状态码
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API返回错误: %s, 状态码: %d", string(body), resp.StatusCode)
+	// 检查HTTP状态码；创建资源返回 201，删除返回 204
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	// 解析响应
-	var result ContentSafetyResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %v, 响应内容: %s", err, string(body))
+	if result == nil || len(respBody) == 0 {
+		return nil
 	}
-
-	return &result, nil
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("解析响应失败: %v, 响应内容: %s", err, string(respBody))
+	}
+	return nil
 }
 
 // testViolatingContent 测试一些可能违规的内容；这里是一个测试函数，按需求修改为正常需要审核的内容即可
@@ -142,19 +485,12 @@ func testViolatingContent(endpoint, apiKey string) {
 
 	for _, text := range testTexts {
 		fmt.Printf("\n测试文本: %s\n", text)
-		result, err := analyzeText(endpoint, apiKey, text)
+		result, err := analyzeText(endpoint, apiKey, text, AnalyzeTextOptions{})
 		if err != nil {
 			fmt.Printf("分析文本时出错: %v\n", err)
 			continue
 		}
 
-		if len(result.CategoriesAnalysis) > 0 {
-			fmt.Println("类别分析:")
-			for _, category := range result.CategoriesAnalysis {
-				fmt.Printf("- 类别: %s, 严重程度: %.2f\n", category.Category, category.Severity)
-			}
-		} else {
-			fmt.Println("没有检测到任何类别问题")
-		}
+		printCategories(result)
 	}
 }
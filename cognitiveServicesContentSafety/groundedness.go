@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// 有根据性检测的任务类型和领域
+const (
+	GroundednessTaskQnA           = "QnA"
+	GroundednessTaskSummarization = "Summarization"
+
+	GroundednessDomainGeneric = "Generic"
+	GroundednessDomainMedical = "Medical"
+)
+
+// GroundednessRequest 表示有根据性检测的请求
+type GroundednessRequest struct {
+	Domain           string           `json:"domain,omitempty"`      // Generic（默认）或 Medical
+	Task             string           `json:"task,omitempty"`        // QnA 或 Summarization（默认）
+	QnA              *GroundednessQnA `json:"qna,omitempty"`         // Task 为 QnA 时的用户问题
+	Text             string           `json:"text"`                  // 要检测的模型回答，最多 7500 个字符
+	GroundingSources []string         `json:"groundingSources"`      // 回答所依据的资料
+	Reasoning        bool             `json:"reasoning,omitempty"`   // 返回没有依据的原因，需要同时提供 LLMResource
+	LLMResource      *LLMResource     `json:"llmResource,omitempty"` // 用于生成原因的 Azure OpenAI 部署
+}
+
+// LLMResource 表示生成原因所用的 Azure OpenAI 部署，内容安全资源的托管标识需要有访问权限
+type LLMResource struct {
+	ResourceType              string `json:"resourceType"` // 固定为 AzureOpenAI
+	AzureOpenAIEndpoint       string `json:"azureOpenAIEndpoint"`
+	AzureOpenAIDeploymentName string `json:"azureOpenAIDeploymentName"`
+}
+
+// GroundednessQnA 表示问答任务中的问题
+type GroundednessQnA struct {
+	Query string `json:"query"`
+}
+
+// TextSpan 表示一段文本在不同编码下的位置
+type TextSpan struct {
+	UTF8      int `json:"utf8"`
+	UTF16     int `json:"utf16"`
+	CodePoint int `json:"codePoint"`
+}
+
+// UngroundedDetail 表示一段没有依据的内容
+type UngroundedDetail struct {
+	Text   string   `json:"text"`
+	Offset TextSpan `json:"offset"`
+	Length TextSpan `json:"length"`
+	Reason string   `json:"reason,omitempty"` // 开启 Reasoning 时返回的原因
+}
+
+// GroundednessResponse 表示有根据性检测的结果
+type GroundednessResponse struct {
+	UngroundedDetected   bool               `json:"ungroundedDetected"`
+	UngroundedPercentage float64            `json:"ungroundedPercentage"` // 没有依据的内容所占比例，0 到 1
+	UngroundedDetails    []UngroundedDetail `json:"ungroundedDetails"`
+}
+
+// detectGroundedness 检测模型回答是否以提供的资料为依据
+func detectGroundedness(endpoint, apiKey string, request GroundednessRequest) (*GroundednessResponse, error) {
+	if request.Text == "" || len(request.GroundingSources) == 0 {
+		return nil, fmt.Errorf("回答和依据资料不能为空")
+	}
+	if request.Reasoning && (request.LLMResource == nil || request.LLMResource.AzureOpenAIEndpoint == "" || request.LLMResource.AzureOpenAIDeploymentName == "") {
+		return nil, fmt.Errorf("返回原因时必须提供 Azure OpenAI 部署（AZURE_OPENAI_ENDPOINT 和 AZURE_OPENAI_DEPLOYMENT）")
+	}
+	if request.Task == GroundednessTaskQnA && (request.QnA == nil || request.QnA.Query == "") {
+		return nil, fmt.Errorf("QnA 任务必须提供问题")
+	}
+
+	apiURL := endpoint + "/contentsafety/text:detectGroundedness?api-version=2024-09-15-preview"
+	var result GroundednessResponse
+	if err := callContentSafety(http.MethodPost, apiURL, apiKey, request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// printGroundedness 打印有根据性检测结果
+func printGroundedness(result *GroundednessResponse) {
+	if !result.UngroundedDetected {
+		fmt.Println("回答均有依据")
+		return
+	}
+	fmt.Printf("检测到没有依据的内容，占比 %.0f%%:\n", result.UngroundedPercentage*100)
+	for _, detail := range result.UngroundedDetails {
+		fmt.Printf("- 位置 %d: %s\n", detail.Offset.CodePoint, detail.Text)
+		if detail.Reason != "" {
+			fmt.Printf("  原因: %s\n", detail.Reason)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newStubDetection 启动模拟的有根据性和受保护内容检测服务：
+// 回答中不在依据资料里的句子视为没有依据；文本或代码中出现 "lyrics" 或 "quicksort" 时视为受保护内容
+func newStubDetection(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/contentsafety/text:detectGroundedness":
+			var req GroundednessRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			var resp GroundednessResponse
+			sources := strings.Join(req.GroundingSources, "\n")
+			offset := 0
+			for _, sentence := range strings.SplitAfter(req.Text, ". ") {
+				if !strings.Contains(sources, strings.TrimSpace(sentence)) {
+					detail := UngroundedDetail{Text: sentence, Offset: TextSpan{CodePoint: offset}}
+					if req.Reasoning {
+						detail.Reason = "资料中没有提到"
+					}
+					resp.UngroundedDetails = append(resp.UngroundedDetails, detail)
+				}
+				offset += len([]rune(sentence))
+			}
+			resp.UngroundedDetected = len(resp.UngroundedDetails) > 0
+			resp.UngroundedPercentage = float64(len(resp.UngroundedDetails)) / float64(len(strings.SplitAfter(req.Text, ". ")))
+			json.NewEncoder(w).Encode(resp)
+		case "/contentsafety/text:detectProtectedMaterial":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			if len([]rune(req["text"])) < 110 {
+				http.Error(w, `{"error":{"code":"InvalidRequestBody","message":"text is too short"}}`, http.StatusBadRequest)
+				return
+			}
+			var resp ProtectedMaterialResponse
+			resp.ProtectedMaterialAnalysis.Detected = strings.Contains(req["text"], "lyrics")
+			json.NewEncoder(w).Encode(resp)
+		case "/contentsafety/text:detectProtectedMaterialForCode":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			if !strings.Contains(req["code"], "quicksort") {
+				w.Write([]byte(`{"protectedMaterialAnalysis":{"detected":false,"codeCitations":[]}}`))
+				return
+			}
+			w.Write([]byte(`{"protectedMaterialAnalysis":{"detected":true,"codeCitations":[
+				{"license":"MIT","sourceUrls":["https://github.com/a/sort/blob/main/q.go","https://github.com/b/algo/blob/main/sort.go"]}]}}`))
+		default:
+			http.Error(w, `{"error":{"code":"NotFound"}}`, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestDetectGroundednessValidation(t *testing.T) {
+	server, calls := newStubDetection(t)
+	valid := func() GroundednessRequest {
+		return GroundednessRequest{Text: "answer", GroundingSources: []string{"source"}}
+	}
+	llm := &LLMResource{ResourceType: "AzureOpenAI", AzureOpenAIEndpoint: "https://aoai.example.com", AzureOpenAIDeploymentName: "gpt-4o"}
+	cases := []struct {
+		name   string
+		modify func(r *GroundednessRequest)
+		want   string
+	}{
+		{"回答为空", func(r *GroundednessRequest) { r.Text = "" }, "不能为空"},
+		{"没有依据资料", func(r *GroundednessRequest) { r.GroundingSources = nil }, "不能为空"},
+		{"QnA 没有问题", func(r *GroundednessRequest) { r.Task = GroundednessTaskQnA }, "必须提供问题"},
+		{"QnA 问题为空", func(r *GroundednessRequest) { r.Task, r.QnA = GroundednessTaskQnA, &GroundednessQnA{} }, "必须提供问题"},
+		{"返回原因但没有部署", func(r *GroundednessRequest) { r.Reasoning = true }, "Azure OpenAI"},
+		{"返回原因但没有配置端点", func(r *GroundednessRequest) {
+			r.Reasoning, r.LLMResource = true, &LLMResource{ResourceType: "AzureOpenAI", AzureOpenAIDeploymentName: "gpt-4o"}
+		}, "AZURE_OPENAI_ENDPOINT"},
+		{"返回原因但没有配置部署名称", func(r *GroundednessRequest) {
+			r.Reasoning, r.LLMResource = true, &LLMResource{ResourceType: "AzureOpenAI", AzureOpenAIEndpoint: "https://aoai.example.com"}
+		}, "AZURE_OPENAI_DEPLOYMENT"},
+	}
+	for _, c := range cases {
+		request := valid()
+		c.modify(&request)
+		if _, err := detectGroundedness(server.URL, "key", request); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: 错误 = %v，期望包含 %q", c.name, err, c.want)
+		}
+	}
+	if calls.Load() != 0 {
+		t.Errorf("无效的请求不应发送，实际发送 %d 次", calls.Load())
+	}
+
+	// 完整的 QnA 和返回原因的请求可以发送
+	request := valid()
+	request.Task, request.QnA = GroundednessTaskQnA, &GroundednessQnA{Query: "question?"}
+	request.Reasoning, request.LLMResource = true, llm
+	if _, err := detectGroundedness(server.URL, "key", request); err != nil {
+		t.Errorf("有效的请求失败: %v", err)
+	}
+}
+
+func TestDetectGroundednessDetails(t *testing.T) {
+	server, _ := newStubDetection(t)
+	sources := []string{"The store opens at 9am. It closes at 6pm."}
+
+	result, err := detectGroundedness(server.URL, "key", GroundednessRequest{Text: "The store opens at 9am. It closes at 6pm.", GroundingSources: sources})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UngroundedDetected || len(result.UngroundedDetails) != 0 {
+		t.Errorf("有依据的回答 = %+v", result)
+	}
+
+	result, err = detectGroundedness(server.URL, "key", GroundednessRequest{
+		Text:             "The store opens at 9am. Parking is free.",
+		GroundingSources: sources,
+		Reasoning:        true,
+		LLMResource:      &LLMResource{ResourceType: "AzureOpenAI", AzureOpenAIEndpoint: "https://aoai.example.com", AzureOpenAIDeploymentName: "gpt-4o"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []UngroundedDetail{{Text: "Parking is free.", Offset: TextSpan{CodePoint: 24}, Reason: "资料中没有提到"}}
+	if !result.UngroundedDetected || result.UngroundedPercentage != 0.5 || !reflect.DeepEqual(result.UngroundedDetails, want) {
+		t.Errorf("没有依据的内容 = %+v", result)
+	}
+}
+
+func TestDetectProtectedMaterial(t *testing.T) {
+	server, _ := newStubDetection(t)
+	padding := strings.Repeat(" la", 40)
+
+	result, err := detectProtectedMaterial(server.URL, "key", "song lyrics"+padding)
+	if err != nil || !result.ProtectedMaterialAnalysis.Detected {
+		t.Errorf("受保护的文本 = %+v, %v", result, err)
+	}
+	result, err = detectProtectedMaterial(server.URL, "key", "my own words"+padding)
+	if err != nil || result.ProtectedMaterialAnalysis.Detected {
+		t.Errorf("原创文本 = %+v, %v", result, err)
+	}
+	var apiErr *APIError
+	if _, err := detectProtectedMaterial(server.URL, "key", "short"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("过短的文本应返回服务端错误: %v", err)
+	}
+
+	code, err := detectProtectedCode(server.URL, "key", "func quicksort(a []int) {}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysis := code.ProtectedMaterialAnalysis
+	if !analysis.Detected || len(analysis.CodeCitations) != 1 || analysis.CodeCitations[0].License != "MIT" || len(analysis.CodeCitations[0].SourceURLs) != 2 {
+		t.Errorf("受保护的代码 = %+v", analysis)
+	}
+	code, err = detectProtectedCode(server.URL, "key", "func main() {}")
+	if err != nil || code.ProtectedMaterialAnalysis.Detected || len(code.ProtectedMaterialAnalysis.CodeCitations) != 0 {
+		t.Errorf("原创代码 = %+v, %v", code, err)
+	}
+}
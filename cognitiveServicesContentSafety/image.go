@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 图片分析的服务限制
+const (
+	maxImageBytes     = 4 * 1024 * 1024 // 图片文件最大 4MB
+	minImageDimension = 50              // 宽高最小 50 像素
+	maxImageDimension = 7200            // 宽高最大 7200 像素
+)
+
+// 支持的图片扩展名，用于扫描目录
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+	".bmp": true, ".tif": true, ".tiff": true, ".webp": true,
+}
+
+// ImageData 表示要分析的图片，Content 和 BlobURL 二选一
+type ImageData struct {
+	Content string `json:"content,omitempty"` // Base64 编码的图片内容
+	BlobURL string `json:"blobUrl,omitempty"` // 存储在 Azure Blob 中的图片地址，内容安全资源需要有读取权限
+}
+
+// ImageAnalyzeRequest 表示图片分析的请求
+type ImageAnalyzeRequest struct {
+	Image ImageData `json:"image"`
+}
+
+// analyzeImage 分析图片内容；source 为本地文件路径或 Blob URL
+// 返回结果与文本分析一致，只包含 CategoriesAnalysis
+func analyzeImage(endpoint, apiKey, source string) (*ContentSafetyResponse, error) {
+	var data ImageData
+	if strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") {
+		data.BlobURL = source
+	} else {
+		content, err := loadImage(source)
+		if err != nil {
+			return nil, err
+		}
+		data.Content = base64.StdEncoding.EncodeToString(content)
+	}
+
+	apiURL := endpoint + "/contentsafety/image:analyze?api-version=2023-10-01"
+	var result ContentSafetyResponse
+	if err := callContentSafety(http.MethodPost, apiURL, apiKey, ImageAnalyzeRequest{Image: data}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// loadImage 读取本地图片并检查大小、格式和尺寸是否符合服务限制
+func loadImage(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %v", err)
+	}
+	if info.Size() > maxImageBytes {
+		return nil, fmt.Errorf("图片 %s 大小为 %d 字节，超过上限 %d 字节", path, info.Size(), maxImageBytes)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %v", err)
+	}
+
+	format := detectImageFormat(content)
+	if format == "" {
+		return nil, fmt.Errorf("图片 %s 的格式不受支持，仅支持 JPEG、PNG、GIF、BMP、TIFF 和 WEBP", path)
+	}
+	width, height, ok := imageSize(format, content)
+	if !ok {
+		// TIFF 和 WEBP 无法用标准库读取尺寸，交给服务校验
+		return content, nil
+	}
+	if width < minImageDimension || height < minImageDimension || width > maxImageDimension || height > maxImageDimension {
+		return nil, fmt.Errorf("图片 %s 的尺寸为 %dx%d，宽高必须在 %d 到 %d 像素之间",
+			path, width, height, minImageDimension, maxImageDimension)
+	}
+	return content, nil
+}
+
+// detectImageFormat 根据文件头判断图片格式，不支持的格式返回空字符串
+func detectImageFormat(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xFF, 0xD8, 0xFF}):
+		return "jpeg"
+	case bytes.HasPrefix(content, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(content, []byte("GIF87a")), bytes.HasPrefix(content, []byte("GIF89a")):
+		return "gif"
+	case bytes.HasPrefix(content, []byte("BM")):
+		return "bmp"
+	case bytes.HasPrefix(content, []byte("II*\x00")), bytes.HasPrefix(content, []byte("MM\x00*")):
+		return "tiff"
+	case len(content) >= 12 && string(content[0:4]) == "RIFF" && string(content[8:12]) == "WEBP":
+		return "webp"
+	}
+	return ""
+}
+
+// imageSize 返回图片的宽高，无法读取时 ok 为 false
+func imageSize(format string, content []byte) (width, height int, ok bool) {
+	switch format {
+	case "jpeg", "png", "gif":
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+		if err != nil {
+			return 0, 0, false
+		}
+		return cfg.Width, cfg.Height, true
+	case "bmp":
+		// BITMAPINFOHEADER 中的宽高位于偏移 18 和 22，高度为负表示自上而下存储
+		if len(content) < 26 {
+			return 0, 0, false
+		}
+		w := int32(binary.LittleEndian.Uint32(content[18:22]))
+		h := int32(binary.LittleEndian.Uint32(content[22:26]))
+		if h < 0 {
+			h = -h
+		}
+		return int(w), int(h), true
+	}
+	return 0, 0, false
+}
+
+// scanImageDirectory 分析目录（含子目录）下的所有图片，返回出错的文件数
+func scanImageDirectory(endpoint, apiKey, dir string) (int, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(path))] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("扫描目录失败: %v", err)
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		fmt.Printf("目录 %s 中没有找到图片\n", dir)
+		return 0, nil
+	}
+
+	failed := 0
+	for _, file := range files {
+		fmt.Printf("\n图片: %s\n", file)
+		result, err := analyzeImage(endpoint, apiKey, file)
+		if err != nil {
+			fmt.Printf("分析图片时出错: %v\n", err)
+			failed++
+			continue
+		}
+		printCategories(result)
+	}
+	fmt.Printf("\n共分析 %d 张图片，失败 %d 张\n", len(files), failed)
+	return failed, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// encodeImage 生成指定尺寸和格式的空白图片
+func encodeImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// bmpHeader 生成只包含文件头和 BITMAPINFOHEADER 宽高的 BMP 数据
+func bmpHeader(width, height int32) []byte {
+	header := make([]byte, 54)
+	copy(header, "BM")
+	binary.LittleEndian.PutUint32(header[18:22], uint32(width))
+	binary.LittleEndian.PutUint32(header[22:26], uint32(height))
+	return header
+}
+
+// writeImage 把图片内容写入临时文件
+func writeImage(t *testing.T, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDetectImageFormat(t *testing.T) {
+	cases := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"JPEG", encodeImage(t, "jpeg", 1, 1), "jpeg"},
+		{"PNG", encodeImage(t, "png", 1, 1), "png"},
+		{"GIF89a", encodeImage(t, "gif", 1, 1), "gif"},
+		{"GIF87a", []byte("GIF87a..."), "gif"},
+		{"BMP", bmpHeader(1, 1), "bmp"},
+		{"小端 TIFF", []byte("II*\x00\x08\x00\x00\x00"), "tiff"},
+		{"大端 TIFF", []byte("MM\x00*\x00\x00\x00\x08"), "tiff"},
+		{"WEBP", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "webp"},
+		{"RIFF 但不是 WEBP", []byte("RIFF\x00\x00\x00\x00WAVEfmt "), ""},
+		{"太短的 WEBP", []byte("RIFF\x00\x00\x00\x00WEB"), ""},
+		{"文本", []byte("hello"), ""},
+		{"空文件", nil, ""},
+	}
+	for _, c := range cases {
+		if got := detectImageFormat(c.content); got != c.want {
+			t.Errorf("%s: 格式 = %q，期望 %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestImageSize(t *testing.T) {
+	cases := []struct {
+		name          string
+		format        string
+		content       []byte
+		width, height int
+		ok            bool
+	}{
+		{"PNG", "png", encodeImage(t, "png", 60, 80), 60, 80, true},
+		{"JPEG", "jpeg", encodeImage(t, "jpeg", 120, 50), 120, 50, true},
+		{"GIF", "gif", encodeImage(t, "gif", 50, 51), 50, 51, true},
+		{"损坏的 PNG", "png", []byte("\x89PNG\r\n\x1a\nbroken"), 0, 0, false},
+		{"BMP", "bmp", bmpHeader(640, 480), 640, 480, true},
+		{"自上而下存储的 BMP", "bmp", bmpHeader(640, -480), 640, 480, true},
+		{"文件头不完整的 BMP", "bmp", bmpHeader(640, 480)[:25], 0, 0, false},
+		{"TIFF 交给服务校验", "tiff", []byte("II*\x00\x08\x00\x00\x00"), 0, 0, false},
+		{"WEBP 交给服务校验", "webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), 0, 0, false},
+	}
+	for _, c := range cases {
+		width, height, ok := imageSize(c.format, c.content)
+		if width != c.width || height != c.height || ok != c.ok {
+			t.Errorf("%s: 尺寸 = %dx%d, %v", c.name, width, height, ok)
+		}
+	}
+}
+
+func TestLoadImageLimits(t *testing.T) {
+	// 恰好 4MB 的图片可以分析，多一个字节就超过上限
+	padded := func(extra int) []byte {
+		content := encodeImage(t, "png", minImageDimension, minImageDimension)
+		return append(content, make([]byte, maxImageBytes-len(content)+extra)...)
+	}
+	cases := []struct {
+		name    string
+		content []byte
+		want    string // 为空表示加载成功
+	}{
+		{"最小尺寸", encodeImage(t, "png", minImageDimension, minImageDimension), ""},
+		{"最大尺寸", encodeImage(t, "png", maxImageDimension, minImageDimension), ""},
+		{"最大高度", encodeImage(t, "gif", minImageDimension, maxImageDimension), ""},
+		{"宽度太小", encodeImage(t, "png", minImageDimension-1, minImageDimension), "49x50"},
+		{"高度太小", encodeImage(t, "jpeg", minImageDimension, minImageDimension-1), "50x49"},
+		{"宽度太大", encodeImage(t, "png", maxImageDimension+1, minImageDimension), "7201x50"},
+		{"BMP 高度太大", bmpHeader(minImageDimension, -(maxImageDimension + 1)), "50x7201"},
+		{"BMP 在范围内", bmpHeader(maxImageDimension, maxImageDimension), ""},
+		{"恰好达到大小上限", padded(0), ""},
+		{"超过大小上限", padded(1), "超过上限 4194304 字节"},
+		{"不支持的格式", []byte("not an image"), "格式不受支持"},
+		{"TIFF 不检查尺寸", []byte("II*\x00\x08\x00\x00\x00"), ""},
+	}
+	for _, c := range cases {
+		content, err := loadImage(writeImage(t, c.content))
+		if c.want == "" {
+			if err != nil || !bytes.Equal(content, c.content) {
+				t.Errorf("%s: 加载失败: %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: 错误 = %v，期望包含 %q", c.name, err, c.want)
+		}
+	}
+
+	if _, err := loadImage(filepath.Join(t.TempDir(), "missing.png")); err == nil {
+		t.Error("不存在的文件应返回错误")
+	}
+}
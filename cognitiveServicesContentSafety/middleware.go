@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 请求体的默认大小上限
+const defaultModerationBodyBytes = 1 << 20
+
+// DecisionFunc 在审核完成后、默认处理之前调用；返回 true 表示已自行写入响应，请求不再继续
+type DecisionFunc func(w http.ResponseWriter, r *http.Request, decision *ModerationDecision) bool
+
+// MiddlewareConfig 表示审核中间件的配置
+type MiddlewareConfig struct {
+	Moderator    *Moderator   // 按策略文件中的阈值做出决定
+	Channel      string       // 使用的策略渠道，为空时使用默认规则
+	Channels     []string     // 允许通过请求头 X-Moderation-Channel 选择的渠道，为空时忽略该请求头
+	Fields       []string     // 要检查的 JSON 字段路径，如 "comment.body"、"messages.*.content"
+	AnnotateOnly bool         // 只在请求头和 context 中标注决定，不拦截请求
+	FailOpen     bool         // 内容安全服务出错时放行请求，默认返回 503
+	MaxBodyBytes int64        // 请求体大小上限，默认 1MB
+	OnDecision   DecisionFunc // 自定义处理，可为空
+}
+
+type moderationContextKey struct{}
+
+// moderationDecisionFrom 返回中间件保存在 context 中的审核决定，没有审核过时返回 nil
+func moderationDecisionFrom(ctx context.Context) *ModerationDecision {
+	decision, _ := ctx.Value(moderationContextKey{}).(*ModerationDecision)
+	return decision
+}
+
+// moderationMiddleware 返回检查请求体的 net/http 中间件
+// 只审核 Content-Type 为 JSON 且请求体不为空的请求，按配置的 JSON 路径取出文本字段逐个审核，取最严格的决定：
+// block 时返回 403 并记录日志；review 和 allow 时放行，决定写入请求头 X-Moderation-Decision 和 context。
+// 表单、文件上传和没有请求体的请求原样放行，不带审核决定
+func moderationMiddleware(cfg MiddlewareConfig) func(http.Handler) http.Handler {
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultModerationBodyBytes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 审核决定只能由中间件写入，去掉调用方伪造的请求头
+			r.Header.Del("X-Moderation-Decision")
+			if r.Body == nil || r.Method == http.MethodGet || r.Method == http.MethodHead || !isJSONContentType(r.Header.Get("Content-Type")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes))
+			if err != nil {
+				writeModerationError(w, http.StatusRequestEntityTooLarge, "请求体过大")
+				return
+			}
+			// 还原请求体，供后续处理函数读取
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			if len(bytes.TrimSpace(body)) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var payload any
+			if err := json.Unmarshal(body, &payload); err != nil {
+				writeModerationError(w, http.StatusBadRequest, "请求体不是有效的JSON")
+				return
+			}
+
+			channel := cfg.Channel
+			if header := r.Header.Get("X-Moderation-Channel"); header != "" && slices.Contains(cfg.Channels, header) {
+				channel = header
+			}
+			decision, field, err := moderateFields(cfg.Moderator, channel, payload, cfg.Fields)
+			if err != nil {
+				log.Printf("审核请求 %s %s 失败: %v", r.Method, r.URL.Path, err)
+				if !cfg.FailOpen {
+					writeModerationError(w, http.StatusServiceUnavailable, "内容审核暂时不可用")
+					return
+				}
+			}
+			if decision == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if cfg.OnDecision != nil && cfg.OnDecision(w, r, decision) {
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), moderationContextKey{}, decision))
+			r.Header.Set("X-Moderation-Decision", string(decision.Decision))
+			w.Header().Set("X-Moderation-Decision", string(decision.Decision))
+
+			if decision.Decision == DecisionBlock && !cfg.AnnotateOnly {
+				log.Printf("拦截请求 %s %s: 字段 %s，触发类别 %v，黑名单 %d 项，审核记录 #%d",
+					r.Method, r.URL.Path, field, decision.Categories, len(decision.BlocklistHits), decision.AuditID)
+				writeModerationJSON(w, http.StatusForbidden, map[string]any{
+					"error":    "内容未通过审核",
+					"field":    field,
+					"decision": decision,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isJSONContentType 判断 Content-Type 是否为 JSON（application/json 或 application/*+json）
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json")
+}
+
+// moderateFields 审核所有匹配路径的字段，返回最严格的决定及其字段路径；没有可审核的文本时返回 nil
+func moderateFields(moderator *Moderator, channel string, payload any, paths []string) (*ModerationDecision, string, error) {
+	var strictest *ModerationDecision
+	var strictestPath string
+	for _, path := range paths {
+		for _, field := range extractJSONPath(payload, path) {
+			if strings.TrimSpace(field.Text) == "" {
+				continue
+			}
+			decision, err := moderator.DecideChannel(channel, field.Text)
+			if err != nil {
+				return nil, field.Path, err
+			}
+			if strictest == nil || decision.Decision.rank() > strictest.Decision.rank() {
+				strictest, strictestPath = decision, field.Path
+			}
+			if strictest.Decision == DecisionBlock {
+				return strictest, strictestPath, nil
+			}
+		}
+	}
+	return strictest, strictestPath, nil
+}
+
+// jsonField 表示从 JSON 中取出的文本字段
+type jsonField struct {
+	Path string
+	Text string
+}
+
+// extractJSONPath 按点分隔的路径取出字符串字段；"*" 匹配数组的所有元素或对象的所有字段，数字匹配数组下标
+func extractJSONPath(value any, path string) []jsonField {
+	var fields []jsonField
+	var walk func(v any, parts []string, prefix string)
+	walk = func(v any, parts []string, prefix string) {
+		if len(parts) == 0 {
+			if text, ok := v.(string); ok {
+				fields = append(fields, jsonField{Path: prefix, Text: text})
+			}
+			return
+		}
+		join := func(key string) string {
+			if prefix == "" {
+				return key
+			}
+			return prefix + "." + key
+		}
+		part, rest := parts[0], parts[1:]
+		switch node := v.(type) {
+		case map[string]any:
+			if part == "*" {
+				for key, child := range node {
+					walk(child, rest, join(key))
+				}
+			} else if child, ok := node[part]; ok {
+				walk(child, rest, join(part))
+			}
+		case []any:
+			if part == "*" {
+				for i, child := range node {
+					walk(child, rest, join(strconv.Itoa(i)))
+				}
+			} else if i, err := strconv.Atoi(part); err == nil && i >= 0 && i < len(node) {
+				walk(node[i], rest, join(part))
+			}
+		}
+	}
+	walk(value, strings.Split(path, "."), "")
+	return fields
+}
+
+// moderationService 是独立运行的审核服务
+//
+//	POST /moderate  审核单段文本：{"text":"...","channel":"kids"}
+//	POST /webhook   按配置的字段路径审核任意 JSON，渠道可通过 ?channel= 或 X-Moderation-Channel 指定为策略中的任一渠道
+//	GET  /healthz   健康检查
+func moderationService(moderator *Moderator, fields []string, onDecision DecisionFunc) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /moderate", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Text    string `json:"text"`
+			Channel string `json:"channel"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, defaultModerationBodyBytes)).Decode(&req); err != nil || req.Text == "" {
+			writeModerationError(w, http.StatusBadRequest, "请求体必须包含 text")
+			return
+		}
+		// 渠道来自调用方，不在策略中时属于请求错误，不能当作上游失败
+		if _, ok := moderator.policy.Channels[req.Channel]; req.Channel != "" && !ok {
+			writeModerationError(w, http.StatusBadRequest, fmt.Sprintf("策略中没有渠道 %s", req.Channel))
+			return
+		}
+		decision, err := moderator.DecideChannel(req.Channel, req.Text)
+		if err != nil {
+			log.Printf("审核失败: %v", err)
+			writeModerationError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		if decision.Decision == DecisionBlock {
+			log.Printf("拦截内容: 触发类别 %v，黑名单 %d 项，审核记录 #%d", decision.Categories, len(decision.BlocklistHits), decision.AuditID)
+		}
+		writeModerationJSON(w, http.StatusOK, decision)
+	})
+
+	// webhook 只做标注，由调用方根据返回的决定处理
+	webhook := moderationMiddleware(MiddlewareConfig{
+		Moderator:    moderator,
+		Channels:     slices.Collect(maps.Keys(moderator.policy.Channels)),
+		Fields:       fields,
+		AnnotateOnly: true,
+		OnDecision:   onDecision,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision := moderationDecisionFrom(r.Context())
+		if decision == nil {
+			decision = &ModerationDecision{Decision: DecisionAllow, PolicyVersion: moderator.policy.Version}
+		}
+		writeModerationJSON(w, http.StatusOK, decision)
+	}))
+	mux.Handle("POST /webhook", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 中间件会放行非 JSON 的请求，webhook 不能把它们当作审核通过
+		if !isJSONContentType(r.Header.Get("Content-Type")) {
+			writeModerationError(w, http.StatusUnsupportedMediaType, "请求体必须是 JSON（Content-Type: application/json）")
+			return
+		}
+		if channel := r.URL.Query().Get("channel"); channel != "" {
+			r.Header.Set("X-Moderation-Channel", channel)
+		}
+		webhook.ServeHTTP(w, r)
+	}))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeModerationJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return mux
+}
+
+// logBlockedDecision 是服务默认使用的决定回调，只记录被拦截的内容，不改变处理流程
+func logBlockedDecision(w http.ResponseWriter, r *http.Request, decision *ModerationDecision) bool {
+	if decision.Decision == DecisionBlock {
+		log.Printf("webhook 拦截: %s 触发类别 %v，黑名单 %d 项，审核记录 #%d",
+			r.RemoteAddr, decision.Categories, len(decision.BlocklistHits), decision.AuditID)
+	}
+	return false
+}
+
+// writeModerationJSON 输出 JSON 响应
+func writeModerationJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeModerationError 输出 {"error": "..."} 格式的错误
+func writeModerationError(w http.ResponseWriter, status int, message string) {
+	writeModerationJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const middlewarePolicy = `version: v1
+default:
+  categories:
+    Hate: {review: 2, block: 6}
+    Violence: {block: 6}
+channels:
+  lenient:
+    categories:
+      Hate: {block: 6}
+`
+
+// newTestModerator 创建使用模拟服务和测试策略的审核器
+func newTestModerator(t *testing.T, endpoint string) *Moderator {
+	t.Helper()
+	policy, err := writePolicy(t, middlewarePolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newModerator(endpoint, "key", policy)
+}
+
+// echoHandler 返回处理函数收到的请求体、审核决定和 X-Moderation-Decision 请求头
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	decision := ""
+	if d := moderationDecisionFrom(r.Context()); d != nil {
+		decision = string(d.Decision)
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"body":     string(body),
+		"decision": decision,
+		"header":   r.Header.Get("X-Moderation-Decision"),
+	})
+})
+
+func TestModerationMiddleware(t *testing.T) {
+	stub, _ := newStubContentSafety(t)
+	handler := moderationMiddleware(MiddlewareConfig{
+		Moderator: newTestModerator(t, stub.URL),
+		Channels:  []string{"lenient"},
+		Fields:    []string{"title", "messages.*.content"},
+	})(echoHandler)
+
+	cases := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		headers     map[string]string
+		status      int
+		decision    string // 处理函数看到的决定，为空表示没有审核
+	}{
+		{"放行", "POST", "application/json", `{"title":"hi","messages":[{"content":"fine"}]}`, nil, 200, "allow"},
+		{"复核", "POST", "application/json; charset=utf-8", `{"title":"hate"}`, nil, 200, "review"},
+		{"拦截", "PUT", "application/json", `{"messages":[{"content":"ok"},{"content":"kill"}]}`, nil, 403, ""},
+		{"+json 类型", "POST", "application/merge-patch+json", `{"title":"kill"}`, nil, 403, ""},
+		{"无效的JSON", "POST", "application/json", `{"title":`, nil, 400, ""},
+		{"表单原样放行", "POST", "application/x-www-form-urlencoded", "title=kill", nil, 200, ""},
+		{"文件上传原样放行", "POST", "multipart/form-data; boundary=x", "--x\r\n\r\nkill\r\n--x--\r\n", nil, 200, ""},
+		{"没有请求体的 DELETE", "DELETE", "", "", nil, 200, ""},
+		{"JSON 类型但请求体为空", "DELETE", "application/json", "", nil, 200, ""},
+		{"伪造的决定请求头被删除", "POST", "text/plain", "x", map[string]string{"X-Moderation-Decision": "allow"}, 200, ""},
+		{"允许的渠道", "POST", "application/json", `{"title":"hate kill"}`, map[string]string{"X-Moderation-Channel": "lenient"}, 403, ""},
+		{"渠道放宽 Hate 阈值", "POST", "application/json", `{"title":"hate"}`, map[string]string{"X-Moderation-Channel": "lenient"}, 200, "allow"},
+		{"未列出的渠道被忽略", "POST", "application/json", `{"title":"hate"}`, map[string]string{"X-Moderation-Channel": "unknown"}, 200, "review"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/comments", strings.NewReader(c.body))
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Errorf("%s: 状态码 %d，期望 %d: %s", c.name, rec.Code, c.status, rec.Body)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var got map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &got)
+		if got["decision"] != c.decision || got["header"] != c.decision || got["body"] != c.body {
+			t.Errorf("%s: 处理函数收到 %v", c.name, got)
+		}
+		if rec.Header().Get("X-Moderation-Decision") != c.decision {
+			t.Errorf("%s: 响应头 = %q", c.name, rec.Header().Get("X-Moderation-Decision"))
+		}
+	}
+}
+
+func TestModerationMiddlewareServiceErrors(t *testing.T) {
+	for _, failOpen := range []bool{false, true} {
+		handler := moderationMiddleware(MiddlewareConfig{
+			Moderator: newTestModerator(t, "http://127.0.0.1:0"),
+			Fields:    []string{"title"},
+			FailOpen:  failOpen,
+		})(echoHandler)
+		req := httptest.NewRequest("POST", "/comments", strings.NewReader(`{"title":"hi"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if want := map[bool]int{false: 503, true: 200}[failOpen]; rec.Code != want {
+			t.Errorf("FailOpen=%v: 状态码 %d，期望 %d", failOpen, rec.Code, want)
+		}
+	}
+}
+
+func TestModerationServiceWebhook(t *testing.T) {
+	stub, _ := newStubContentSafety(t)
+	server := httptest.NewServer(moderationService(newTestModerator(t, stub.URL), []string{"comment.body"}, logBlockedDecision))
+	defer server.Close()
+
+	post := func(path, contentType, body string) (int, ModerationDecision) {
+		t.Helper()
+		resp, err := http.Post(server.URL+path, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var decision ModerationDecision
+		json.NewDecoder(resp.Body).Decode(&decision)
+		return resp.StatusCode, decision
+	}
+	for _, c := range []struct {
+		name, path, contentType, body string
+		status                        int
+		want                          Decision
+	}{
+		{"拦截只标注", "/webhook", "application/json", `{"comment":{"body":"kill"}}`, 200, DecisionBlock},
+		{"通过查询参数选择渠道", "/webhook?channel=lenient", "application/json", `{"comment":{"body":"hate"}}`, 200, DecisionAllow},
+		{"默认渠道", "/webhook", "application/json", `{"comment":{"body":"hate"}}`, 200, DecisionReview},
+		{"非 JSON 请求被拒绝", "/webhook", "application/x-www-form-urlencoded", "comment=kill", 415, ""},
+		{"单段文本", "/moderate", "application/json", `{"text":"kill"}`, 200, DecisionBlock},
+		{"单段文本的渠道", "/moderate", "application/json", `{"text":"hate","channel":"lenient"}`, 200, DecisionAllow},
+		{"未知渠道是请求错误", "/moderate", "application/json", `{"text":"kill","channel":"unknown"}`, 400, ""},
+	} {
+		status, decision := post(c.path, c.contentType, c.body)
+		if status != c.status || decision.Decision != c.want {
+			t.Errorf("%s: 状态码 %d，决定 %q", c.name, status, decision.Decision)
+		}
+	}
+}
+
+func TestExtractJSONPath(t *testing.T) {
+	var payload any
+	json.Unmarshal([]byte(`{"a":{"b":"x","n":1},"list":[{"c":"y"},{"c":"z"},{"d":"w"}]}`), &payload)
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"a.b", "a.b=x"},
+		{"a.n", ""},
+		{"list.*.c", "list.0.c=y list.1.c=z"},
+		{"list.1.c", "list.1.c=z"},
+		{"list.5.c", ""},
+		{"missing.path", ""},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, field := range extractJSONPath(payload, c.path) {
+			got = append(got, field.Path+"="+field.Text)
+		}
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("extractJSONPath(%s) = %q", c.path, got)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decision 表示审核决定
+type Decision string
+
+const (
+	DecisionAllow  Decision = "allow"  // 放行
+	DecisionReview Decision = "review" // 标记，等待人工复核
+	DecisionBlock  Decision = "block"  // 拦截
+)
+
+// rank 返回决定的严格程度，用于取多个决定中最严格的一个
+func (d Decision) rank() int {
+	switch d {
+	case DecisionReview:
+		return 1
+	case DecisionBlock:
+		return 2
+	}
+	return 0
+}
+
+// Threshold 表示一个类别的严重程度阈值，严重程度大于等于阈值时触发对应决定；未设置的阈值不生效
+// 没有问题的文本严重程度为 0，阈值设为 0 时该类别的所有文本都会触发，因此阈值一般从 2（四级）或 1（八级）开始
+type Threshold struct {
+	Review *int `yaml:"review"`
+	Block  *int `yaml:"block"`
+}
+
+// PolicyRule 表示一组审核规则
+type PolicyRule struct {
+	Categories         map[string]Threshold `yaml:"categories"`         // 按类别（Hate、SelfHarm、Sexual、Violence）设置的阈值
+	Blocklists         []string             `yaml:"blocklists"`         // 分析时匹配的黑名单
+	BlocklistDecision  Decision             `yaml:"blocklistDecision"`  // 命中黑名单时的决定，默认 block
+	HaltOnBlocklistHit *bool                `yaml:"haltOnBlocklistHit"` // 命中黑名单时不再进行类别分析
+	OutputType         string               `yaml:"outputType"`         // 严重程度的输出级别，阈值按该级别设置，默认四级
+}
+
+// Policy 表示审核策略文件
+//
+//	version: "2024-06-01"
+//	default:
+//	  outputType: EightSeverityLevels
+//	  blocklists: [banned-words]
+//	  categories:
+//	    Hate: {review: 2, block: 4}
+//	channels:
+//	  kids:
+//	    categories:
+//	      Violence: {review: 1, block: 2}
+//
+// 阈值必须是对应输出级别可能返回的严重程度：四级为 0、2、4、6，八级为 0-7
+type Policy struct {
+	Version  string                `yaml:"version"`  // 策略版本，会记录在审核结果中
+	Default  PolicyRule            `yaml:"default"`  // 默认规则
+	Channels map[string]PolicyRule `yaml:"channels"` // 按渠道覆盖默认规则
+}
+
+// loadPolicy 从 YAML 文件加载审核策略
+func loadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取策略文件失败: %v", err)
+	}
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("解析策略文件失败: %v", err)
+	}
+	if err := normalizeRule("default", &policy.Default, ""); err != nil {
+		return nil, err
+	}
+	for _, name := range slices.Sorted(maps.Keys(policy.Channels)) {
+		rule := policy.Channels[name]
+		if err := normalizeRule("channels."+name, &rule, policy.Default.OutputType); err != nil {
+			return nil, err
+		}
+		// 四级和八级的阈值不能混用：渠道改用另一种输出级别时，默认规则中的类别阈值都必须重新设置
+		if severityScale(rule.OutputType, policy.Default.OutputType) != severityScale(policy.Default.OutputType, "") {
+			for category := range policy.Default.Categories {
+				if _, ok := rule.Categories[category]; !ok {
+					return nil, fmt.Errorf("策略 channels.%s 修改了 outputType，但类别 %s 沿用了默认规则按另一种输出级别设置的阈值", name, category)
+				}
+			}
+		}
+		policy.Channels[name] = rule
+	}
+	return &policy, nil
+}
+
+// normalizeRule 规范化规则中的输出级别并校验黑名单决定和类别阈值；
+// inherited 为规则没有设置 outputType 时沿用的输出级别
+func normalizeRule(name string, rule *PolicyRule, inherited string) error {
+	outputType, err := parseOutputType(rule.OutputType)
+	if err != nil {
+		return fmt.Errorf("策略 %s 中的 outputType: %v", name, err)
+	}
+	rule.OutputType = outputType
+	switch rule.BlocklistDecision {
+	case "", DecisionAllow, DecisionReview, DecisionBlock:
+	default:
+		return fmt.Errorf("策略 %s 中的 blocklistDecision 无效: %s", name, rule.BlocklistDecision)
+	}
+	scale := severityScale(outputType, inherited)
+	for category, t := range rule.Categories {
+		if !slices.Contains(allCategories, category) {
+			return fmt.Errorf("策略 %s 中的类别无效: %s，可选类别: %s", name, category, strings.Join(allCategories, ", "))
+		}
+		for _, threshold := range []*int{t.Review, t.Block} {
+			if threshold != nil && !validThreshold(*threshold, scale) {
+				return fmt.Errorf("策略 %s 中类别 %s 的阈值 %d 无效，%s", name, category, *threshold, thresholdRange(scale))
+			}
+		}
+		if t.Review != nil && t.Block != nil && *t.Review > *t.Block {
+			return fmt.Errorf("策略 %s 中类别 %s 的 review 阈值大于 block 阈值", name, category)
+		}
+	}
+	return nil
+}
+
+// severityScale 返回规则实际使用的输出级别：没有设置时沿用 inherited，都没有设置时为服务默认的四级输出
+func severityScale(outputType, inherited string) string {
+	if outputType == "" {
+		outputType = inherited
+	}
+	if outputType == "" {
+		return OutputFourSeverityLevels
+	}
+	return outputType
+}
+
+// validThreshold 判断阈值是否是该输出级别可能返回的严重程度
+func validThreshold(threshold int, outputType string) bool {
+	if threshold < 0 || Severity(threshold) > maxSeverity {
+		return false
+	}
+	return outputType == OutputEightSeverityLevels || threshold%2 == 0
+}
+
+// thresholdRange 描述输出级别允许的阈值
+func thresholdRange(outputType string) string {
+	if outputType == OutputEightSeverityLevels {
+		return "八级输出的阈值为 0-7"
+	}
+	return "四级输出的阈值只能是 0、2、4、6"
+}
+
+// rule 返回渠道的有效规则：渠道中设置的类别阈值、黑名单和黑名单决定覆盖默认规则
+func (p *Policy) rule(channel string) (PolicyRule, error) {
+	rule := p.Default
+	if channel == "" {
+		return rule, nil
+	}
+	override, ok := p.Channels[channel]
+	if !ok {
+		return rule, fmt.Errorf("策略中没有渠道 %s", channel)
+	}
+	categories := make(map[string]Threshold, len(rule.Categories)+len(override.Categories))
+	for name, t := range rule.Categories {
+		categories[name] = t
+	}
+	for name, t := range override.Categories {
+		categories[name] = t
+	}
+	rule.Categories = categories
+	if override.Blocklists != nil {
+		rule.Blocklists = override.Blocklists
+	}
+	if override.BlocklistDecision != "" {
+		rule.BlocklistDecision = override.BlocklistDecision
+	}
+	if override.HaltOnBlocklistHit != nil {
+		rule.HaltOnBlocklistHit = override.HaltOnBlocklistHit
+	}
+	if override.OutputType != "" {
+		rule.OutputType = override.OutputType
+	}
+	return rule, nil
+}
+
+// TriggeredCategory 表示触发决定的类别
+type TriggeredCategory struct {
+	Category  string   `json:"category"`
+	Severity  Severity `json:"severity"`
+	Threshold int      `json:"threshold"`
+	Decision  Decision `json:"decision"`
+}
+
+// ModerationDecision 表示一次审核的结果
+type ModerationDecision struct {
+	Decision      Decision               `json:"decision"`
+	Channel       string                 `json:"channel,omitempty"`
+	PolicyVersion string                 `json:"policyVersion"`
+	Categories    []TriggeredCategory    `json:"categories,omitempty"`    // 超过阈值的类别
+	BlocklistHits []BlocklistMatch       `json:"blocklistHits,omitempty"` // 命中的黑名单项
+	Result        *ContentSafetyResponse `json:"-"`                       // 服务返回的原始结果
+	AuditID       int64                  `json:"auditId,omitempty"`       // 审核日志中的记录ID
+}
+
+// evaluate 按规则把分析结果转换为审核决定，取所有触发项中最严格的决定
+func (p *Policy) evaluate(rule PolicyRule, channel string, result *ContentSafetyResponse) *ModerationDecision {
+	decision := &ModerationDecision{Decision: DecisionAllow, Channel: channel, PolicyVersion: p.Version, Result: result}
+	raise := func(d Decision) {
+		if d.rank() > decision.Decision.rank() {
+			decision.Decision = d
+		}
+	}
+
+	for _, analysis := range result.CategoriesAnalysis {
+		t, ok := rule.Categories[analysis.Category]
+		if !ok {
+			continue
+		}
+		severity := int(analysis.Severity)
+		switch {
+		case t.Block != nil && severity >= *t.Block:
+			decision.Categories = append(decision.Categories, TriggeredCategory{analysis.Category, analysis.Severity, *t.Block, DecisionBlock})
+			raise(DecisionBlock)
+		case t.Review != nil && severity >= *t.Review:
+			decision.Categories = append(decision.Categories, TriggeredCategory{analysis.Category, analysis.Severity, *t.Review, DecisionReview})
+			raise(DecisionReview)
+		}
+	}
+	sort.Slice(decision.Categories, func(i, j int) bool { return decision.Categories[i].Category < decision.Categories[j].Category })
+
+	if len(result.BlocklistsMatch) > 0 {
+		decision.BlocklistHits = result.BlocklistsMatch
+		if rule.BlocklistDecision == "" {
+			raise(DecisionBlock)
+		} else {
+			raise(rule.BlocklistDecision)
+		}
+	}
+	return decision
+}
+
+// Moderator 按审核策略分析文本
+type Moderator struct {
+	endpoint string
+	apiKey   string
+	policy   *Policy
+	audit    *auditLog // 审核日志，为空时不记录
+}
+
+// newModerator 创建审核器
+func newModerator(endpoint, apiKey string, policy *Policy) *Moderator {
+	return &Moderator{endpoint: endpoint, apiKey: apiKey, policy: policy}
+}
+
+// Decide 按默认规则审核文本
+func (m *Moderator) Decide(text string) (*ModerationDecision, error) {
+	return m.DecideChannel("", text)
+}
+
+// DecideChannel 按指定渠道的规则审核文本，渠道为空时使用默认规则
+func (m *Moderator) DecideChannel(channel, text string) (*ModerationDecision, error) {
+	rule, err := m.policy.rule(channel)
+	if err != nil {
+		return nil, err
+	}
+	opts := AnalyzeTextOptions{BlocklistNames: rule.Blocklists, OutputType: rule.OutputType}
+	if rule.HaltOnBlocklistHit != nil {
+		opts.HaltOnBlocklistHit = *rule.HaltOnBlocklistHit
+	}
+	result, err := analyzeText(m.endpoint, m.apiKey, text, opts)
+	if err != nil {
+		return nil, err
+	}
+	decision := m.policy.evaluate(rule, channel, result)
+	if m.audit != nil {
+		if decision.AuditID, err = m.audit.record(decision, text); err != nil {
+			return nil, err
+		}
+	}
+	return decision, nil
+}
+
+// printDecision 打印审核决定
+func printDecision(decision *ModerationDecision) {
+	fmt.Printf("决定: %s（策略版本 %s", decision.Decision, decision.PolicyVersion)
+	if decision.Channel != "" {
+		fmt.Printf("，渠道 %s", decision.Channel)
+	}
+	fmt.Println("）")
+	if decision.AuditID != 0 {
+		fmt.Printf("审核日志记录: #%d\n", decision.AuditID)
+	}
+	for _, c := range decision.Categories {
+		fmt.Printf("- 类别: %s, 严重程度: %d, 阈值: %d, 决定: %s\n", c.Category, c.Severity, c.Threshold, c.Decision)
+	}
+	for _, hit := range decision.BlocklistHits {
+		fmt.Printf("- 黑名单: %s, 项目ID: %s, 文本: %s\n", hit.BlocklistName, hit.BlocklistItemId, hit.BlocklistItemText)
+	}
+}
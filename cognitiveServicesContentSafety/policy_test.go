@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writePolicy 把策略内容写入临时文件并加载
+func writePolicy(t *testing.T, content string) (*Policy, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return loadPolicy(path)
+}
+
+func TestLoadPolicyExample(t *testing.T) {
+	policy, err := loadPolicy("policy.example.yaml")
+	if err != nil {
+		t.Fatalf("加载示例策略失败: %v", err)
+	}
+	rule, err := policy.rule("kids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有问题的文本在任何渠道都放行
+	clean := &ContentSafetyResponse{CategoriesAnalysis: []CategoryAnalysis{
+		{Category: CategoryHate}, {Category: CategorySelfHarm}, {Category: CategorySexual}, {Category: CategoryViolence},
+	}}
+	for _, channel := range []string{"", "kids", "internal"} {
+		rule, err := policy.rule(channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := policy.evaluate(rule, channel, clean); d.Decision != DecisionAllow {
+			t.Errorf("渠道 %q 中没有问题的文本被判定为 %s", channel, d.Decision)
+		}
+	}
+	if got := rule.Categories[CategoryHate]; got.Review == nil || *got.Review != 2 {
+		t.Errorf("kids 渠道没有沿用默认的 Hate 阈值: %+v", got)
+	}
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	cases := []struct {
+		name, content, want string
+	}{
+		{"未知类别", "default:\n  categories:\n    Violense: {block: 4}\n", "类别无效"},
+		{"渠道中的未知类别", "channels:\n  kids:\n    categories:\n      hate: {block: 2}\n", "类别无效"},
+		{"四级输出的奇数阈值", "default:\n  categories:\n    Hate: {review: 3}\n", "阈值 3 无效"},
+		{"超出范围的阈值", "default:\n  outputType: EightSeverityLevels\n  categories:\n    Hate: {block: 8}\n", "阈值 8 无效"},
+		{"负数阈值", "default:\n  categories:\n    Hate: {review: -2}\n", "阈值 -2 无效"},
+		{"渠道沿用默认的四级输出", "channels:\n  kids:\n    categories:\n      Hate: {block: 5}\n", "阈值 5 无效"},
+		{"review 大于 block", "default:\n  categories:\n    Hate: {review: 4, block: 2}\n", "大于 block"},
+		{"无效的黑名单决定", "default:\n  blocklistDecision: drop\n", "blocklistDecision"},
+		{"无效的输出级别", "default:\n  outputType: TenLevels\n", "outputType"},
+	}
+	for _, c := range cases {
+		if _, err := writePolicy(t, c.content); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: 错误 = %v，期望包含 %q", c.name, err, c.want)
+		}
+	}
+
+	// 八级输出允许奇数阈值
+	if _, err := writePolicy(t, "default:\n  outputType: eightseveritylevels\n  categories:\n    Hate: {review: 1, block: 3}\n"); err != nil {
+		t.Errorf("八级阈值应当有效: %v", err)
+	}
+}
+
+func TestLoadPolicyOutputTypes(t *testing.T) {
+	const base = "default:\n  categories:\n    Hate: {review: 2, block: 4}\n    Violence: {block: 6}\n"
+	cases := []struct {
+		name, channels string
+		ok             bool
+	}{
+		{"渠道改用八级但沿用四级阈值", "  adult:\n    outputType: EightSeverityLevels\n    categories:\n      Hate: {block: 5}\n", false},
+		{"渠道改用八级并重新设置所有阈值", "  adult:\n    outputType: EightSeverityLevels\n    categories:\n      Hate: {block: 5}\n      Violence: {block: 7}\n", true},
+		{"显式设置与默认相同的级别", "  kids:\n    outputType: FourSeverityLevels\n    categories:\n      Hate: {block: 2}\n", true},
+	}
+	for _, c := range cases {
+		if _, err := writePolicy(t, base+"channels:\n"+c.channels); (err == nil) != c.ok {
+			t.Errorf("%s: 错误 = %v", c.name, err)
+		}
+	}
+
+	// 渠道中的 outputType 同样规范化
+	policy, err := writePolicy(t, "channels:\n  adult:\n    outputType: eightseveritylevels\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := policy.Channels["adult"].OutputType; got != OutputEightSeverityLevels {
+		t.Errorf("渠道的 outputType = %q", got)
+	}
+
+	// 默认规则为八级时，渠道改回四级同样需要重新设置阈值
+	eight := "default:\n  outputType: EightSeverityLevels\n  categories:\n    Hate: {review: 1, block: 3}\n"
+	if _, err := writePolicy(t, eight+"channels:\n  legacy:\n    outputType: FourSeverityLevels\n"); err == nil {
+		t.Error("渠道改回四级且沿用八级阈值时应返回错误")
+	}
+	policy, err = writePolicy(t, eight+"channels:\n  strict:\n    categories:\n      Hate: {block: 1}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule, _ := policy.rule("strict"); rule.OutputType != OutputEightSeverityLevels {
+		t.Errorf("渠道没有沿用默认的输出级别: %q", rule.OutputType)
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy, err := writePolicy(t, `version: v1
+default:
+  categories:
+    Hate: {review: 2, block: 4}
+    Violence: {block: 6}
+channels:
+  forum:
+    blocklistDecision: review
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysis := func(hate, violence Severity, blocklist bool) *ContentSafetyResponse {
+		result := &ContentSafetyResponse{CategoriesAnalysis: []CategoryAnalysis{
+			{Category: CategoryHate, Severity: hate},
+			{Category: CategorySexual, Severity: 6}, // 没有配置的类别不参与决定
+			{Category: CategoryViolence, Severity: violence},
+		}}
+		if blocklist {
+			result.BlocklistsMatch = []BlocklistMatch{{BlocklistName: "words", BlocklistItemText: "x"}}
+		}
+		return result
+	}
+	cases := []struct {
+		name      string
+		channel   string
+		result    *ContentSafetyResponse
+		want      Decision
+		triggered []string
+	}{
+		{"低于阈值", "", analysis(0, 4, false), DecisionAllow, nil},
+		{"复核", "", analysis(2, 0, false), DecisionReview, []string{"Hate/review"}},
+		{"取最严格的决定", "", analysis(2, 6, false), DecisionBlock, []string{"Hate/review", "Violence/block"}},
+		{"黑名单默认拦截", "", analysis(0, 0, true), DecisionBlock, nil},
+		{"渠道的黑名单决定", "forum", analysis(0, 0, true), DecisionReview, nil},
+		{"黑名单不会降低类别决定", "forum", analysis(4, 0, true), DecisionBlock, []string{"Hate/block"}},
+	}
+	for _, c := range cases {
+		rule, err := policy.rule(c.channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := policy.evaluate(rule, c.channel, c.result)
+		var triggered []string
+		for _, category := range d.Categories {
+			triggered = append(triggered, category.Category+"/"+string(category.Decision))
+		}
+		if d.Decision != c.want || !reflect.DeepEqual(triggered, c.triggered) || d.PolicyVersion != "v1" {
+			t.Errorf("%s: 决定 = %s，触发 %q", c.name, d.Decision, triggered)
+		}
+	}
+
+	if _, err := policy.rule("unknown"); err == nil {
+		t.Error("未知渠道应返回错误")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ProtectedMaterialResponse 表示受保护文本（歌词、文章、菜谱等已知内容）的检测结果
+type ProtectedMaterialResponse struct {
+	ProtectedMaterialAnalysis struct {
+		Detected bool `json:"detected"`
+	} `json:"protectedMaterialAnalysis"`
+}
+
+// CodeCitation 表示匹配到的公开代码来源
+type CodeCitation struct {
+	License    string   `json:"license"`
+	SourceURLs []string `json:"sourceUrls"`
+}
+
+// ProtectedCodeResponse 表示受保护代码的检测结果
+type ProtectedCodeResponse struct {
+	ProtectedMaterialAnalysis struct {
+		Detected      bool           `json:"detected"`
+		CodeCitations []CodeCitation `json:"codeCitations"`
+	} `json:"protectedMaterialAnalysis"`
+}
+
+// detectProtectedMaterial 检测文本是否复现了受保护的内容；服务要求文本至少 110 个字符
+func detectProtectedMaterial(endpoint, apiKey, text string) (*ProtectedMaterialResponse, error) {
+	apiURL := endpoint + "/contentsafety/text:detectProtectedMaterial?api-version=2024-09-01"
+	var result ProtectedMaterialResponse
+	if err := callContentSafety(http.MethodPost, apiURL, apiKey, map[string]string{"text": text}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// detectProtectedCode 检测代码是否与 GitHub 公开仓库中的代码匹配，并返回来源和许可证
+func detectProtectedCode(endpoint, apiKey, code string) (*ProtectedCodeResponse, error) {
+	apiURL := endpoint + "/contentsafety/text:detectProtectedMaterialForCode?api-version=2024-09-15-preview"
+	var result ProtectedCodeResponse
+	if err := callContentSafety(http.MethodPost, apiURL, apiKey, map[string]string{"code": code}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// printProtectedCode 打印受保护代码的检测结果
+func printProtectedCode(result *ProtectedCodeResponse) {
+	analysis := result.ProtectedMaterialAnalysis
+	if !analysis.Detected {
+		fmt.Println("没有检测到受保护的代码")
+		return
+	}
+	fmt.Println("检测到受保护的代码:")
+	for _, citation := range analysis.CodeCitations {
+		fmt.Printf("- 许可证: %s\n", citation.License)
+		for _, url := range citation.SourceURLs {
+			fmt.Printf("  来源: %s\n", url)
+		}
+	}
+}
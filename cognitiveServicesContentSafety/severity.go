@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity 表示严重程度；四级输出只返回 0、2、4、6，八级输出返回 0-7
+type Severity int
+
+// 最高严重程度，两种输出方式都不超过 7
+const maxSeverity Severity = 7
+
+// 严重程度的输出级别
+const (
+	OutputFourSeverityLevels  = "FourSeverityLevels"  // 四级输出（默认）
+	OutputEightSeverityLevels = "EightSeverityLevels" // 八级输出，仅文本分析支持
+)
+
+// 分析的类别
+const (
+	CategoryHate     = "Hate"
+	CategorySelfHarm = "SelfHarm"
+	CategorySexual   = "Sexual"
+	CategoryViolence = "Violence"
+)
+
+// allCategories 是服务支持的所有类别
+var allCategories = []string{CategoryHate, CategorySelfHarm, CategorySexual, CategoryViolence}
+
+// parseOutputType 校验输出级别，大小写不敏感，为空时使用服务默认值
+func parseOutputType(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return "", nil
+	case strings.ToLower(OutputFourSeverityLevels):
+		return OutputFourSeverityLevels, nil
+	case strings.ToLower(OutputEightSeverityLevels):
+		return OutputEightSeverityLevels, nil
+	}
+	return "", fmt.Errorf("无效的输出级别: %s，请使用 %s 或 %s", s, OutputFourSeverityLevels, OutputEightSeverityLevels)
+}
+
+// parseCategories 解析逗号分隔的类别列表，大小写不敏感，为空时分析所有类别
+func parseCategories(s string) ([]string, error) {
+	var categories []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, category := range allCategories {
+			if strings.EqualFold(name, category) {
+				categories = append(categories, category)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("无效的类别: %s，可选类别: %s", name, strings.Join(allCategories, ", "))
+		}
+	}
+	return categories, nil
+}
+
+// bar 把严重程度渲染为长度固定的条形，便于在终端中比较
+func (s Severity) bar() string {
+	filled := min(max(s, 0), maxSeverity)
+	return strings.Repeat("█", int(filled)) + strings.Repeat("░", int(maxSeverity-filled))
+}
+
+// printCategories 按类别打印严重程度条形
+func printCategories(result *ContentSafetyResponse) {
+	if len(result.CategoriesAnalysis) == 0 {
+		fmt.Println("没有检测到任何类别问题")
+		return
+	}
+	fmt.Println("类别分析:")
+	for _, category := range result.CategoriesAnalysis {
+		fmt.Printf("- %-8s %s %d\n", category.Category, category.Severity.bar(), category.Severity)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseCategories(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []string
+		err   string
+	}{
+		{"", nil, ""},
+		{" , ", nil, ""},
+		{"Hate", []string{CategoryHate}, ""},
+		{"hate, SELFHARM ,sexual,Violence", []string{CategoryHate, CategorySelfHarm, CategorySexual, CategoryViolence}, ""},
+		{"Violence,,Hate", []string{CategoryViolence, CategoryHate}, ""},
+		{"Hate,Violense", nil, "无效的类别: Violense"},
+		{"self-harm", nil, "无效的类别: self-harm"},
+	}
+	for _, c := range cases {
+		got, err := parseCategories(c.input)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("parseCategories(%q) 错误 = %v，期望包含 %q", c.input, err, c.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseCategories(%q) = %q, %v", c.input, got, err)
+		}
+	}
+}
+
+func TestParseOutputType(t *testing.T) {
+	cases := []struct {
+		input, want string
+		ok          bool
+	}{
+		{"", "", true},
+		{"FourSeverityLevels", OutputFourSeverityLevels, true},
+		{" fourseveritylevels ", OutputFourSeverityLevels, true},
+		{"EightSeverityLevels", OutputEightSeverityLevels, true},
+		{"EIGHTSEVERITYLEVELS", OutputEightSeverityLevels, true},
+		{"Eight", "", false},
+		{"TenSeverityLevels", "", false},
+	}
+	for _, c := range cases {
+		got, err := parseOutputType(c.input)
+		if got != c.want || (err == nil) != c.ok {
+			t.Errorf("parseOutputType(%q) = %q, %v", c.input, got, err)
+		}
+	}
+}
+
+func TestSeverityBar(t *testing.T) {
+	cases := []struct {
+		severity Severity
+		want     string
+	}{
+		{0, "░░░░░░░"},
+		{2, "██░░░░░"},
+		{maxSeverity, "███████"},
+		{-1, "░░░░░░░"}, // 超出范围的值按边界渲染
+		{9, "███████"},
+	}
+	for _, c := range cases {
+		got := c.severity.bar()
+		if got != c.want {
+			t.Errorf("Severity(%d).bar() = %q，期望 %q", c.severity, got, c.want)
+		}
+		if utf8.RuneCountInString(got) != int(maxSeverity) {
+			t.Errorf("Severity(%d).bar() 长度不固定", c.severity)
+		}
+	}
+}
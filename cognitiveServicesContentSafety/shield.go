@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+// Prompt Shields 的服务限制
+const (
+	maxShieldChars     = 10000 // 用户提示和文档的总字符数上限
+	maxShieldDocuments = 5     // 每次最多检测的文档数
+)
+
+// ShieldPromptRequest 表示 Prompt Shields 的请求
+type ShieldPromptRequest struct {
+	UserPrompt string   `json:"userPrompt,omitempty"` // 用户输入，检测越狱（jailbreak）攻击
+	Documents  []string `json:"documents,omitempty"`  // 附带的文档、邮件等，检测间接注入攻击
+}
+
+// AttackAnalysis 表示单段内容的攻击检测结果
+type AttackAnalysis struct {
+	AttackDetected bool `json:"attackDetected"`
+}
+
+// ShieldPromptResponse 表示 Prompt Shields 的检测结果
+type ShieldPromptResponse struct {
+	UserPromptAnalysis *AttackAnalysis  `json:"userPromptAnalysis,omitempty"`
+	DocumentsAnalysis  []AttackAnalysis `json:"documentsAnalysis,omitempty"` // 与请求中的文档一一对应
+}
+
+// AttackDetected 判断用户提示或任一文档中是否检测到攻击
+func (r *ShieldPromptResponse) AttackDetected() bool {
+	if r.UserPromptAnalysis != nil && r.UserPromptAnalysis.AttackDetected {
+		return true
+	}
+	for _, doc := range r.DocumentsAnalysis {
+		if doc.AttackDetected {
+			return true
+		}
+	}
+	return false
+}
+
+// AttackedDocuments 返回检测到攻击的文档下标
+func (r *ShieldPromptResponse) AttackedDocuments() []int {
+	var indexes []int
+	for i, doc := range r.DocumentsAnalysis {
+		if doc.AttackDetected {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// shieldPrompt 检测用户提示中的越狱攻击以及文档中的间接注入攻击
+func shieldPrompt(endpoint, apiKey, userPrompt string, documents []string) (*ShieldPromptResponse, error) {
+	if userPrompt == "" && len(documents) == 0 {
+		return nil, fmt.Errorf("用户提示和文档不能同时为空")
+	}
+	if len(documents) > maxShieldDocuments {
+		return nil, fmt.Errorf("文档数 %d 超过上限 %d", len(documents), maxShieldDocuments)
+	}
+	chars := utf8.RuneCountInString(userPrompt)
+	for _, doc := range documents {
+		chars += utf8.RuneCountInString(doc)
+	}
+	if chars > maxShieldChars {
+		return nil, fmt.Errorf("用户提示和文档共 %d 个字符，超过上限 %d", chars, maxShieldChars)
+	}
+
+	apiURL := endpoint + "/contentsafety/text:shieldPrompt?api-version=2024-09-01"
+	var result ShieldPromptResponse
+	request := ShieldPromptRequest{UserPrompt: userPrompt, Documents: documents}
+	if err := callContentSafety(http.MethodPost, apiURL, apiKey, request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// printShieldResult 打印攻击检测结果
+func printShieldResult(result *ShieldPromptResponse) {
+	if result.UserPromptAnalysis != nil {
+		fmt.Printf("用户提示: 检测到攻击 = %v\n", result.UserPromptAnalysis.AttackDetected)
+	}
+	for i, doc := range result.DocumentsAnalysis {
+		fmt.Printf("文档 %d: 检测到攻击 = %v\n", i+1, doc.AttackDetected)
+	}
+}